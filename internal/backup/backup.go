@@ -0,0 +1,436 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/projectpath"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// ManifestName is the archive entry describing the other entries.
+const ManifestName = "MANIFEST.json"
+
+// FormatVersion is bumped when the archive layout changes incompatibly.
+const FormatVersion = 1
+
+const filePrefix = "jevons-backup-"
+
+// ArchivedFiles lists the DataRoot files captured by a backup: the event
+// stores, manifests and sync checkpoints. Missing files are skipped.
+var ArchivedFiles = []string{
+	"events.tsv",
+	"events-ext.tsv",
+	"events.idx",
+	"live-events.tsv",
+	"projects.json",
+	"account.json",
+	store.AccountsFile,
+	"sync-status.json",
+	model.ConfigFileName,
+	projectpath.AliasesFile,
+	vault.KeyInfoFile,
+}
+
+// Manifest describes the contents of a backup archive.
+type Manifest struct {
+	FormatVersion int         `json:"format_version"`
+	CreatedAt     string      `json:"created_at"`
+	Files         []FileEntry `json:"files"`
+}
+
+// FileEntry records one archived file and its checksum.
+type FileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// DefaultDir returns the directory backups are written to when none is configured.
+func DefaultDir(dataRoot string) string {
+	return filepath.Join(dataRoot, "backups")
+}
+
+// Create writes a timestamped, gzip-compressed tar of the data-root stores
+// into dir and returns its path. Sealed files are archived as ciphertext.
+func Create(dataRoot, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, store.DirMode); err != nil {
+		return "", err
+	}
+
+	manifest := Manifest{FormatVersion: FormatVersion, CreatedAt: now.UTC().Format(time.RFC3339)}
+	contents := make(map[string][]byte)
+	for _, name := range ArchivedFiles {
+		data, err := os.ReadFile(filepath.Join(dataRoot, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", name, err)
+		}
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, FileEntry{Name: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+		contents[name] = data
+	}
+	if len(manifest.Files) == 0 {
+		return "", fmt.Errorf("nothing to back up in %s. Run: jevons sync", dataRoot)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	addEntry := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: int64(store.FileMode), Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := addEntry(ManifestName, append(manifestData, '\n')); err != nil {
+		return "", err
+	}
+	for _, f := range manifest.Files {
+		if err := addEntry(f.Name, contents[f.Name]); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, filePrefix+now.UTC().Format("20060102T150405Z")+".tar.gz")
+	if err := store.WriteFile(path, buf.Bytes(), nil); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Archive is a validated backup loaded into memory.
+type Archive struct {
+	Manifest Manifest
+	Files    map[string][]byte
+}
+
+// Open reads and validates an archive: the manifest must be present and of a
+// supported version, every listed file must match its checksum, and no
+// unexpected entries may appear.
+func Open(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	allowed := map[string]bool{ManifestName: true}
+	for _, name := range ArchivedFiles {
+		allowed[name] = true
+	}
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if !allowed[hdr.Name] {
+			return nil, fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+		entries[hdr.Name] = data
+	}
+
+	raw, ok := entries[ManifestName]
+	if !ok {
+		return nil, errors.New("archive has no " + ManifestName)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestName, err)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d", manifest.FormatVersion)
+	}
+
+	files := make(map[string][]byte, len(manifest.Files))
+	for _, fe := range manifest.Files {
+		data, ok := entries[fe.Name]
+		if !ok {
+			return nil, fmt.Errorf("archive is missing %s", fe.Name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != fe.SHA256 {
+			return nil, fmt.Errorf("checksum mismatch for %s", fe.Name)
+		}
+		files[fe.Name] = data
+	}
+	if len(files) != len(entries)-1 {
+		return nil, errors.New("archive contains files not listed in the manifest")
+	}
+
+	return &Archive{Manifest: manifest, Files: files}, nil
+}
+
+// Rotate deletes all but the newest keep backups in dir and returns the removed paths.
+func Rotate(dir string, keep int) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, filePrefix+"*.tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+	if keep < 1 || len(matches) <= keep {
+		return nil, nil
+	}
+	// Timestamped names sort chronologically.
+	sort.Strings(matches)
+	var removed []string
+	for _, path := range matches[:len(matches)-keep] {
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// RestoreResult summarizes a restore.
+type RestoreResult struct {
+	EventsBefore int      `json:"events_before"`
+	EventsAfter  int      `json:"events_after"`
+	EventsAdded  int      `json:"events_added"`
+	Restored     []string `json:"restored"`
+	Kept         []string `json:"kept"`
+	LiveSkipped  int      `json:"live_skipped"` // Malformed live-events.tsv rows left out of the merge
+}
+
+// Restore merges a validated archive into cfg.DataRoot without clobbering
+// newer data: event stores and projects.json are merged with what is on disk,
+// and every other file is only restored when it does not exist yet.
+func Restore(a *Archive, cfg model.Config) (*RestoreResult, error) {
+	if err := os.MkdirAll(cfg.DataRoot, store.DirMode); err != nil {
+		return nil, err
+	}
+	lock, err := store.Lock(cfg.DataRoot, false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	result := &RestoreResult{}
+
+	// Restore the KDF salt before loading the key so passphrase-encrypted
+	// archives can be opened in a fresh data root.
+	if data, ok := a.Files[vault.KeyInfoFile]; ok {
+		restored, err := restoreIfMissing(cfg.DataRoot, vault.KeyInfoFile, data)
+		if err != nil {
+			return nil, err
+		}
+		result.note(vault.KeyInfoFile, restored)
+	}
+	v, err := vault.Load(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("load encryption key: %w", err)
+	}
+
+	if data, ok := a.Files["events.tsv"]; ok {
+		before, after, err := mergeEvents(filepath.Join(cfg.DataRoot, "events.tsv"), data, a.Files["events-ext.tsv"], v)
+		if err != nil {
+			return nil, fmt.Errorf("merge events.tsv: %w", err)
+		}
+		result.EventsBefore, result.EventsAfter = before, after
+		result.EventsAdded = after - before
+		result.Restored = append(result.Restored, "events.tsv")
+	}
+	if data, ok := a.Files["live-events.tsv"]; ok {
+		skipped, err := mergeLiveEvents(filepath.Join(cfg.DataRoot, "live-events.tsv"), data, v)
+		if err != nil {
+			return nil, fmt.Errorf("merge live-events.tsv: %w", err)
+		}
+		result.LiveSkipped = skipped
+		result.Restored = append(result.Restored, "live-events.tsv")
+	}
+	if data, ok := a.Files["projects.json"]; ok {
+		if err := mergeProjects(filepath.Join(cfg.DataRoot, "projects.json"), data, v); err != nil {
+			return nil, fmt.Errorf("merge projects.json: %w", err)
+		}
+		result.Restored = append(result.Restored, "projects.json")
+	}
+
+	// Everything else is restored only when missing.
+	merged := map[string]bool{
+		vault.KeyInfoFile: true, "events.tsv": true, "events-ext.tsv": true,
+		"events.idx": true, "live-events.tsv": true, "projects.json": true,
+	}
+	for _, name := range ArchivedFiles {
+		data, ok := a.Files[name]
+		if !ok || merged[name] {
+			continue
+		}
+		restored, err := restoreIfMissing(cfg.DataRoot, name, data)
+		if err != nil {
+			return nil, err
+		}
+		result.note(name, restored)
+	}
+
+	return result, nil
+}
+
+func (r *RestoreResult) note(name string, restored bool) {
+	if restored {
+		r.Restored = append(r.Restored, name)
+	} else {
+		r.Kept = append(r.Kept, name)
+	}
+}
+
+func restoreIfMissing(dataRoot, name string, data []byte) (bool, error) {
+	path := filepath.Join(dataRoot, name)
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	if err := store.WriteFile(path, data, nil); err != nil {
+		return false, fmt.Errorf("restore %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// mergeEvents unions archived and on-disk events, returning row counts before and after.
+// Events only found in the archive are numbered after the current high-water
+// mark so change-feed cursors held by consumers stay valid.
+func mergeEvents(path string, archived, archivedExt []byte, v *vault.Vault) (int, int, error) {
+	current, err := store.ReadEvents(path, v)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+	plain, err := v.Open(archived)
+	if err != nil {
+		return 0, 0, err
+	}
+	plainExt, err := v.Open(archivedExt)
+	if err != nil {
+		return 0, 0, err
+	}
+	old := store.ParseEvents(plain, plainExt)
+
+	merged := append(append([]model.TokenEvent{}, current...), old...)
+	store.SortEvents(merged)
+	merged = store.DedupEvents(merged)
+	seqs := store.LoadSeqs(filepath.Dir(path), current)
+	seqs.Assign(current)
+	seqs.Assign(merged)
+	if err := store.WriteEventsTSV(path, merged, v); err != nil {
+		return 0, 0, err
+	}
+	return len(current), len(merged), nil
+}
+
+// mergeLiveEvents unions archived and on-disk live events, returning how
+// many malformed rows it left out.
+func mergeLiveEvents(path string, archived []byte, v *vault.Vault) (int, error) {
+	current, skipped, err := readTSV(path, v, store.UnmarshalLiveEvent)
+	if err != nil {
+		return 0, err
+	}
+	plain, err := v.Open(archived)
+	if err != nil {
+		return 0, err
+	}
+	old, oldSkipped := parseTSV(plain, store.UnmarshalLiveEvent)
+
+	merged := append(append([]model.LiveEvent{}, current...), old...)
+	store.SortLiveEvents(merged)
+	merged = store.DedupLiveEvents(merged)
+	return skipped + oldSkipped, store.WriteLiveEventsTSV(path, merged, v)
+}
+
+// mergeProjects keeps every on-disk entry and adds archived slugs that are missing.
+func mergeProjects(path string, archived []byte, v *vault.Vault) error {
+	current, err := store.ReadProjects(path, v)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	plain, err := v.Open(archived)
+	if err != nil {
+		return err
+	}
+	var old []model.Project
+	if err := json.Unmarshal(plain, &old); err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(current))
+	for _, p := range current {
+		known[p.Slug] = true
+	}
+	for _, p := range old {
+		if !known[p.Slug] {
+			known[p.Slug] = true
+			current = append(current, p)
+		}
+	}
+	sort.Slice(current, func(i, j int) bool { return current[i].Path < current[j].Path })
+	return store.WriteProjects(path, current, v)
+}
+
+func readTSV[T any](path string, v *vault.Vault, unmarshal func(string) (T, error)) ([]T, int, error) {
+	data, err := store.ReadFile(path, v)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, skipped := parseTSV(data, unmarshal)
+	return rows, skipped, nil
+}
+
+func parseTSV[T any](data []byte, unmarshal func(string) (T, error)) ([]T, int) {
+	lines := strings.Split(string(data), "\n")
+	var rows []T
+	skipped := 0
+	for i, line := range lines {
+		if i == 0 || strings.TrimSpace(line) == "" {
+			continue // header or blank
+		}
+		row, err := unmarshal(line)
+		if err != nil {
+			skipped++ // Malformed, as mergeEvents skips them
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, skipped
+}
@@ -0,0 +1,234 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+	internalSync "github.com/giannimassi/jevons/internal/sync"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	oldRow  = "1736935210\t2025-01-15T10:00:10Z\tproj\ts1\t100\t50\t20\t10\t150\t180\ttext\t100|50|20|10"
+	newRow  = "1736935270\t2025-01-15T10:01:10Z\tproj\ts1\t200\t150\t40\t15\t350\t405\ttext\t200|150|40|15"
+	oldLive = "1736935210\t2025-01-15T10:00:10Z\tproj\ts1\tHello\t100\t50\t20\t10\t150\t180\ttext\t100|50|20|10"
+)
+
+func writeDataRoot(t *testing.T, dir string, events []string, projects string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	content := store.EventsTSVHeader + "\n"
+	for _, r := range events {
+		content += r + "\n"
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "events.tsv"), []byte(content), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "live-events.tsv"), []byte(store.LiveEventsTSVHeader+"\n"+oldLive+"\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "projects.json"), []byte(projects), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "account.json"), []byte(`{"email":"old@example.com"}`), 0600))
+}
+
+func TestCreateOpenRoundTrip(t *testing.T) {
+	dataRoot := t.TempDir()
+	writeDataRoot(t, dataRoot, []string{oldRow}, `[{"slug":"proj","path":"/proj"}]`)
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	path, err := Create(dataRoot, DefaultDir(dataRoot), now)
+	require.NoError(t, err)
+	assert.Equal(t, "jevons-backup-20260301T120000Z.tar.gz", filepath.Base(path))
+
+	a, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, a.Manifest.FormatVersion)
+	assert.Len(t, a.Manifest.Files, 4, "events, live events, projects and account")
+	assert.Contains(t, string(a.Files["events.tsv"]), oldRow)
+}
+
+func TestCreateEmptyDataRoot(t *testing.T) {
+	dataRoot := t.TempDir()
+	_, err := Create(dataRoot, DefaultDir(dataRoot), time.Now())
+	assert.Error(t, err)
+}
+
+func writeArchive(t *testing.T, entries map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	path := filepath.Join(t.TempDir(), "archive.tar.gz")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+	return path
+}
+
+func TestOpenRejectsInvalidArchives(t *testing.T) {
+	goodManifest := `{"format_version":1,"files":[{"name":"events.tsv","size":3,"sha256":"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}]}`
+
+	tests := []struct {
+		name    string
+		entries map[string]string
+	}{
+		{name: "missing manifest", entries: map[string]string{"events.tsv": "abc"}},
+		{name: "checksum mismatch", entries: map[string]string{ManifestName: goodManifest, "events.tsv": "abd"}},
+		{name: "missing listed file", entries: map[string]string{ManifestName: goodManifest}},
+		{name: "path traversal", entries: map[string]string{ManifestName: goodManifest, "events.tsv": "abc", "../evil": "x"}},
+		{name: "unlisted file", entries: map[string]string{ManifestName: goodManifest, "events.tsv": "abc", "account.json": "{}"}},
+		{name: "future version", entries: map[string]string{ManifestName: `{"format_version":99,"files":[]}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(writeArchive(t, tt.entries))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := Open(writeArchive(t, map[string]string{ManifestName: goodManifest, "events.tsv": "abc"}))
+	assert.NoError(t, err, "sanity check: the valid variant opens")
+
+	notGzip := filepath.Join(t.TempDir(), "plain.tar.gz")
+	require.NoError(t, os.WriteFile(notGzip, []byte("not gzip"), 0600))
+	_, err = Open(notGzip)
+	assert.Error(t, err)
+}
+
+func TestRestoreMergesWithoutClobbering(t *testing.T) {
+	source := t.TempDir()
+	writeDataRoot(t, source, []string{oldRow}, `[{"slug":"gone","path":"/gone"},{"slug":"proj","path":"/old/proj"}]`)
+	path, err := Create(source, t.TempDir(), time.Now())
+	require.NoError(t, err)
+
+	target := t.TempDir()
+	writeDataRoot(t, target, []string{newRow}, `[{"slug":"proj","path":"/new/proj"}]`)
+	require.NoError(t, os.WriteFile(filepath.Join(target, "account.json"), []byte(`{"email":"new@example.com"}`), 0600))
+
+	a, err := Open(path)
+	require.NoError(t, err)
+	result, err := Restore(a, model.Config{DataRoot: target})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.EventsBefore)
+	assert.Equal(t, 2, result.EventsAfter)
+	assert.Equal(t, 1, result.EventsAdded)
+	assert.Contains(t, result.Kept, "account.json")
+
+	data, err := os.ReadFile(filepath.Join(target, "events.tsv"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, []string{store.EventsTSVHeader, oldRow, newRow}, lines, "merged and sorted by epoch")
+
+	events, err := store.ReadEvents(filepath.Join(target, "events.tsv"), nil)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(2), events[0].Seq, "restored event is ingested after existing ones")
+	assert.Equal(t, int64(1), events[1].Seq)
+
+	account, err := os.ReadFile(filepath.Join(target, "account.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(account), "new@example.com", "existing files are not clobbered")
+
+	projects, err := store.ReadProjects(filepath.Join(target, "projects.json"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Project{{Slug: "gone", Path: "/gone"}, {Slug: "proj", Path: "/new/proj"}}, projects)
+
+	// Restoring the same archive again is idempotent.
+	result, err = Restore(a, model.Config{DataRoot: target})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.EventsAdded)
+}
+
+func TestRestoreSkipsMalformedLiveRows(t *testing.T) {
+	source := t.TempDir()
+	writeDataRoot(t, source, []string{oldRow}, `[{"slug":"proj","path":"/proj"}]`)
+	path, err := Create(source, t.TempDir(), time.Now())
+	require.NoError(t, err)
+
+	target := t.TempDir()
+	writeDataRoot(t, target, []string{newRow}, `[{"slug":"proj","path":"/proj"}]`)
+	livePath := filepath.Join(target, "live-events.tsv")
+	require.NoError(t, os.WriteFile(livePath, []byte(store.LiveEventsTSVHeader+"\nnot\ta\trow\n"+oldLive+"\n"), 0600))
+
+	a, err := Open(path)
+	require.NoError(t, err)
+	result, err := Restore(a, model.Config{DataRoot: target})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.LiveSkipped)
+	assert.Equal(t, 1, result.EventsAdded, "events are still merged")
+
+	data, err := os.ReadFile(livePath)
+	require.NoError(t, err)
+	assert.Equal(t, store.LiveEventsTSVHeader+"\n"+oldLive+"\n", string(data))
+}
+
+func TestRestoredEventsSurviveSync(t *testing.T) {
+	sourceDir := filepath.Join(t.TempDir(), "source")
+	projectDir := filepath.Join(sourceDir, "-proj")
+	require.NoError(t, os.MkdirAll(projectDir, 0700))
+	session := func(name, ts string) string {
+		path := filepath.Join(projectDir, name+".jsonl")
+		line := `{"cwd":"/proj","type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"` + ts + `"}` + "\n"
+		require.NoError(t, os.WriteFile(path, []byte(line), 0600))
+		return path
+	}
+	old := session("s1", "2025-01-15T10:00:00.000Z")
+	session("s2", "2025-01-16T10:00:00.000Z")
+
+	cfg := model.Config{DataRoot: t.TempDir(), SourceDir: sourceDir}
+	_, err := internalSync.Run(cfg)
+	require.NoError(t, err)
+	path, err := Create(cfg.DataRoot, t.TempDir(), time.Now())
+	require.NoError(t, err)
+
+	// A fresh data root whose sources no longer have the old session.
+	require.NoError(t, os.Remove(old))
+	cfg.DataRoot = t.TempDir()
+	result, err := internalSync.Run(cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.EventRows)
+
+	a, err := Open(path)
+	require.NoError(t, err)
+	restored, err := Restore(a, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored.EventsAdded)
+
+	result, err = internalSync.Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.EventRows, "sync keeps restored events")
+	assert.Equal(t, 0, result.NewEvents)
+	events, err := store.ReadEvents(filepath.Join(cfg.DataRoot, "events.tsv"), nil)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "s1", events[0].SessionID)
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	for _, ts := range []string{"20260101T000000Z", "20260102T000000Z", "20260103T000000Z"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, filePrefix+ts+".tar.gz"), nil, 0600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unrelated.txt"), nil, 0600))
+
+	removed, err := Rotate(dir, 2)
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Contains(t, removed[0], "20260101")
+
+	left, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, left, 3, "two newest backups plus the unrelated file")
+}
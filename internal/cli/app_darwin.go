@@ -4,14 +4,15 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http/httputil"
-	"net/url"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"fyne.io/systray"
+	"github.com/giannimassi/jevons/internal/control"
 	"github.com/giannimassi/jevons/internal/daemon"
 	"github.com/giannimassi/jevons/internal/dashboard"
 	"github.com/giannimassi/jevons/internal/menubar"
@@ -35,29 +36,50 @@ func newAppCmd() *cobra.Command {
 	defaults := model.DefaultConfig()
 	var port int
 	var interval int
+	var watchFlag bool
 
 	cmd := &cobra.Command{
 		Use:   "app",
 		Short: "Start menu bar app with native webview dashboard",
 		Long:  "Start Jevons as a macOS menu bar app with a native webview window and background sync.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := model.DefaultConfig()
-			cfg.Port = port
-			cfg.Interval = interval
+			cfg, v, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("interval") && interval < 1 {
+				return fmt.Errorf("--interval must be a positive integer")
+			}
+			override := func(cfg *model.Config) {
+				if cmd.Flags().Changed("port") {
+					cfg.Port = port
+				}
+				if cmd.Flags().Changed("interval") {
+					cfg.Interval = interval
+				}
+				if cmd.Flags().Changed("watch") {
+					cfg.Watch = watchFlag
+				}
+			}
+			override(&cfg)
 
 			if err := daemon.EnsureDataDirs(cfg.DataRoot); err != nil {
 				return err
 			}
+			defer setupLogging(cfg, os.Stderr)()
 
 			// Initial sync
 			if _, err := internalSync.Run(cfg); err != nil {
-				fmt.Fprintf(os.Stderr, "initial sync warning: %v\n", err)
+				slog.Warn("initial sync failed", "err", err)
 			}
 
 			// Start HTTP server (goroutine)
 			srv := &dashboard.Server{
 				Port:     cfg.Port,
 				DataRoot: cfg.DataRoot,
+				Vault:    v,
+
+				MetricsProjects: cfg.Metrics.Projects,
 			}
 			if err := srv.Start(); err != nil {
 				return fmt.Errorf("start server: %w", err)
@@ -68,30 +90,35 @@ func newAppCmd() *cobra.Command {
 
 			// Start daemon (goroutine)
 			ctx, cancel := context.WithCancel(context.Background())
-			d := &daemon.Daemon{
-				Interval: cfg.Interval,
-				DataRoot: cfg.DataRoot,
-				SyncFn: func() error {
-					_, err := internalSync.Run(cfg)
-					return err
-				},
-			}
-			go d.Run(ctx)
+			d, s := newSyncDaemon(cfg)
+			defer s.Close()
+			s.override = override
 
-			// Create the app instance
+			// Create the app instance; manual syncs go through the daemon loop
 			app := &App{
 				ctxReady: make(chan struct{}),
 				syncFn: func() {
-					if _, err := internalSync.Run(cfg); err != nil {
-						fmt.Fprintf(os.Stderr, "manual sync error: %v\n", err)
-					}
+					// The daemon loop logs failed syncs.
+					_ = d.SyncNow(ctx)
 				},
 			}
-
-			// Create reverse proxy to the HTTP server
-			target, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", cfg.Port))
-			proxy := httputil.NewSingleHostReverseProxy(target)
-			proxy.FlushInterval = -1 // Immediate flush for SSE
+			if err := d.Acquire(); err != nil {
+				var running *daemon.RunningError
+				if !errors.As(err, &running) {
+					cancel()
+					return err
+				}
+				// Another process runs the sync loop; manual syncs go to it.
+				slog.Warn("sync loop already running; serving the dashboard only", "pid", running.PID)
+				app.syncFn = func() {
+					_ = control.Call(control.SocketPath(cfg.DataRoot), control.MethodSync, nil, nil)
+				}
+			} else {
+				go d.Run(ctx)
+				ctl := serveControl(d, s, func() { wailsruntime.Quit(app.ctx) })
+				defer ctl.Close()
+				s.reloadOnHangup(ctx, d)
+			}
 
 			// Register systray (non-blocking — works with Wails' Cocoa event loop)
 			systray.Register(func() {
@@ -127,7 +154,7 @@ func newAppCmd() *cobra.Command {
 					systray.Quit()
 				},
 				AssetServer: &assetserver.Options{
-					Handler: proxy,
+					Handler: dashboard.Proxy(cfg.Port),
 				},
 			}); err != nil {
 				return fmt.Errorf("wails error: %w", err)
@@ -139,6 +166,7 @@ func newAppCmd() *cobra.Command {
 
 	cmd.Flags().IntVar(&port, "port", defaults.Port, "HTTP server port")
 	cmd.Flags().IntVar(&interval, "interval", defaults.Interval, "Sync interval in seconds")
+	cmd.Flags().BoolVar(&watchFlag, "watch", defaults.Watch, "Also sync as soon as session files change")
 	return cmd
 }
 
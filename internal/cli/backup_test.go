@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRestoreCmdHelp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"backup mentions archive", []string{"backup", "--help"}, "tar.gz"},
+		{"backup dir flag", []string{"backup", "--help"}, "--dir"},
+		{"restore mentions merge", []string{"restore", "--help"}, "never clobbered"},
+		{"restore check flag", []string{"restore", "--help"}, "--check"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := NewRootCmd()
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+
+			require.NoError(t, cmd.Execute())
+			assert.Contains(t, buf.String(), tt.want)
+		})
+	}
+}
+
+func TestBackupAndRestoreCmd(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	header := "ts_epoch\tts_iso\tproject_slug\tsession_id\tinput\toutput\tcache_read\tcache_create\tbillable\ttotal_with_cache\tcontent_type\tsignature\n"
+	row := "1736937000\t2025-01-15T10:30:00Z\ttest\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig\n"
+	eventsPath := filepath.Join(tmpDir, "events.tsv")
+	require.NoError(t, os.WriteFile(eventsPath, []byte(header+row), 0600))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"backup"})
+		require.NoError(t, cmd.Execute())
+	})
+	m := regexp.MustCompile(`backup_ok path=(\S+)`).FindStringSubmatch(out)
+	require.Len(t, m, 2, "output: %s", out)
+	archive := m[1]
+
+	// Lose the events, then restore them.
+	require.NoError(t, os.WriteFile(eventsPath, []byte(header), 0600))
+
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"restore", "--check", archive})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "archive_ok")
+
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"restore", archive})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "events_added=1")
+
+	data, err := os.ReadFile(eventsPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), row)
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/control"
+	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/giannimassi/jevons/internal/hooks"
+)
+
+// syncReply is the result of the control sync method.
+type syncReply struct {
+	SessionFiles  int            `json:"session_files"`
+	EventRows     int            `json:"event_rows"`
+	LiveEventRows int            `json:"live_rows"`
+	NewEvents     int            `json:"new_events"`
+	SourceRoot    string         `json:"source_root"`
+	DurationMS    int64          `json:"duration_ms"`
+	Hooks         []hooks.Result `json:"hooks,omitempty"`
+}
+
+// stateReply is the result of the control state method.
+type stateReply struct {
+	daemon.Heartbeat
+	DataRoot  string `json:"data_root"`
+	SourceDir string `json:"source_dir"`
+	Watch     bool   `json:"watch"`
+	Uptime    int64  `json:"uptime_s"`
+}
+
+type intervalParams struct {
+	Seconds int `json:"seconds"`
+}
+
+// serveControl starts the control socket for d. shutdown stops the whole
+// process. A failure to listen is reported but doesn't stop the caller: the
+// process keeps syncing, just without remote control.
+func serveControl(d *daemon.Daemon, s *syncer, shutdown func()) *control.Server {
+	started := time.Now()
+	cfg := s.config()
+	srv := &control.Server{
+		Path: control.SocketPath(cfg.DataRoot),
+		Methods: map[string]control.Method{
+			control.MethodSync: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				if err := d.SyncNow(ctx); err != nil {
+					if errors.Is(err, daemon.ErrSkipped) {
+						return nil, err
+					}
+					return nil, fmt.Errorf("sync failed: %w", err)
+				}
+				r := s.lastResult()
+				return syncReply{
+					SessionFiles:  r.SessionFiles,
+					EventRows:     r.EventRows,
+					LiveEventRows: r.LiveEventRows,
+					NewEvents:     r.NewEvents,
+					SourceRoot:    r.SourceRoot,
+					DurationMS:    r.Duration.Milliseconds(),
+					Hooks:         r.Hooks,
+				}, nil
+			},
+			control.MethodState: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				hb, err := d.State()
+				if err != nil {
+					return nil, err
+				}
+				cfg := s.config()
+				return stateReply{
+					Heartbeat: hb,
+					DataRoot:  cfg.DataRoot,
+					SourceDir: cfg.SourceDir,
+					Watch:     s.watching(),
+					Uptime:    int64(time.Since(started).Seconds()),
+				}, nil
+			},
+			control.MethodSetInterval: func(ctx context.Context, params json.RawMessage) (any, error) {
+				var p intervalParams
+				if err := json.Unmarshal(params, &p); err != nil {
+					return nil, control.InvalidParams(err)
+				}
+				if p.Seconds < 1 {
+					return nil, control.InvalidParams(fmt.Errorf("seconds must be a positive integer, got %d", p.Seconds))
+				}
+				err := d.Update(ctx, func(d *daemon.Daemon) {
+					d.Interval = p.Seconds
+					d.Backoff.Base = time.Duration(p.Seconds) * time.Second
+					s.mu.Lock()
+					s.cfg.Interval = p.Seconds
+					s.mu.Unlock()
+				})
+				return p, err
+			},
+			control.MethodReload: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				return s.reload(ctx, d)
+			},
+			control.MethodShutdown: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				go shutdown()
+				return map[string]bool{"ok": true}, nil
+			},
+		},
+	}
+	if err := srv.Start(); err != nil {
+		slog.Warn("control socket disabled", "err", err)
+		return &control.Server{}
+	}
+	return srv
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/control"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startControlledDaemon runs a sync daemon with its control socket for the
+// data root in CLAUDE_USAGE_DATA_DIR, as jevons daemon run does.
+func startControlledDaemon(t *testing.T) (dataRoot string, done <-chan error) {
+	t.Helper()
+	// Short paths keep the socket under the platform limit.
+	dataRoot, err := os.MkdirTemp("", "jv")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dataRoot) })
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataRoot)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", t.TempDir())
+
+	cfg, err := model.LoadConfig()
+	require.NoError(t, err)
+	cfg.Interval = 3600
+	d, s := newSyncDaemon(cfg)
+	t.Cleanup(s.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctl := serveControl(d, s, cancel)
+	errc := make(chan error, 1)
+	stopped := make(chan struct{})
+	go func() {
+		err := d.Run(ctx)
+		ctl.Close()
+		errc <- err
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	require.Eventually(t, func() bool {
+		_, err := d.State()
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return dataRoot, errc
+}
+
+func TestControlSocketStatusAndSync(t *testing.T) {
+	dataRoot, _ := startControlledDaemon(t)
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"status"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "source=control interval=3600s")
+	assert.Contains(t, out, "control_socket="+control.SocketPath(dataRoot))
+
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"sync"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "sync_ok ")
+	assert.Contains(t, out, "via=daemon")
+
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"sync", "--no-daemon"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "sync_ok ")
+	assert.NotContains(t, out, "via=daemon")
+}
+
+func TestControlSyncReportsSkip(t *testing.T) {
+	dataRoot, _ := startControlledDaemon(t)
+
+	// Another process holds the DataRoot lock: the daemon's sync is skipped
+	// and reported as such, not as a fresh success.
+	lock, err := store.Lock(dataRoot, false)
+	require.NoError(t, err)
+	defer lock.Unlock()
+
+	var reply syncReply
+	err = control.Call(control.SocketPath(dataRoot), control.MethodSync, nil, &reply)
+	require.ErrorContains(t, err, "sync skipped: sync already in progress")
+
+	var state stateReply
+	require.NoError(t, control.Call(control.SocketPath(dataRoot), control.MethodState, nil, &state))
+	assert.Equal(t, "skipped", state.Status)
+	assert.Zero(t, state.ConsecutiveFailures)
+}
+
+func TestControlSocketMethods(t *testing.T) {
+	dataRoot, done := startControlledDaemon(t)
+	socket := control.SocketPath(dataRoot)
+
+	// Interval changes apply to the running loop.
+	require.NoError(t, control.Call(socket, control.MethodSetInterval, intervalParams{Seconds: 30}, nil))
+	var state stateReply
+	require.NoError(t, control.Call(socket, control.MethodState, nil, &state))
+	assert.Equal(t, 30, state.Interval)
+	assert.Equal(t, dataRoot, state.DataRoot)
+
+	err := control.Call(socket, control.MethodSetInterval, intervalParams{Seconds: 0}, nil)
+	var rpcErr *control.Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, control.CodeInvalidParams, rpcErr.Code)
+
+	// Reload picks up config.json; an invalid file is rejected.
+	configPath := filepath.Join(dataRoot, model.ConfigFileName)
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"interval": 45, "port": 9999}`), 0600))
+	var reply reloadReply
+	require.NoError(t, control.Call(socket, control.MethodReload, nil, &reply))
+	assert.Equal(t, []string{"interval: 30 -> 45"}, reply.Changes)
+	assert.Equal(t, []string{"port: 8765 -> 9999"}, reply.RestartRequired)
+	require.NoError(t, control.Call(socket, control.MethodState, nil, &state))
+	assert.Equal(t, 45, state.Interval)
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"workers": -1}`), 0600))
+	err = control.Call(socket, control.MethodReload, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "workers must not be negative")
+	require.NoError(t, control.Call(socket, control.MethodState, nil, &state))
+	assert.Equal(t, 45, state.Interval)
+
+	// Shutdown is acknowledged, then the loop exits and the socket goes away.
+	require.NoError(t, control.Call(socket, control.MethodShutdown, nil, nil))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not shut down")
+	}
+	err = control.Call(socket, control.MethodState, nil, nil)
+	assert.ErrorIs(t, err, control.ErrUnavailable)
+}
+
+func TestDaemonReloadCmd(t *testing.T) {
+	dataRoot, _ := startControlledDaemon(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, model.ConfigFileName),
+		[]byte(`{"interval": 60, "projects": {"exclude": ["*-scratch"]}}`), 0600))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"daemon", "reload"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "config_reloaded changes=2 restart_required=0")
+	assert.Contains(t, out, "changed interval: 3600 -> 60")
+	assert.Contains(t, out, `changed projects.exclude[0]: (unset) -> "*-scratch"`)
+
+	// A broken config.json still reaches the daemon, which rejects it.
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, model.ConfigFileName), []byte(`{`), 0600))
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"daemon", "reload"})
+	cmd.SilenceUsage = true
+	cmd.SetErr(new(bytes.Buffer))
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parse config.json")
+}
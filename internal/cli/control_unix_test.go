@@ -0,0 +1,53 @@
+//go:build unix
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/control"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadOnHangup(t *testing.T) {
+	dataRoot, err := os.MkdirTemp("", "jv")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dataRoot) })
+	sourceDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataRoot)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", sourceDir)
+
+	cfg, err := model.LoadConfig()
+	require.NoError(t, err)
+	d, s := newSyncDaemon(cfg)
+	t.Cleanup(s.Close)
+	logged := make(chan string, 10)
+	s.logf = func(format string, args ...any) {
+		logged <- format
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctl := serveControl(d, s, cancel)
+	defer ctl.Close()
+	go d.Run(ctx)
+	s.reloadOnHangup(ctx, d)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, model.ConfigFileName),
+		[]byte(`{"interval": 90, "watch": true}`), 0600))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	require.Eventually(t, func() bool {
+		var state stateReply
+		err := control.Call(control.SocketPath(dataRoot), control.MethodState, nil, &state)
+		return err == nil && state.Interval == 90 && state.Watch
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "received SIGHUP, reloading config", <-logged)
+}
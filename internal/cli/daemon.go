@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/control"
+	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// startupGrace is how long daemon start watches the new process before
+// reporting success, to catch one that exits straight away (bad config).
+const startupGrace = 500 * time.Millisecond
+
+func newDaemonCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run background sync as a detached process",
+		Long: "Start, stop and inspect a detached background sync loop. " +
+			"Its PID is kept in pids/sync.pid and its output goes to logs/sync.log under the data root.",
+	}
+	cmd.AddCommand(
+		newDaemonStartCmd(),
+		newDaemonStopCmd(),
+		newDaemonRestartCmd(),
+		newDaemonStatusCmd(),
+		newDaemonReloadCmd(),
+		newDaemonRunCmd(),
+	)
+	return cmd
+}
+
+// daemonFlags are the settings start and restart pass on to the detached
+// process. Only flags given on the command line are forwarded, so the
+// daemon otherwise follows config.json.
+type daemonFlags struct {
+	interval int
+	watch    bool
+}
+
+func (f *daemonFlags) register(cmd *cobra.Command) {
+	defaults := model.DefaultConfig()
+	cmd.Flags().IntVar(&f.interval, "interval", defaults.Interval, "Sync interval in seconds")
+	cmd.Flags().BoolVar(&f.watch, "watch", defaults.Watch, "Also sync as soon as session files change")
+}
+
+func (f *daemonFlags) args(cmd *cobra.Command) []string {
+	args := []string{"daemon", "run"}
+	if cmd.Flags().Changed("interval") {
+		args = append(args, "--interval", strconv.Itoa(f.interval))
+	}
+	if cmd.Flags().Changed("watch") {
+		args = append(args, "--watch="+strconv.FormatBool(f.watch))
+	}
+	return args
+}
+
+func newDaemonStartCmd() *cobra.Command {
+	var flags daemonFlags
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start the background sync daemon",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return startDaemon(cmd, &flags)
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
+
+func startDaemon(cmd *cobra.Command, flags *daemonFlags) error {
+	cfg, err := model.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("interval") {
+		if flags.interval < 1 {
+			return fmt.Errorf("--interval must be a positive integer")
+		}
+		cfg.Interval = flags.interval
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate jevons binary: %w", err)
+	}
+	pid, err := daemon.Spawn(cfg.DataRoot, exe, flags.args(cmd)...)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(startupGrace)
+	if !daemon.IsPIDRunning(pid) {
+		return fmt.Errorf("daemon exited during startup; see %s", daemon.LogPath(cfg.DataRoot))
+	}
+	fmt.Printf("Started daemon (pid=%d, interval=%ds)\n", pid, cfg.Interval)
+	fmt.Printf("Log: %s\n", daemon.LogPath(cfg.DataRoot))
+	return nil
+}
+
+func newDaemonStopCmd() *cobra.Command {
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the background sync daemon",
+		Long:  "Send SIGTERM to the daemon and wait for it to exit, killing it after --timeout.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			return stopDaemon(cfg, timeout)
+		},
+	}
+	cmd.Flags().DurationVar(&timeout, "timeout", daemon.DefaultStopTimeout, "How long to wait for a graceful exit before killing")
+	return cmd
+}
+
+func stopDaemon(cfg model.Config, timeout time.Duration) error {
+	pid, err := daemon.Stop(cfg.DataRoot, timeout)
+	switch {
+	case errors.Is(err, daemon.ErrNotRunning) && pid > 0:
+		fmt.Printf("Daemon not running (removed stale pid file, pid=%d)\n", pid)
+	case errors.Is(err, daemon.ErrNotRunning):
+		fmt.Println("Daemon not running")
+	case err != nil:
+		return err
+	default:
+		fmt.Printf("Stopped daemon (pid=%d)\n", pid)
+	}
+	return nil
+}
+
+func newDaemonRestartCmd() *cobra.Command {
+	var flags daemonFlags
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "restart",
+		Short: "Restart the background sync daemon",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			if err := stopDaemon(cfg, timeout); err != nil {
+				return err
+			}
+			return startDaemon(cmd, &flags)
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().DurationVar(&timeout, "timeout", daemon.DefaultStopTimeout, "How long to wait for a graceful exit before killing")
+	return cmd
+}
+
+func newDaemonStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the background sync daemon is running",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+
+			pid, alive := daemon.ReadPID(cfg.DataRoot)
+			switch {
+			case alive:
+				line := fmt.Sprintf("daemon_status=running pid=%d", pid)
+				if hb := daemon.ReadHeartbeatState(cfg.DataRoot); hb != nil {
+					line += fmt.Sprintf(" interval=%ds heartbeat_age=%ds status=%s", hb.Interval, hb.Age, hb.Status)
+					if hb.ConsecutiveFailures > 0 {
+						line += fmt.Sprintf(" failures=%d last_error=%q", hb.ConsecutiveFailures, hb.LastError)
+					}
+				}
+				fmt.Println(line)
+			case pid > 0:
+				fmt.Printf("daemon_status=stale-pid pid=%d\n", pid)
+			default:
+				fmt.Println("daemon_status=stopped")
+			}
+			fmt.Printf("daemon_log=%s\n", daemon.LogPath(cfg.DataRoot))
+			return nil
+		},
+	}
+}
+
+func newDaemonReloadCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reload",
+		Short: "Reload config.json in the running daemon",
+		Long: "Ask the running web, app or daemon process to re-read config.json over its control socket " +
+			"(sending it SIGHUP does the same). Changes apply without a restart; an invalid config is rejected " +
+			"and the process keeps its current settings.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Not LoadConfig: an invalid config.json should still reach the
+			// daemon, which rejects and logs it.
+			dataRoot := model.DefaultConfig().DataRoot
+			var reply reloadReply
+			if err := control.Call(control.SocketPath(dataRoot), control.MethodReload, nil, &reply); err != nil {
+				return fmt.Errorf("reload config: %w", err)
+			}
+			fmt.Printf("config_reloaded changes=%d restart_required=%d\n", len(reply.Changes), len(reply.RestartRequired))
+			for _, c := range reply.Changes {
+				fmt.Printf("changed %s\n", c)
+			}
+			for _, c := range reply.RestartRequired {
+				fmt.Printf("restart_required %s\n", c)
+			}
+			return nil
+		},
+	}
+}
+
+// newDaemonRunCmd is the foreground loop daemon start launches detached.
+func newDaemonRunCmd() *cobra.Command {
+	var interval int
+	var watchFlag bool
+	defaults := model.DefaultConfig()
+
+	cmd := &cobra.Command{
+		Use:    "run",
+		Short:  "Run the sync loop in the foreground (used by daemon start)",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			override := func(cfg *model.Config) {
+				if cmd.Flags().Changed("interval") {
+					cfg.Interval = interval
+				}
+				if cmd.Flags().Changed("watch") {
+					cfg.Watch = watchFlag
+				}
+			}
+			override(&cfg)
+			if err := daemon.EnsureDataDirs(cfg.DataRoot); err != nil {
+				return err
+			}
+			// Output on stderr already ends up in logs/sync.log.
+			defer setupLogging(cfg, nil)()
+
+			d, s := newSyncDaemon(cfg)
+			defer s.Close()
+			s.override = override
+			// Before the control socket, which would take over the running loop's.
+			if err := d.Acquire(); err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				sig := <-sigCh
+				slog.Info("stopping", "signal", sig.String())
+				cancel()
+			}()
+			ctl := serveControl(d, s, func() {
+				slog.Info("shutdown requested over the control socket")
+				cancel()
+			})
+			defer ctl.Close()
+			s.reloadOnHangup(ctx, d)
+
+			slog.Info("daemon started", "pid", os.Getpid(), "interval", cfg.Interval, "data_root", cfg.DataRoot)
+			err = d.Run(ctx)
+			slog.Info("daemon stopped", "pid", os.Getpid())
+			return err
+		},
+	}
+	cmd.Flags().IntVar(&interval, "interval", defaults.Interval, "Sync interval in seconds")
+	cmd.Flags().BoolVar(&watchFlag, "watch", defaults.Watch, "Also sync as soon as session files change")
+	return cmd
+}
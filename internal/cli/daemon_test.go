@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonCmdSubcommands(t *testing.T) {
+	cmd := NewRootCmd()
+	daemonCmd, _, err := cmd.Find([]string{"daemon"})
+	require.NoError(t, err)
+
+	names := map[string]bool{}
+	for _, sub := range daemonCmd.Commands() {
+		names[sub.Name()] = true
+	}
+	for _, name := range []string{"start", "stop", "restart", "status", "run"} {
+		assert.True(t, names[name], "daemon should have subcommand %q", name)
+	}
+
+	stop, _, err := cmd.Find([]string{"daemon", "stop"})
+	require.NoError(t, err)
+	assert.NotNil(t, stop.Flags().Lookup("timeout"))
+}
+
+func TestDaemonCmdStatusAndStopWhenStopped(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"daemon", "status"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "daemon_status=stopped")
+	assert.Contains(t, out, "daemon_log="+filepath.Join(tmpDir, "logs", "sync.log"))
+
+	// A PID file left by a crashed daemon is reported, then cleaned up by stop.
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "pids"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "pids", "sync.pid"), []byte("4194304"), 0600))
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"daemon", "status"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "daemon_status=stale-pid pid=4194304")
+
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"daemon", "stop"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "removed stale pid file")
+	assert.NoFileExists(t, filepath.Join(tmpDir, "pids", "sync.pid"))
+}
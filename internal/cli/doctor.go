@@ -5,7 +5,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
+	"github.com/giannimassi/jevons/internal/projectpath"
+	"github.com/giannimassi/jevons/internal/store"
+	internalSync "github.com/giannimassi/jevons/internal/sync"
+	"github.com/giannimassi/jevons/internal/vault"
 	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/spf13/cobra"
 )
@@ -18,8 +23,12 @@ func newDoctorCmd() *cobra.Command {
 		Short: "Check environment and dependencies",
 		Long:  "Run diagnostic checks on the environment and optionally fix issues.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := model.DefaultConfig()
 			coreOK := true
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				fmt.Printf("Config: [FAIL] %v\n", err)
+				coreOK = false
+			}
 
 			// Check source directory (CORE)
 			fmt.Printf("Source dir: %s\n", cfg.SourceDir)
@@ -36,7 +45,7 @@ func newDoctorCmd() *cobra.Command {
 			if info, err := os.Stat(cfg.DataRoot); err != nil || !info.IsDir() {
 				fmt.Println("  [WARN] Data directory does not exist")
 				if fix {
-					if err := os.MkdirAll(cfg.DataRoot, 0755); err != nil {
+					if err := os.MkdirAll(cfg.DataRoot, store.DirMode); err != nil {
 						fmt.Printf("  [FAIL] Could not create: %v\n", err)
 					} else {
 						fmt.Println("  [FIXED] Created data directory")
@@ -56,6 +65,81 @@ func newDoctorCmd() *cobra.Command {
 				coreOK = false
 			}
 
+			// Check project paths (CORE)
+			aliasesPath := filepath.Join(cfg.DataRoot, projectpath.AliasesFile)
+			if aliases, err := projectpath.LoadAliases(aliasesPath); err != nil {
+				fmt.Printf("  %s: [FAIL] %v\n", projectpath.AliasesFile, err)
+				coreOK = false
+			} else if len(aliases) > 0 {
+				fmt.Printf("  %s: %d aliases\n", projectpath.AliasesFile, len(aliases))
+			}
+			if v, err := vault.Load(cfg); err == nil {
+				if projects, err := store.ReadProjects(filepath.Join(cfg.DataRoot, "projects.json"), v); err == nil {
+					var unresolved []string
+					for _, p := range projects {
+						if strings.HasPrefix(p.Path, projectpath.UnknownPrefix) {
+							unresolved = append(unresolved, p.Slug)
+						}
+					}
+					if len(unresolved) > 0 {
+						fmt.Printf("  [WARN] %d projects without a known path (map them in %s): %s\n",
+							len(unresolved), projectpath.AliasesFile, strings.Join(unresolved, ", "))
+					}
+				}
+			}
+
+			// Explain project include/exclude rules (INFORMATIONAL)
+			if !cfg.Projects.Empty() {
+				fmt.Printf("Project rules: %d include, %d exclude patterns\n", len(cfg.Projects.Include), len(cfg.Projects.Exclude))
+				if decisions, err := internalSync.ProjectDecisions(cfg); err != nil {
+					fmt.Printf("  [FAIL] %v\n", err)
+					coreOK = false
+				} else {
+					tracked := 0
+					for _, d := range decisions {
+						if d.Included {
+							tracked++
+							continue
+						}
+						fmt.Printf("  excluded %s (%s): %s\n", d.Slug, d.Path, d.Reason)
+					}
+					fmt.Printf("  [OK] Tracking %d of %d projects\n", tracked, len(decisions))
+				}
+			}
+
+			// Check encryption (CORE)
+			if cfg.Encryption.Mode == model.EncryptionOff {
+				fmt.Println("Encryption: off")
+			} else {
+				fmt.Printf("Encryption: %s\n", cfg.Encryption.Mode)
+				if _, err := vault.Load(cfg); err != nil {
+					fmt.Printf("  [FAIL] Could not load key: %v\n", err)
+					coreOK = false
+				} else {
+					fmt.Println("  [OK] Key available")
+				}
+			}
+
+			// Check permissions (CORE)
+			fmt.Println("Permissions:")
+			loose := loosePermissions(cfg.DataRoot)
+			if len(loose) == 0 {
+				fmt.Println("  [OK] Data root is private")
+			}
+			for _, p := range loose {
+				fmt.Printf("  [WARN] %s is %04o (want %04o)\n", p.path, p.mode, p.want)
+				if fix {
+					if err := os.Chmod(p.path, p.want); err != nil {
+						fmt.Printf("  [FAIL] Could not chmod: %v\n", err)
+						coreOK = false
+					} else {
+						fmt.Printf("  [FIXED] %s set to %04o\n", p.path, p.want)
+					}
+				} else {
+					coreOK = false
+				}
+			}
+
 			// Check shell dependencies (INFORMATIONAL ONLY — not required by Go binary)
 			fmt.Println("\nOptional (legacy shell script only):")
 			for _, dep := range []string{"jq", "python3", "curl"} {
@@ -80,3 +164,35 @@ func newDoctorCmd() *cobra.Command {
 
 	return cmd
 }
+
+type loosePath struct {
+	path string
+	mode os.FileMode
+	want os.FileMode
+}
+
+// loosePermissions lists everything under dataRoot readable or writable by
+// group or others. A missing data root yields no findings.
+func loosePermissions(dataRoot string) []loosePath {
+	var loose []loosePath
+	filepath.WalkDir(dataRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		want := store.FileMode
+		if d.IsDir() {
+			want = store.DirMode
+		} else if !info.Mode().IsRegular() {
+			return nil
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			loose = append(loose, loosePath{path: path, mode: info.Mode().Perm(), want: want})
+		}
+		return nil
+	})
+	return loose
+}
@@ -70,9 +70,9 @@ func TestDoctorCmdWithExistingDirs(t *testing.T) {
 	sourceDir := filepath.Join(tmpDir, "source")
 	dataDir := filepath.Join(tmpDir, "data")
 	require.NoError(t, os.MkdirAll(sourceDir, 0755))
-	require.NoError(t, os.MkdirAll(dataDir, 0755))
+	require.NoError(t, os.MkdirAll(dataDir, 0700))
 	// Create events.tsv so all core checks pass
-	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "events.tsv"), []byte("header\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "events.tsv"), []byte("header\n"), 0600))
 
 	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataDir)
 	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", sourceDir)
@@ -108,3 +108,96 @@ func TestDoctorCmdFixCreatesDataDir(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, info.IsDir())
 }
+
+func TestDoctorCmdFlagsLoosePermissions(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+	require.NoError(t, os.MkdirAll(dataDir, 0755))
+	eventsPath := filepath.Join(dataDir, "events.tsv")
+	require.NoError(t, os.WriteFile(eventsPath, []byte("header\n"), 0644))
+	require.NoError(t, os.Chmod(dataDir, 0755))
+	require.NoError(t, os.Chmod(eventsPath, 0644))
+
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataDir)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", tmpDir)
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"doctor"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "events.tsv is 0644 (want 0600)")
+	assert.Contains(t, out, "Some checks failed")
+
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"doctor", "--fix"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "[FIXED]")
+
+	info, err := os.Stat(eventsPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
+
+func TestDoctorCmdReportsProjectPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+	require.NoError(t, os.MkdirAll(dataDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "events.tsv"), []byte("header\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "projects.json"),
+		[]byte(`[{"slug": "-gone", "path": "/unknown/-gone"}, {"slug": "-here", "path": "/here"}]`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "project-aliases.json"), []byte(`{"-old": "/new"}`), 0600))
+
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataDir)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", tmpDir)
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"doctor"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "project-aliases.json: 1 aliases")
+	assert.Contains(t, out, "1 projects without a known path (map them in project-aliases.json): -gone")
+	assert.Contains(t, out, "All checks passed")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "project-aliases.json"), []byte(`{"-old": "new"}`), 0600))
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"doctor"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "project-aliases.json: [FAIL]")
+	assert.Contains(t, out, "Some checks failed")
+}
+
+func TestDoctorCmdExplainsProjectRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+	sourceDir := filepath.Join(tmpDir, "source")
+	require.NoError(t, os.MkdirAll(dataDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "events.tsv"), []byte("header\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "config.json"),
+		[]byte(`{"projects": {"include": ["/work/**"], "exclude": ["*-nda-*"]}}`), 0600))
+	for slug, cwd := range map[string]string{"-work-app": "/work/app", "-work-nda-client": "/work/nda-client", "-tmp-scratch": "/tmp/scratch"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, slug), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, slug, "s.jsonl"), []byte(`{"cwd":"`+cwd+`"}`+"\n"), 0644))
+	}
+
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataDir)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", sourceDir)
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"doctor"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "Project rules: 1 include, 1 exclude patterns")
+	assert.Contains(t, out, `excluded -tmp-scratch (/tmp/scratch): matches no include pattern`)
+	assert.Contains(t, out, `excluded -work-nda-client (/work/nda-client): matches exclude "*-nda-*"`)
+	assert.Contains(t, out, "Tracking 1 of 3 projects")
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/giannimassi/jevons/internal/query"
+	"github.com/spf13/cobra"
+)
+
+func newEventsCmd() *cobra.Command {
+	var filter query.Filter
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Print events ingested after a cursor",
+		Long: "Print token events ingested after --after as JSON lines, in ingest order. " +
+			"Each event carries a stable id and its seq; pass the seq of the last line as the next --after to tail the store.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, v, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if filter.AfterSeq < 0 {
+				return fmt.Errorf("--after must not be negative")
+			}
+			if err := resolveFilter(&filter, cfg, v); err != nil {
+				return err
+			}
+
+			page, err := query.ReadFeed(filepath.Join(cfg.DataRoot, "events.tsv"), v, filter, limit)
+			if err != nil {
+				return fmt.Errorf("read events: %w", err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			for _, e := range page.Events {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Int64Var(&filter.AfterSeq, "after", 0, "Cursor: only print events with a higher seq")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Maximum number of events to print (0 = no limit)")
+	addFilterFlags(cmd, &filter)
+	return cmd
+}
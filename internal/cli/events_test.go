@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsCmdFlags(t *testing.T) {
+	t.Parallel()
+
+	cmd := newEventsCmd()
+	for _, name := range []string{"after", "limit", "project", "session", "model"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %q should exist", name)
+	}
+}
+
+func TestEventsCmdAfterCursor(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	events := []model.TokenEvent{
+		{TSEpoch: 1000, TSISO: "-", ProjectSlug: "p", SessionID: "s", ContentType: "text", Signature: "a", ID: "e1", Seq: 1},
+		{TSEpoch: 1001, TSISO: "-", ProjectSlug: "p", SessionID: "s", ContentType: "text", Signature: "b", ID: "e2", Seq: 2},
+		{TSEpoch: 1002, TSISO: "-", ProjectSlug: "p", SessionID: "s", ContentType: "text", Signature: "c", ID: "e3", Seq: 3},
+	}
+	require.NoError(t, store.WriteEventsTSV(filepath.Join(tmpDir, "events.tsv"), events, nil))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"events", "--after", "1", "--limit", "1"})
+		require.NoError(t, cmd.Execute())
+	})
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 1)
+	var got model.TokenEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	assert.Equal(t, "e2", got.ID)
+	assert.Equal(t, int64(2), got.Seq)
+}
+
+func TestEventsCmdEmptyStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"events"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Empty(t, out)
+}
@@ -4,11 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
-	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/giannimassi/jevons/internal/query"
 	"github.com/spf13/cobra"
 )
 
@@ -17,14 +16,21 @@ func newGraphCmd() *cobra.Command {
 	var rangeFlag string
 	var points int
 	var bucket int
+	var filter query.Filter
 
 	cmd := &cobra.Command{
 		Use:   "graph",
 		Short: "Display ASCII usage graph",
 		Long:  "Render an ASCII graph of token usage over time.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := model.DefaultConfig()
+			cfg, v, err := loadConfig()
+			if err != nil {
+				return err
+			}
 			eventsPath := filepath.Join(cfg.DataRoot, "events.tsv")
+			if err := resolveFilter(&filter, cfg, v); err != nil {
+				return err
+			}
 
 			if _, err := os.Stat(eventsPath); os.IsNotExist(err) {
 				return fmt.Errorf("no synced events found. Run: jevons sync")
@@ -34,38 +40,22 @@ func newGraphCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-
-			now := time.Now().Unix()
-			var cutoff int64
 			if rangeSec > 0 {
-				cutoff = now - rangeSec
+				filter.Since = time.Now().Unix() - rangeSec
 			}
 
-			events, err := readEventsFromTSV(eventsPath)
-			if err != nil {
+			series := query.NewSeries(metric, int64(bucket))
+			if err := query.Scan(eventsPath, v, filter, series); err != nil {
 				return fmt.Errorf("read events: %w", err)
 			}
-
-			buckets := make(map[int64]int64)
-			for _, e := range events {
-				if cutoff > 0 && e.TSEpoch < cutoff {
-					continue
-				}
-				val := metricValue(e, metric)
-				b := (e.TSEpoch / int64(bucket)) * int64(bucket)
-				buckets[b] += val
-			}
+			buckets := series.Buckets
 
 			if len(buckets) == 0 {
 				fmt.Println("No data in selected range.")
 				return nil
 			}
 
-			keys := make([]int64, 0, len(buckets))
-			for k := range buckets {
-				keys = append(keys, k)
-			}
-			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+			keys := series.Keys()
 
 			// Take last N points
 			if len(keys) > points {
@@ -97,25 +87,7 @@ func newGraphCmd() *cobra.Command {
 	cmd.Flags().StringVar(&rangeFlag, "range", "24h", "Time range (e.g., 1h, 24h, 7d)")
 	cmd.Flags().IntVar(&points, "points", 80, "Number of buckets to render")
 	cmd.Flags().IntVar(&bucket, "bucket", 900, "Bucket width in seconds")
+	addFilterFlags(cmd, &filter)
 
 	return cmd
 }
-
-func metricValue(e model.TokenEvent, metric string) int64 {
-	switch metric {
-	case "input":
-		return e.Input
-	case "output":
-		return e.Output
-	case "cache_read":
-		return e.CacheRead
-	case "cache_create":
-		return e.CacheCreate
-	case "billable":
-		return e.Billable
-	case "total_with_cache":
-		return e.TotalWithCache
-	default:
-		return e.Billable
-	}
-}
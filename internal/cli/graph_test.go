@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 	"testing"
 
-	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -97,36 +96,3 @@ func TestGraphCmdNoDataInRange(t *testing.T) {
 
 	assert.Contains(t, out, "No data")
 }
-
-func TestMetricValue(t *testing.T) {
-	t.Parallel()
-
-	e := model.TokenEvent{
-		Input:          100,
-		Output:         50,
-		CacheRead:      20,
-		CacheCreate:    10,
-		Billable:       150,
-		TotalWithCache: 180,
-	}
-
-	tests := []struct {
-		metric string
-		want   int64
-	}{
-		{"input", 100},
-		{"output", 50},
-		{"cache_read", 20},
-		{"cache_create", 10},
-		{"billable", 150},
-		{"total_with_cache", 180},
-		{"unknown_metric", 150}, // defaults to billable
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.metric, func(t *testing.T) {
-			t.Parallel()
-			assert.Equal(t, tt.want, metricValue(e, tt.metric))
-		})
-	}
-}
@@ -1,73 +1,114 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/giannimassi/jevons/internal/query"
 	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
 	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/spf13/cobra"
 )
 
-// rangeToSeconds converts a human-readable range string to seconds.
-func rangeToSeconds(r string) (int64, error) {
-	switch r {
-	case "1h":
-		return 3600, nil
-	case "3h":
-		return 10800, nil
-	case "6h":
-		return 21600, nil
-	case "12h":
-		return 43200, nil
-	case "24h":
-		return 86400, nil
-	case "30h":
-		return 108000, nil
-	case "48h":
-		return 172800, nil
-	case "7d":
-		return 604800, nil
-	case "14d":
-		return 1209600, nil
-	case "30d":
-		return 2592000, nil
-	case "all":
-		return 0, nil
-	default:
-		return 0, fmt.Errorf("unknown range: %s", r)
+// loadConfig loads the runtime config and the vault for its data root.
+func loadConfig() (model.Config, *vault.Vault, error) {
+	cfg, err := model.LoadConfig()
+	if err != nil {
+		return cfg, nil, err
 	}
-}
-
-// readEventsFromTSV reads all token events from a TSV file.
-func readEventsFromTSV(path string) ([]model.TokenEvent, error) {
-	f, err := os.Open(path)
+	v, err := vault.Load(cfg)
 	if err != nil {
-		return nil, err
+		return cfg, nil, fmt.Errorf("load encryption key: %w", err)
 	}
-	defer f.Close()
+	return cfg, v, nil
+}
 
-	var events []model.TokenEvent
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+// rangeToSeconds converts a human-readable range string to seconds.
+func rangeToSeconds(r string) (int64, error) {
+	return query.RangeSeconds(r)
+}
 
-	// Skip header
-	if scanner.Scan() {
-		// consumed
+// addFilterFlags registers the event filter flags shared by reporting commands.
+func addFilterFlags(cmd *cobra.Command, f *query.Filter) {
+	cmd.Flags().StringVar(&f.Project, "project", "", "Only count events for this project slug")
+	cmd.Flags().StringVar(&f.Session, "session", "", "Only count events for this session ID")
+	cmd.Flags().StringVar(&f.Model, "model", "", "Only count events for this model")
+	cmd.Flags().StringVar(&f.Account, "account", "", "Only count events for this account (UUID or email)")
+	cmd.Flags().StringVar(&f.Org, "org", "", "Only count events for this organization UUID")
+}
+
+// resolveFilter turns an email passed to --account into the account UUID
+// recorded in accounts.json.
+func resolveFilter(f *query.Filter, cfg model.Config, v *vault.Vault) error {
+	if !strings.Contains(f.Account, "@") {
+		return nil
+	}
+	accounts, err := store.ReadAccounts(filepath.Join(cfg.DataRoot, store.AccountsFile), v)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", store.AccountsFile, err)
+	}
+	uuid, ok := accounts.ResolveAccount(f.Account)
+	if !ok {
+		return fmt.Errorf("unknown account: %s", f.Account)
 	}
+	f.Account = uuid
+	return nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+// groupKey returns the event key for a --by dimension. "repo" groups project
+// slugs by the git repository recorded in projects.json, so worktrees and
+// subdirectories of one repository count together.
+func groupKey(by string, cfg model.Config, v *vault.Vault) (func(e model.TokenEvent) string, error) {
+	switch by {
+	case "project":
+		return func(e model.TokenEvent) string { return e.ProjectSlug }, nil
+	case "session":
+		return func(e model.TokenEvent) string { return e.SessionID }, nil
+	case "model":
+		return func(e model.TokenEvent) string { return e.Model }, nil
+	case "account":
+		return func(e model.TokenEvent) string { return e.Account }, nil
+	case "repo":
+		projects, err := store.ReadProjects(filepath.Join(cfg.DataRoot, "projects.json"), v)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read projects.json: %w", err)
 		}
-		event, err := store.UnmarshalTokenEvent(line)
-		if err != nil {
-			continue
+		repos := make(map[string]string, len(projects))
+		for _, p := range projects {
+			repos[p.Slug] = p.RepoKey()
 		}
-		events = append(events, event)
+		return func(e model.TokenEvent) string {
+			if r, ok := repos[e.ProjectSlug]; ok {
+				return r
+			}
+			return e.ProjectSlug
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown grouping: %s (want project, repo, session, model or account)", by)
 	}
+}
+
+// groupRow is one entry of a grouped report.
+type groupRow struct {
+	Key string `json:"key"`
+	query.Totals
+}
 
-	return events, scanner.Err()
+// sortedGroups flattens g, largest billable first.
+func sortedGroups(g *query.GroupBy) []groupRow {
+	rows := make([]groupRow, 0, len(g.Groups))
+	for k, t := range g.Groups {
+		rows = append(rows, groupRow{Key: k, Totals: *t})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Billable != rows[j].Billable {
+			return rows[i].Billable > rows[j].Billable
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
 }
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/logging"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// followPoll is how often jevons logs -f checks the log for new lines.
+const followPoll = 250 * time.Millisecond
+
+// setupLogging makes the rotated log file of cfg the default slog (and log)
+// output. Warnings and errors are also written to console, when not nil.
+// The returned func closes the file. If the log can't be opened the process
+// keeps logging to stderr.
+func setupLogging(cfg model.Config, console io.Writer) func() {
+	logger, closer, err := logging.New(cfg.Log, cfg.DataRoot, console)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logging to stderr: %v\n", err)
+		return func() {}
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	return func() {
+		slog.SetDefault(prev)
+		closer.Close()
+	}
+}
+
+func newLogsCmd() *cobra.Command {
+	var follow bool
+	var lines int
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Show the jevons log",
+		Long: "Print the last lines of the structured log the sync, daemon and dashboard write to " +
+			"logs/" + logging.File + " under the data root. With -f, keep printing new lines as they are written.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			path := logging.Path(cfg.DataRoot)
+			tail, err := logging.Tail(path, lines)
+			if os.IsNotExist(err) && !follow {
+				return fmt.Errorf("no log yet at %s", path)
+			}
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("read log: %w", err)
+			}
+			for _, line := range tail {
+				fmt.Println(line)
+			}
+			if !follow {
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			// Wait for a process to create the log, then print all of it.
+			created := false
+			for os.IsNotExist(err) {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(followPoll):
+				}
+				_, err = os.Stat(path)
+				created = true
+			}
+			return logging.Follow(ctx, path, os.Stdout, followPoll, created)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep printing new lines until interrupted")
+	cmd.Flags().IntVarP(&lines, "lines", "n", 50, "Number of lines to print first")
+	return cmd
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giannimassi/jevons/internal/logging"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogsCmd(t *testing.T) {
+	dataRoot := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataRoot)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", t.TempDir())
+
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"logs"})
+	cmd.SilenceUsage = true
+	cmd.SetErr(new(bytes.Buffer))
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no log yet")
+
+	// An idle sync only logs at debug level.
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, model.ConfigFileName),
+		[]byte(`{"log": {"level": "debug", "format": "json"}}`), 0600))
+	captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"sync", "--no-daemon"})
+		require.NoError(t, cmd.Execute())
+	})
+	_, err = os.Stat(logging.Path(dataRoot))
+	require.NoError(t, err)
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"logs", "-n", "1"})
+		require.NoError(t, cmd.Execute())
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"msg":"sync finished"`)
+	assert.Contains(t, lines[0], `"level":"DEBUG"`)
+}
@@ -27,6 +27,13 @@ func NewRootCmd() *cobra.Command {
 		newDoctorCmd(),
 		newTotalCmd(),
 		newGraphCmd(),
+		newEventsCmd(),
+		newVerifyCmd(),
+		newBackupCmd(),
+		newRestoreCmd(),
+		newDaemonCmd(),
+		newServiceCmd(),
+		newLogsCmd(),
 	)
 
 	root.Version = Version
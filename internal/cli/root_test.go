@@ -41,7 +41,7 @@ func TestRootCmdSubcommands(t *testing.T) {
 		subCmds[sub.Name()] = true
 	}
 
-	expected := []string{"sync", "web", "app", "status", "doctor", "total", "graph"}
+	expected := []string{"sync", "web", "app", "status", "doctor", "total", "graph", "events", "verify", "backup", "restore", "daemon", "service", "logs"}
 	for _, name := range expected {
 		assert.True(t, subCmds[name], "root should have subcommand %q", name)
 	}
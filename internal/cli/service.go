@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/giannimassi/jevons/internal/service"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+func newServiceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Run jevons as a login service",
+		Long: "Install, remove and inspect a per-user service that keeps jevons syncing after login: " +
+			"systemd user units on Linux, launchd agents on macOS.",
+	}
+	cmd.AddCommand(newServiceInstallCmd(), newServiceUninstallCmd(), newServiceStatusCmd())
+	return cmd
+}
+
+func newServiceInstallCmd() *cobra.Command {
+	defaults := model.DefaultConfig()
+	var interval, port int
+	var web, socket, noVerify bool
+	var verifyTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install and start the sync service",
+		Long: "Write a systemd user unit (Linux) or launchd agent (macOS) that runs the sync daemon for this data root, " +
+			"start it, and wait for a healthy heartbeat. --web adds a dashboard service; --socket (systemd only) " +
+			"starts the dashboard on its first connection instead.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("interval") {
+				cfg.Interval = interval
+			}
+			if cmd.Flags().Changed("port") {
+				cfg.Port = port
+			}
+			if cfg.Interval < 1 {
+				return fmt.Errorf("the service needs a positive sync interval, got %d", cfg.Interval)
+			}
+
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("locate jevons binary: %w", err)
+			}
+			dataRoot, err := filepath.Abs(cfg.DataRoot)
+			if err != nil {
+				return err
+			}
+			if err := daemon.EnsureDataDirs(dataRoot); err != nil {
+				return err
+			}
+
+			m, err := service.NewManager()
+			if err != nil {
+				return err
+			}
+			spec := service.Spec{
+				Exe:       exe,
+				DataRoot:  dataRoot,
+				SourceDir: os.Getenv("CLAUDE_USAGE_SOURCE_DIR"),
+				Interval:  cfg.Interval,
+				Port:      cfg.Port,
+				Web:       web || socket,
+				Socket:    socket,
+			}
+
+			started := time.Now()
+			files, err := m.Install(spec)
+			for _, f := range files {
+				fmt.Printf("Wrote %s\n", f.Path)
+			}
+			if err != nil {
+				return fmt.Errorf("install service: %w", err)
+			}
+			if noVerify {
+				return nil
+			}
+
+			fmt.Printf("Waiting up to %s for a healthy heartbeat...\n", verifyTimeout)
+			hb, err := m.WaitHealthy(dataRoot, started, verifyTimeout)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("service_status=healthy pid=%s interval=%ds status=%s\n", hb.PID, hb.Interval, hb.Status)
+			if spec.Web {
+				fmt.Printf("Dashboard URL: http://127.0.0.1:%d/dashboard/index.html\n", cfg.Port)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&interval, "interval", defaults.Interval, "Sync interval in seconds")
+	cmd.Flags().IntVar(&port, "port", defaults.Port, "Dashboard port for --web/--socket")
+	cmd.Flags().BoolVar(&web, "web", false, "Also run the dashboard server")
+	cmd.Flags().BoolVar(&socket, "socket", false, "Start the dashboard on its first connection (systemd socket unit)")
+	cmd.Flags().BoolVar(&noVerify, "no-verify", false, "Don't wait for the service's first heartbeat")
+	cmd.Flags().DurationVar(&verifyTimeout, "verify-timeout", service.DefaultVerifyWait, "How long to wait for a healthy heartbeat")
+	return cmd
+}
+
+func newServiceUninstallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall",
+		Short: "Stop and remove the sync service",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := service.NewManager()
+			if err != nil {
+				return err
+			}
+			removed, err := m.Uninstall()
+			if err != nil {
+				return err
+			}
+			if len(removed) == 0 {
+				fmt.Println("No jevons services installed")
+			}
+			for _, path := range removed {
+				fmt.Printf("Removed %s\n", path)
+			}
+			return nil
+		},
+	}
+}
+
+func newServiceStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the sync service is installed and healthy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			m, err := service.NewManager()
+			if err != nil {
+				return err
+			}
+			installed, err := m.Installed()
+			if err != nil {
+				return err
+			}
+
+			if len(installed) == 0 {
+				fmt.Println("service_status=not-installed")
+			}
+			for _, path := range installed {
+				fmt.Printf("service_unit=%s active=%t path=%s\n", filepath.Base(path), m.Active(path), path)
+			}
+			if hb := daemon.ReadHeartbeatState(cfg.DataRoot); hb != nil {
+				fmt.Printf("sync_heartbeat=%s pid=%s age=%ds status=%s\n", hb.Mode, hb.PID, hb.Age, hb.Status)
+			} else {
+				fmt.Println("sync_heartbeat=none")
+			}
+			return nil
+		},
+	}
+}
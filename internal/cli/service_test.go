@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceCmdFlags(t *testing.T) {
+	cmd := NewRootCmd()
+	install, _, err := cmd.Find([]string{"service", "install"})
+	require.NoError(t, err)
+	for _, name := range []string{"interval", "port", "web", "socket", "no-verify", "verify-timeout"} {
+		assert.NotNil(t, install.Flags().Lookup(name), "install should have --%s", name)
+	}
+	for _, sub := range []string{"uninstall", "status"} {
+		_, _, err := cmd.Find([]string{"service", sub})
+		assert.NoError(t, err)
+	}
+}
+
+func TestServiceCmdStatusNotInstalled(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("no supported service manager")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", t.TempDir())
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"service", "status"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, "service_status=not-installed")
+	assert.Contains(t, out, "sync_heartbeat=none")
+}
@@ -1,27 +1,49 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/giannimassi/jevons/internal/control"
 	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/giannimassi/jevons/internal/dashboard"
 	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/spf13/cobra"
 )
 
 func newStatusCmd() *cobra.Command {
-	return &cobra.Command{
+	var port int
+
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show sync and server status",
-		Long:  "Display the current state of the sync daemon and web server.",
+		Long: "Display the current state of the sync daemon and web server. A running web, app or daemon " +
+			"is asked over its control socket; otherwise the heartbeat file is read. The web server is " +
+			"probed on its /healthz and /readyz endpoints.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := model.DefaultConfig()
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("port") {
+				cfg.Port = port
+			}
 
-			// Sync status
-			hb := daemon.ReadHeartbeatState(cfg.DataRoot)
-			if hb != nil && hb.Mode == "running" {
+			// Sync status, live from the running process when it answers on
+			// the control socket, otherwise from the heartbeat file
+			var hb *daemon.HeartbeatState
+			var state stateReply
+			socket := control.SocketPath(cfg.DataRoot)
+			if err := control.Call(socket, control.MethodState, nil, &state); err == nil {
+				hb = state.Heartbeat.State()
+				fmt.Printf("sync_status=running pid=%s source=control interval=%ds status=%s watch=%t uptime=%ds\n",
+					hb.PID, hb.Interval, hb.Status, state.Watch, state.Uptime)
+				fmt.Printf("control_socket=%s\n", socket)
+			} else if hb = daemon.ReadHeartbeatState(cfg.DataRoot); hb != nil && hb.Mode == "running" {
 				fmt.Printf("sync_status=running pid=%s source=heartbeat age=%ds interval=%ds status=%s\n",
 					hb.PID, hb.Age, hb.Interval, hb.Status)
 			} else if daemon.IsSyncRunning(cfg.DataRoot) {
@@ -30,14 +52,23 @@ func newStatusCmd() *cobra.Command {
 				fmt.Println("sync_status=stopped")
 			}
 
-			// Heartbeat
-			hbPath := filepath.Join(cfg.DataRoot, "heartbeat", "sync.txt")
-			if data, err := os.ReadFile(hbPath); err == nil {
-				fmt.Printf("sync_heartbeat=%s\n", strings.TrimSpace(string(data)))
-			} else {
-				fmt.Println("sync_heartbeat=none")
+			if hb != nil && !hb.Legacy {
+				nextAttempt := "none"
+				if hb.NextAttemptEpoch > 0 {
+					nextAttempt = time.Unix(hb.NextAttemptEpoch, 0).UTC().Format(time.RFC3339)
+				}
+				fmt.Printf("sync_failures=%d last_error=%q next_attempt=%s\n", hb.ConsecutiveFailures, hb.LastError, nextAttempt)
+				lastSuccess := "never"
+				if hb.LastSuccessEpoch > 0 {
+					lastSuccess = time.Unix(hb.LastSuccessEpoch, 0).UTC().Format(time.RFC3339)
+				}
+				fmt.Printf("sync_last_success=%s duration_ms=%d version=%s host=%s\n",
+					lastSuccess, hb.DurationMS, orNone(hb.Version), orNone(hb.Host))
 			}
 
+			// Heartbeat
+			fmt.Printf("sync_heartbeat=%s\n", rawHeartbeat(cfg.DataRoot))
+
 			// Last sync status
 			statusPath := filepath.Join(cfg.DataRoot, "sync-status.json")
 			if data, err := os.ReadFile(statusPath); err == nil {
@@ -46,10 +77,49 @@ func newStatusCmd() *cobra.Command {
 				fmt.Println("sync_last_status_json=none")
 			}
 
+			printWebStatus(cmd.Context(), cfg.Port)
+
 			// Events file path
 			fmt.Printf("events_file=%s\n", filepath.Join(cfg.DataRoot, "events.tsv"))
 
 			return nil
 		},
 	}
+
+	cmd.Flags().IntVar(&port, "port", model.DefaultConfig().Port, "Port the web server listens on")
+	return cmd
+}
+
+// printWebStatus probes the dashboard on port: running when /healthz
+// answers, with readiness and the failing checks from /readyz.
+func printWebStatus(ctx context.Context, port int) {
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	health, ready, err := dashboard.Probe(ctx, base)
+	if health == nil {
+		fmt.Printf("web_status=stopped port=%d\n", port)
+		return
+	}
+	fmt.Printf("web_status=running pid=%d port=%d url=%s/dashboard/index.html uptime=%ds ready=%t\n",
+		health.PID, port, base, health.Uptime, ready != nil && ready.Ready)
+	switch {
+	case err != nil:
+		fmt.Printf("web_not_ready=%q\n", err.Error())
+	case !ready.Ready:
+		fmt.Printf("web_not_ready=%q\n", ready.Failed())
+	}
+}
+
+// rawHeartbeat returns the content of the newer heartbeat file on one line.
+func rawHeartbeat(dataRoot string) string {
+	if _, raw := daemon.ReadHeartbeat(dataRoot); raw != nil {
+		return strings.TrimSpace(string(raw))
+	}
+	return "none"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
 }
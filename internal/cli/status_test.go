@@ -2,10 +2,16 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/giannimassi/jevons/internal/dashboard"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -74,3 +80,88 @@ func TestStatusCmdWithSyncStatus(t *testing.T) {
 	assert.Contains(t, out, "sync_last_status_json=")
 	assert.Contains(t, out, "last_sync")
 }
+
+func TestStatusCmdHeartbeatDetails(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	hbDir := filepath.Join(tmpDir, "heartbeat")
+	require.NoError(t, os.MkdirAll(hbDir, 0755))
+	hb := fmt.Sprintf(`{"epoch":%d,"interval":60,"pid":%d,"status":"error","last_error":"parse failed","consecutive_failures":2,"last_success_epoch":1700000000,"duration_ms":42,"version":"v1.2.3","host":"box"}`,
+		time.Now().Unix(), os.Getpid())
+	require.NoError(t, os.WriteFile(filepath.Join(hbDir, "sync.json"), []byte(hb+"\n"), 0644))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"status"})
+		require.NoError(t, cmd.Execute())
+	})
+
+	assert.Contains(t, out, "sync_status=running")
+	assert.Contains(t, out, "status=error")
+	assert.Contains(t, out, `sync_failures=2 last_error="parse failed"`)
+	assert.Contains(t, out, "sync_last_success=2023-11-14T22:13:20Z duration_ms=42 version=v1.2.3 host=box")
+	assert.Contains(t, out, "sync_heartbeat="+hb)
+}
+
+func TestStatusCmdLegacyHeartbeat(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	hbDir := filepath.Join(tmpDir, "heartbeat")
+	require.NoError(t, os.MkdirAll(hbDir, 0755))
+	hb := fmt.Sprintf("%d,60,%d,ok", time.Now().Unix(), os.Getpid())
+	require.NoError(t, os.WriteFile(filepath.Join(hbDir, "sync.txt"), []byte(hb+"\n"), 0644))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"status"})
+		require.NoError(t, cmd.Execute())
+	})
+
+	assert.Contains(t, out, "sync_status=running")
+	assert.Contains(t, out, "sync_heartbeat="+hb)
+	assert.NotContains(t, out, "sync_failures=")
+}
+
+func TestStatusCmdWebProbe(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+	hbDir := filepath.Join(tmpDir, "heartbeat")
+	require.NoError(t, os.MkdirAll(hbDir, 0755))
+	now := time.Now().Unix()
+	hb := fmt.Sprintf(`{"epoch":%d,"interval":60,"pid":%d,"status":"ok","consecutive_failures":0,"last_success_epoch":%d,"duration_ms":1}`,
+		now, os.Getpid(), now)
+	require.NoError(t, os.WriteFile(filepath.Join(hbDir, "sync.json"), []byte(hb), 0644))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	srv := &dashboard.Server{DataRoot: tmpDir, Listener: ln}
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+
+	status := func() string {
+		return captureStdout(t, func() {
+			cmd := NewRootCmd()
+			cmd.SetArgs([]string{"status", "--port", strconv.Itoa(port)})
+			require.NoError(t, cmd.Execute())
+		})
+	}
+
+	// Nothing synced yet: up, but not ready.
+	out := status()
+	assert.Contains(t, out, fmt.Sprintf("web_status=running pid=%d port=%d", os.Getpid(), port))
+	assert.Contains(t, out, "ready=false")
+	assert.Contains(t, out, `web_not_ready="data: events.tsv`)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "events.tsv"), []byte("ts_epoch\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "projects.json"), []byte("[]"), 0644))
+	out = status()
+	assert.Contains(t, out, "ready=true")
+	assert.NotContains(t, out, "web_not_ready")
+
+	require.NoError(t, srv.Stop(context.Background()))
+	out = status()
+	assert.Contains(t, out, fmt.Sprintf("web_status=stopped port=%d", port))
+}
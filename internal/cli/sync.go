@@ -1,27 +1,188 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 
+	"github.com/giannimassi/jevons/internal/control"
+	"github.com/giannimassi/jevons/internal/hooks"
+	"github.com/giannimassi/jevons/internal/store"
 	internalSync "github.com/giannimassi/jevons/internal/sync"
 	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/spf13/cobra"
 )
 
 func newSyncCmd() *cobra.Command {
-	return &cobra.Command{
+	var workers int
+	var wait, noDaemon bool
+	var dryRun, rows, asJSON, exitCode bool
+
+	cmd := &cobra.Command{
 		Use:   "sync",
 		Short: "Sync session logs into event stores",
-		Long:  "Read AI session JSONL files, extract token events, deduplicate, and write to TSV event stores.",
+		Long: "Read AI session JSONL files, extract token events, deduplicate, and write to TSV event stores. " +
+			"When a web, app or daemon process is running, the sync runs inside it (see --no-daemon).",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := model.DefaultConfig()
-			result, err := internalSync.Run(cfg)
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("workers") {
+				if workers < 0 {
+					return fmt.Errorf("--workers must not be negative")
+				}
+				cfg.Workers = workers
+			}
+			if dryRun {
+				// A diff under --exit-code is a result, not a usage mistake.
+				cmd.SilenceUsage = true
+				return runDryRun(cfg, rows, asJSON, exitCode)
+			}
+			if rows || asJSON || exitCode {
+				return fmt.Errorf("--rows, --json and --exit-code require --dry-run")
+			}
+			defer setupLogging(cfg, nil)()
+			if !noDaemon {
+				var reply syncReply
+				err := control.Call(control.SocketPath(cfg.DataRoot), control.MethodSync, nil, &reply)
+				if err == nil {
+					fmt.Printf("sync_ok session_files=%d event_rows=%d live_rows=%d source_root=%s via=daemon\n",
+						reply.SessionFiles, reply.EventRows, reply.LiveEventRows, reply.SourceRoot)
+					printHookWarnings(reply.Hooks)
+					return nil
+				}
+				if !errors.Is(err, control.ErrUnavailable) {
+					return fmt.Errorf("sync via daemon: %w", err)
+				}
+			}
+			result, err := internalSync.RunWith(cfg, internalSync.Options{Wait: wait})
+			if errors.Is(err, store.ErrLocked) {
+				return fmt.Errorf("%w; rerun with --wait to wait for it", err)
+			}
 			if err != nil {
 				return fmt.Errorf("sync failed: %w", err)
 			}
 			fmt.Printf("sync_ok session_files=%d event_rows=%d live_rows=%d source_root=%s\n",
 				result.SessionFiles, result.EventRows, result.LiveEventRows, result.SourceRoot)
+			printHookWarnings(result.Hooks)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&workers, "workers", 0, "Session files to parse concurrently (0 = one per CPU)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for a sync already in progress instead of failing")
+	cmd.Flags().BoolVar(&noDaemon, "no-daemon", false, "Sync in this process even when a daemon is running")
+	cmd.AddCommand(newSyncHistoryCmd())
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report how a sync would change events.tsv without writing anything")
+	cmd.Flags().BoolVar(&rows, "rows", false, "With --dry-run, also print the differing rows")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "With --dry-run, print the diff as JSON")
+	cmd.Flags().BoolVar(&exitCode, "exit-code", false, "With --dry-run, exit non-zero when a sync would change anything")
+	return cmd
+}
+
+func printHookWarnings(results []hooks.Result) {
+	for _, h := range results {
+		if !h.OK {
+			fmt.Fprintf(os.Stderr, "hook warning: %s: %s\n", h.Name, h.Error)
+		}
+	}
+}
+
+// errDryRunDiff is returned by --dry-run --exit-code when a sync would change
+// events.tsv, so CI jobs fail on unexpected differences.
+var errDryRunDiff = errors.New("dry run: sync would change events.tsv")
+
+func runDryRun(cfg model.Config, rows, asJSON, exitCode bool) error {
+	diff, err := internalSync.DryRun(cfg, internalSync.Options{}, rows)
+	if err != nil {
+		return fmt.Errorf("dry run failed: %w", err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("dry_run added=%d removed=%d changed=%d unchanged=%d %s\n",
+			diff.Added, diff.Removed, diff.Changed, diff.Unchanged, formatTokenDelta(diff.Tokens))
+		for _, g := range diff.Groups {
+			fmt.Printf("diff project=%s session=%s added=%d removed=%d changed=%d %s\n",
+				g.Project, g.Session, g.Added, g.Removed, g.Changed, formatTokenDelta(g.Tokens))
+		}
+		for _, r := range diff.Rows {
+			if r.Old != nil {
+				fmt.Printf("-\t%s\n", store.MarshalTokenEvent(*r.Old))
+			}
+			if r.New != nil {
+				fmt.Printf("+\t%s\n", store.MarshalTokenEvent(*r.New))
+			}
+		}
+	}
+
+	if exitCode && !diff.Empty() {
+		return errDryRunDiff
+	}
+	return nil
+}
+
+func formatTokenDelta(t internalSync.TokenDelta) string {
+	return fmt.Sprintf("input=%+d output=%+d cache_read=%+d cache_create=%+d billable=%+d total_with_cache=%+d",
+		t.Input, t.Output, t.CacheRead, t.CacheCreate, t.Billable, t.TotalWithCache)
+}
+
+func newSyncHistoryCmd() *cobra.Command {
+	var limit int
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show recent sync runs, trends and anomalies",
+		Long:  "Summarize sync-history.jsonl: run durations, files scanned and changed, events added and failures, flagging slow runs, ingest bursts, shrinking stores and daemon restarts.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit < 0 {
+				return fmt.Errorf("--limit must not be negative")
+			}
+			cfg, err := model.LoadConfig()
+			if err != nil {
+				return err
+			}
+			records, err := internalSync.ReadHistory(cfg.DataRoot)
+			if err != nil {
+				return fmt.Errorf("read sync history: %w", err)
+			}
+			summary := internalSync.AnalyzeHistory(records)
+			recent := records[max(0, len(records)-limit):]
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]any{"summary": summary, "runs": recent})
+			}
+
+			fmt.Printf("history runs=%d failures=%d failure_streak=%d median_ms=%d p95_ms=%d max_ms=%d events_added=%d median_events_added=%d\n",
+				summary.Runs, summary.Failures, summary.FailureStreak, summary.MedianDurationMS, summary.P95DurationMS,
+				summary.MaxDurationMS, summary.EventsAdded, summary.MedianEventsAdded)
+			for _, r := range recent {
+				fmt.Printf("run time=%s ok=%t duration_ms=%d files_scanned=%d files_changed=%d events_added=%d event_rows=%d pid=%d daemon=%t",
+					r.Time, r.OK, r.DurationMS, r.FilesScanned, r.FilesChanged, r.EventsAdded, r.EventRows, r.PID, r.Daemon)
+				if r.Error != "" {
+					fmt.Printf(" error=%q", r.Error)
+				}
+				fmt.Println()
+			}
+			for _, a := range summary.Anomalies {
+				fmt.Printf("anomaly time=%s kind=%s detail=%q\n", a.Time, a.Kind, a.Detail)
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().IntVar(&limit, "limit", 20, "Number of most recent runs to list")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the summary and runs as JSON")
+	return cmd
 }
@@ -2,8 +2,13 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/giannimassi/jevons/internal/store"
+	internalSync "github.com/giannimassi/jevons/internal/sync"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -37,10 +42,142 @@ func TestSyncCmdHelp(t *testing.T) {
 	}
 }
 
-func TestSyncCmdNoFlags(t *testing.T) {
+func TestSyncCmdFlags(t *testing.T) {
 	t.Parallel()
 
 	cmd := newSyncCmd()
-	// sync has no custom flags
-	assert.Empty(t, cmd.Flags().FlagUsages())
+	f := cmd.Flags().Lookup("workers")
+	require.NotNil(t, f)
+	assert.Equal(t, "0", f.DefValue)
+
+	f = cmd.Flags().Lookup("wait")
+	require.NotNil(t, f)
+	assert.Equal(t, "false", f.DefValue)
+
+	f = cmd.Flags().Lookup("no-daemon")
+	require.NotNil(t, f)
+	assert.Equal(t, "false", f.DefValue)
+}
+
+func TestSyncCmdAlreadyInProgress(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", t.TempDir())
+
+	held, err := store.Lock(tmpDir, false)
+	require.NoError(t, err)
+	defer held.Unlock()
+
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"sync"})
+	cmd.SilenceUsage = true
+	cmd.SetErr(new(bytes.Buffer))
+	err = cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sync already in progress")
+	assert.Contains(t, err.Error(), "--wait")
+}
+
+func TestSyncCmdDryRun(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	sourceDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataDir)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", sourceDir)
+
+	projectDir := filepath.Join(sourceDir, "-Users-test-proj")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	session := `{"type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-15T10:00:00.000Z"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "s1.jsonl"), []byte(session), 0644))
+
+	run := func(args ...string) (string, error) {
+		var err error
+		out := captureStdout(t, func() {
+			cmd := NewRootCmd()
+			cmd.SetArgs(append([]string{"sync"}, args...))
+			cmd.SilenceUsage = true
+			cmd.SetErr(new(bytes.Buffer))
+			err = cmd.Execute()
+		})
+		return out, err
+	}
+
+	out, err := run("--dry-run", "--rows")
+	require.NoError(t, err)
+	assert.Contains(t, out, "dry_run added=1 removed=0 changed=0 unchanged=0")
+	assert.Contains(t, out, "diff project=-Users-test-proj session=s1 added=1")
+	assert.Contains(t, out, "billable=+15")
+	assert.Contains(t, out, "+\t1736935200\t")
+	_, err = os.Stat(dataDir)
+	assert.True(t, os.IsNotExist(err), "dry run writes nothing")
+
+	_, err = run("--dry-run", "--exit-code")
+	require.ErrorIs(t, err, errDryRunDiff)
+
+	// CI logs get the diff and the error, not the usage text.
+	output := new(bytes.Buffer)
+	captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"sync", "--dry-run", "--exit-code"})
+		cmd.SetOut(output) // Where cobra prints the usage
+		cmd.SetErr(output)
+		err = cmd.Execute()
+	})
+	require.ErrorIs(t, err, errDryRunDiff)
+	assert.Contains(t, output.String(), errDryRunDiff.Error())
+	assert.NotContains(t, output.String(), "Usage:")
+
+	_, err = run()
+	require.NoError(t, err)
+
+	out, err = run("--dry-run", "--json", "--exit-code")
+	require.NoError(t, err)
+	var diff internalSync.Diff
+	require.NoError(t, json.Unmarshal([]byte(out), &diff))
+	assert.Equal(t, 1, diff.Unchanged)
+	assert.True(t, diff.Empty())
+
+	_, err = run("--rows")
+	assert.ErrorContains(t, err, "require --dry-run")
+}
+
+func TestSyncHistoryCmd(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataDir)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", t.TempDir())
+
+	var lines []byte
+	for i := 0; i < 6; i++ {
+		lines = append(lines, []byte(`{"time":"2026-01-01T00:00:0`+string(rune('0'+i))+`Z","ok":true,"duration_ms":100,"events_added":1,"event_rows":10,"pid":7,"daemon":true}`+"\n")...)
+	}
+	lines = append(lines, []byte(`{"time":"2026-01-01T00:01:00Z","ok":false,"error":"disk full","pid":7,"daemon":true}`+"\n")...)
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, internalSync.HistoryFile), lines, 0600))
+
+	run := func(args ...string) string {
+		return captureStdout(t, func() {
+			cmd := NewRootCmd()
+			cmd.SetArgs(append([]string{"sync", "history"}, args...))
+			require.NoError(t, cmd.Execute())
+		})
+	}
+
+	out := run("--limit", "2")
+	assert.Contains(t, out, "history runs=7 failures=1 failure_streak=1 median_ms=100")
+	assert.Equal(t, 2, bytes.Count([]byte(out), []byte("\nrun ")))
+	assert.Contains(t, out, `error="disk full"`)
+	assert.Contains(t, out, `anomaly time=2026-01-01T00:01:00Z kind=error detail="disk full"`)
+
+	var got struct {
+		Summary internalSync.HistorySummary  `json:"summary"`
+		Runs    []internalSync.HistoryRecord `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(run("--json")), &got))
+	assert.Equal(t, 7, got.Summary.Runs)
+	assert.Len(t, got.Runs, 7)
+
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"sync", "history", "--limit", "-1"})
+	cmd.SilenceUsage = true
+	cmd.SetErr(new(bytes.Buffer))
+	assert.ErrorContains(t, cmd.Execute(), "--limit must not be negative")
 }
@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/giannimassi/jevons/internal/hooks"
+	"github.com/giannimassi/jevons/internal/store"
+	internalSync "github.com/giannimassi/jevons/internal/sync"
+	"github.com/giannimassi/jevons/internal/watch"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// restartOnly lists config.json settings a running process can't change:
+// the dashboard keeps its listener, encryption key and metrics settings, and
+// the process its log file, until it restarts.
+var restartOnly = []string{"port:", "encryption.", "log.", "metrics."}
+
+// syncer runs the syncs of a long-lived process (web, app, daemon run) with
+// the settings of the latest loaded config. Parse results are cached across
+// runs so each sync only re-reads changed files.
+type syncer struct {
+	// override re-applies command-line flags on top of a reloaded config.
+	override func(cfg *model.Config)
+	// logf reports config reloads (default: the slog info log).
+	logf func(format string, args ...any)
+
+	cache    *internalSync.Cache
+	reloadMu sync.Mutex // Serializes reloads from SIGHUP and the control socket
+
+	mu      sync.Mutex
+	cfg     model.Config
+	watcher *watch.Watcher
+	last    internalSync.Result
+}
+
+// reloadReply is the result of a config reload.
+type reloadReply struct {
+	Changes         []string `json:"changes"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+func (s *syncer) config() model.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+func (s *syncer) watching() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watcher != nil
+}
+
+func (s *syncer) lastResult() internalSync.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+func (s *syncer) sync() error {
+	result, err := internalSync.RunWith(s.config(), internalSync.Options{Cache: s.cache, Daemon: true})
+	if errors.Is(err, store.ErrLocked) {
+		// Another process is syncing the same data; this sync did not happen.
+		return fmt.Errorf("%w: %w", daemon.ErrSkipped, err)
+	}
+	if err == nil {
+		s.mu.Lock()
+		s.last = *result
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Close stops the source watcher, if any.
+func (s *syncer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watcher != nil {
+		s.watcher.Close()
+		s.watcher = nil
+	}
+}
+
+func (s *syncer) log(format string, args ...any) {
+	if s.logf != nil {
+		s.logf(format, args...)
+		return
+	}
+	slog.Info(fmt.Sprintf(format, args...))
+}
+
+// alert reports a breaker alert of the daemon in the log and to the
+// configured hooks, so a paused sync loop doesn't go unnoticed.
+func (s *syncer) alert(msg string) {
+	slog.Warn(msg)
+	for _, r := range hooks.Alert(s.config().Hooks, msg) {
+		if !r.OK {
+			slog.Warn("alert hook failed", "hook", r.Name, "err", r.Error)
+		}
+	}
+}
+
+// reload re-reads config.json and applies it to the running daemon between
+// syncs: the interval, retry and backup settings, the source directory and
+// watcher, and everything sync reads (workers, hooks, project rules). An
+// invalid config is rejected and leaves everything as it was.
+func (s *syncer) reload(ctx context.Context, d *daemon.Daemon) (reloadReply, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	reply, err := s.applyReload(ctx, d)
+	if err != nil {
+		s.log("config reload rejected: %v", err)
+		return reply, err
+	}
+	if len(reply.Changes) == 0 && len(reply.RestartRequired) == 0 {
+		s.log("config reloaded, nothing changed")
+	}
+	for _, c := range reply.Changes {
+		s.log("config changed: %s", c)
+	}
+	for _, c := range reply.RestartRequired {
+		s.log("config changed: %s (takes effect after a restart)", c)
+	}
+	return reply, nil
+}
+
+func (s *syncer) applyReload(ctx context.Context, d *daemon.Daemon) (reloadReply, error) {
+	var reply reloadReply
+	cfg, err := model.LoadConfig()
+	if err != nil {
+		return reply, err
+	}
+	if s.override != nil {
+		s.override(&cfg)
+	}
+	if cfg.Interval < 1 {
+		return reply, fmt.Errorf("interval must be a positive integer, got %d", cfg.Interval)
+	}
+
+	old := s.config()
+	for _, c := range model.DiffConfig(old, cfg) {
+		if isRestartOnly(c) {
+			reply.RestartRequired = append(reply.RestartRequired, c)
+		} else {
+			reply.Changes = append(reply.Changes, c)
+		}
+	}
+	cfg.Port = old.Port
+	cfg.Encryption = old.Encryption
+	cfg.Log = old.Log
+	cfg.Metrics = old.Metrics
+
+	// Start the new watcher before touching anything, so a source directory
+	// that can't be watched rejects the reload.
+	s.mu.Lock()
+	oldWatcher := s.watcher
+	s.mu.Unlock()
+	sourceMoved := cfg.SourceDir != old.SourceDir
+	newWatcher := oldWatcher
+	if !cfg.Watch || sourceMoved {
+		newWatcher = nil
+	}
+	if cfg.Watch && newWatcher == nil {
+		if newWatcher, err = watch.New(cfg.SourceDir, watch.DefaultDebounce); err != nil {
+			return reply, fmt.Errorf("watch %s: %w", cfg.SourceDir, err)
+		}
+	}
+
+	err = d.Update(ctx, func(d *daemon.Daemon) {
+		applyDaemonConfig(d, cfg)
+		d.Changes = nil
+		if newWatcher != nil {
+			d.Changes = newWatcher.C
+		}
+		s.mu.Lock()
+		s.cfg = cfg
+		s.watcher = newWatcher
+		s.mu.Unlock()
+	})
+	if err != nil {
+		if newWatcher != oldWatcher && newWatcher != nil {
+			newWatcher.Close()
+		}
+		return reply, err
+	}
+	if oldWatcher != nil && oldWatcher != newWatcher {
+		oldWatcher.Close()
+	}
+	return reply, nil
+}
+
+// reloadOnHangup reloads the config whenever the process gets SIGHUP, until
+// ctx is done.
+func (s *syncer) reloadOnHangup(ctx context.Context, d *daemon.Daemon) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				s.log("received SIGHUP, reloading config")
+				_, _ = s.reload(ctx, d)
+			}
+		}
+	}()
+}
+
+func isRestartOnly(change string) bool {
+	for _, prefix := range restartOnly {
+		if strings.HasPrefix(change, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDaemonConfig sets the loop settings that come from cfg.
+func applyDaemonConfig(d *daemon.Daemon, cfg model.Config) {
+	d.Interval = cfg.Interval
+	d.Backoff = daemon.Backoff{
+		Base: time.Duration(cfg.Interval) * time.Second,
+		Max:  time.Duration(cfg.Retry.MaxBackoff) * time.Second,
+	}
+	d.BreakerFailures = cfg.Retry.BreakerFailures
+	d.SnapshotInterval = time.Duration(cfg.Backup.IntervalHours) * time.Hour
+	d.SnapshotFn = snapshotFn(cfg)
+}
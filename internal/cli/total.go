@@ -7,20 +7,28 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/giannimassi/jevons/internal/query"
 	"github.com/spf13/cobra"
 )
 
 func newTotalCmd() *cobra.Command {
 	var rangeFlag string
+	var filter query.Filter
+	var by string
 
 	cmd := &cobra.Command{
 		Use:   "total",
 		Short: "Show token usage totals",
 		Long:  "Display aggregated token usage totals as JSON.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := model.DefaultConfig()
+			cfg, v, err := loadConfig()
+			if err != nil {
+				return err
+			}
 			eventsPath := filepath.Join(cfg.DataRoot, "events.tsv")
+			if err := resolveFilter(&filter, cfg, v); err != nil {
+				return err
+			}
 
 			if _, err := os.Stat(eventsPath); os.IsNotExist(err) {
 				return fmt.Errorf("no synced events found. Run: jevons sync")
@@ -30,42 +38,43 @@ func newTotalCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-
-			now := time.Now().Unix()
-			var cutoff int64
 			if rangeSec > 0 {
-				cutoff = now - rangeSec
+				filter.Since = time.Now().Unix() - rangeSec
 			}
 
-			events, err := readEventsFromTSV(eventsPath)
-			if err != nil {
+			var totals query.Totals
+			aggs := []query.Aggregator{&totals}
+			var groups *query.GroupBy
+			if by != "" {
+				key, err := groupKey(by, cfg, v)
+				if err != nil {
+					return err
+				}
+				groups = query.NewGroupBy(key)
+				aggs = append(aggs, groups)
+			}
+			if err := query.Scan(eventsPath, v, filter, aggs...); err != nil {
 				return fmt.Errorf("read events: %w", err)
 			}
 
-			var n, inputSum, outputSum, cacheReadSum, cacheCreateSum, billableSum, totalWithCacheSum int64
-			for _, e := range events {
-				if cutoff > 0 && e.TSEpoch < cutoff {
-					continue
-				}
-				n++
-				inputSum += e.Input
-				outputSum += e.Output
-				cacheReadSum += e.CacheRead
-				cacheCreateSum += e.CacheCreate
-				billableSum += e.Billable
-				totalWithCacheSum += e.TotalWithCache
+			var projectSlug any
+			if filter.Project != "" {
+				projectSlug = filter.Project
 			}
-
 			result := map[string]any{
 				"range":            rangeFlag,
-				"project_slug":     nil,
-				"events":           n,
-				"input":            inputSum,
-				"output":           outputSum,
-				"cache_read":       cacheReadSum,
-				"cache_create":     cacheCreateSum,
-				"billable":         billableSum,
-				"total_with_cache": totalWithCacheSum,
+				"project_slug":     projectSlug,
+				"events":           totals.Events,
+				"input":            totals.Input,
+				"output":           totals.Output,
+				"cache_read":       totals.CacheRead,
+				"cache_create":     totals.CacheCreate,
+				"billable":         totals.Billable,
+				"total_with_cache": totals.TotalWithCache,
+			}
+			if groups != nil {
+				result["by"] = by
+				result["groups"] = sortedGroups(groups)
 			}
 
 			enc := json.NewEncoder(os.Stdout)
@@ -75,5 +84,7 @@ func newTotalCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&rangeFlag, "range", "24h", "Time range (e.g., 1h, 24h, 7d)")
+	cmd.Flags().StringVar(&by, "by", "", "Also break totals down by project, repo, session, model or account")
+	addFilterFlags(cmd, &filter)
 	return cmd
 }
@@ -2,10 +2,13 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -83,6 +86,125 @@ func TestTotalCmdWithEvents(t *testing.T) {
 	assert.Contains(t, out, `"billable": 150`)
 }
 
+func TestTotalCmdFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	eventsPath := filepath.Join(tmpDir, "events.tsv")
+	header := "ts_epoch\tts_iso\tproject_slug\tsession_id\tinput\toutput\tcache_read\tcache_create\tbillable\ttotal_with_cache\tcontent_type\tsignature\n"
+	rows := "9999999990\t2286-11-20T17:46:30Z\talpha\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig1\n" +
+		"9999999999\t2286-11-20T17:46:39Z\tbeta\ts2\t7\t3\t0\t0\t10\t10\ttext\tsig2\n"
+	require.NoError(t, os.WriteFile(eventsPath, []byte(header+rows), 0644))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"total", "--range", "all", "--project", "beta"})
+		err := cmd.Execute()
+		require.NoError(t, err)
+	})
+
+	assert.Contains(t, out, `"project_slug": "beta"`)
+	assert.Contains(t, out, `"events": 1`)
+	assert.Contains(t, out, `"input": 7`)
+}
+
+func TestTotalCmdByRepo(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	header := "ts_epoch\tts_iso\tproject_slug\tsession_id\tinput\toutput\tcache_read\tcache_create\tbillable\ttotal_with_cache\tcontent_type\tsignature\n"
+	rows := "9999999990\t2286-11-20T17:46:30Z\t-code-repo\ts1\t100\t50\t0\t0\t150\t150\ttext\tsig1\n" +
+		"9999999991\t2286-11-20T17:46:31Z\t-code-repo-wt\ts2\t10\t10\t0\t0\t20\t20\ttext\tsig2\n" +
+		"9999999999\t2286-11-20T17:46:39Z\t-code-other\ts3\t7\t3\t0\t0\t10\t10\ttext\tsig3\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "events.tsv"), []byte(header+rows), 0644))
+	projects := `[
+  {"slug": "-code-repo", "path": "/code/repo", "git": {"repo": "/code/repo", "root": "/code/repo"}},
+  {"slug": "-code-repo-wt", "path": "/code/repo-wt", "git": {"repo": "/code/repo", "root": "/code/repo-wt", "worktree": true}},
+  {"slug": "-code-other", "path": "/code/other"}
+]`
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "projects.json"), []byte(projects), 0644))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"total", "--range", "all", "--by", "repo"})
+		require.NoError(t, cmd.Execute())
+	})
+
+	var result struct {
+		Billable int64 `json:"billable"`
+		Groups   []struct {
+			Key      string `json:"key"`
+			Events   int64  `json:"events"`
+			Billable int64  `json:"billable"`
+		} `json:"groups"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, int64(180), result.Billable)
+	require.Len(t, result.Groups, 2)
+	assert.Equal(t, "/code/repo", result.Groups[0].Key)
+	assert.Equal(t, int64(2), result.Groups[0].Events)
+	assert.Equal(t, int64(170), result.Groups[0].Billable)
+	assert.Equal(t, "/code/other", result.Groups[1].Key)
+
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"total", "--range", "all", "--by", "planet"})
+	cmd.SilenceUsage = true
+	cmd.SetErr(new(bytes.Buffer))
+	assert.ErrorContains(t, cmd.Execute(), "unknown grouping")
+}
+
+func TestTotalCmdAccountFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	events := []model.TokenEvent{
+		{TSEpoch: 9999999990, TSISO: "-", ProjectSlug: "alpha", SessionID: "s1", Input: 100, Billable: 100, ContentType: "text", Signature: "a", ID: "e1", Seq: 1, Account: "acct-a", Org: "org-1"},
+		{TSEpoch: 9999999991, TSISO: "-", ProjectSlug: "alpha", SessionID: "s2", Input: 10, Billable: 10, ContentType: "text", Signature: "b", ID: "e2", Seq: 2, Account: "acct-b", Org: "org-2"},
+		{TSEpoch: 9999999992, TSISO: "-", ProjectSlug: "beta", SessionID: "s3", Input: 1, Billable: 1, ContentType: "text", Signature: "c", ID: "e3", Seq: 3, Account: "acct-b", Org: "org-2"},
+	}
+	require.NoError(t, store.WriteEventsTSV(filepath.Join(tmpDir, "events.tsv"), events, nil))
+	accounts := model.AccountHistory{
+		{AccountUUID: "acct-a", OrganizationUUID: "org-1", Email: "a@example.com"},
+		{AccountUUID: "acct-b", OrganizationUUID: "org-2", Email: "b@example.com"},
+	}
+	require.NoError(t, store.WriteAccounts(filepath.Join(tmpDir, store.AccountsFile), accounts, nil))
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantBillable int64
+		wantErr      string
+	}{
+		{name: "account uuid", args: []string{"--account", "acct-a"}, wantBillable: 100},
+		{name: "account email", args: []string{"--account", "b@example.com"}, wantBillable: 11},
+		{name: "organization", args: []string{"--org", "org-2", "--project", "beta"}, wantBillable: 1},
+		{name: "unknown email", args: []string{"--account", "nobody@example.com"}, wantErr: "unknown account"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"total", "--range", "all"}, tt.args...)
+			if tt.wantErr != "" {
+				cmd := NewRootCmd()
+				cmd.SetArgs(args)
+				cmd.SilenceUsage = true
+				cmd.SetErr(new(bytes.Buffer))
+				assert.ErrorContains(t, cmd.Execute(), tt.wantErr)
+				return
+			}
+			out := captureStdout(t, func() {
+				cmd := NewRootCmd()
+				cmd.SetArgs(args)
+				require.NoError(t, cmd.Execute())
+			})
+			var result struct {
+				Billable int64 `json:"billable"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(out), &result))
+			assert.Equal(t, tt.wantBillable, result.Billable)
+		})
+	}
+}
+
 func TestTotalCmdInvalidRange(t *testing.T) {
 	tmpDir := t.TempDir()
 	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
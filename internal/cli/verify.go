@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/giannimassi/jevons/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var repair bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check event store integrity",
+		Long:  "Verify that the event stores are sorted, free of duplicates, internally consistent and match projects.json. Prints a JSON report and exits non-zero when issues remain.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, v, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			var report *verify.Report
+			if repair {
+				report, err = verify.Repair(cfg.DataRoot, v)
+			} else {
+				report, err = verify.Run(cfg.DataRoot, v)
+			}
+			if err != nil {
+				return fmt.Errorf("verify failed: %w", err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+
+			if !report.OK {
+				total := 0
+				for _, n := range report.Counts {
+					total += n
+				}
+				return fmt.Errorf("verify found %d issues", total)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&repair, "repair", false, "Rewrite the stores to fix detected issues")
+	return cmd
+}
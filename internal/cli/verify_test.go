@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCmdHelp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"mentions JSON report", "JSON report"},
+		{"repair flag", "--repair"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := NewRootCmd()
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs([]string{"verify", "--help"})
+
+			err := cmd.Execute()
+			require.NoError(t, err)
+			assert.Contains(t, buf.String(), tt.want)
+		})
+	}
+}
+
+func TestVerifyCmdReportsAndRepairs(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", tmpDir)
+
+	header := "ts_epoch\tts_iso\tproject_slug\tsession_id\tinput\toutput\tcache_read\tcache_create\tbillable\ttotal_with_cache\tcontent_type\tsignature\n"
+	liveHeader := "ts_epoch\tts_iso\tproject_slug\tsession_id\tprompt_preview\tinput\toutput\tcache_read\tcache_create\tbillable\ttotal_with_cache\tcontent_type\tsignature\n"
+	row := "1736937000\t2025-01-15T10:30:00Z\ttest\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig\n"
+	live := "1736937000\t2025-01-15T10:30:00Z\ttest\ts1\thi\t100\t50\t20\t10\t150\t180\ttext\tsig\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "events.tsv"), []byte(header+row+row), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "live-events.tsv"), []byte(liveHeader+live), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "projects.json"), []byte(`[{"slug":"test","path":"/test"}]`), 0600))
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"verify"})
+		assert.Error(t, cmd.Execute())
+	})
+	assert.Contains(t, out, `"ok": false`)
+	assert.Contains(t, out, `"duplicate": 1`)
+
+	out = captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"verify", "--repair"})
+		require.NoError(t, cmd.Execute())
+	})
+	assert.Contains(t, out, `"ok": true`)
+	assert.Contains(t, out, `"repaired"`)
+}
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,6 +12,7 @@ import (
 	"github.com/giannimassi/jevons/internal/daemon"
 	"github.com/giannimassi/jevons/internal/dashboard"
 	internalSync "github.com/giannimassi/jevons/internal/sync"
+	"github.com/giannimassi/jevons/internal/watch"
 	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/spf13/cobra"
 )
@@ -18,29 +21,58 @@ func newWebCmd() *cobra.Command {
 	defaults := model.DefaultConfig()
 	var port int
 	var interval int
+	var watchFlag bool
+	var noSync bool
 
 	cmd := &cobra.Command{
 		Use:   "web",
 		Short: "Start dashboard and background sync",
 		Long:  "Start the HTTP dashboard server and a background sync loop.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := model.DefaultConfig()
-			cfg.Port = port
-			cfg.Interval = interval
+			cfg, v, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("interval") && interval < 1 {
+				return fmt.Errorf("--interval must be a positive integer")
+			}
+			override := func(cfg *model.Config) {
+				if cmd.Flags().Changed("port") {
+					cfg.Port = port
+				}
+				if cmd.Flags().Changed("interval") {
+					cfg.Interval = interval
+				}
+				if cmd.Flags().Changed("watch") {
+					cfg.Watch = watchFlag
+				}
+			}
+			override(&cfg)
 
 			if err := daemon.EnsureDataDirs(cfg.DataRoot); err != nil {
 				return err
 			}
+			defer setupLogging(cfg, os.Stderr)()
 
 			// Initial sync
-			if _, err := internalSync.Run(cfg); err != nil {
-				fmt.Fprintf(os.Stderr, "initial sync warning: %v\n", err)
+			if !noSync {
+				if _, err := internalSync.Run(cfg); err != nil {
+					slog.Warn("initial sync failed", "err", err)
+				}
 			}
 
-			// Start HTTP server
+			// Start HTTP server, on the socket systemd passed us if there is one
+			ln, err := dashboard.SystemdListener()
+			if err != nil {
+				return err
+			}
 			srv := &dashboard.Server{
 				Port:     cfg.Port,
 				DataRoot: cfg.DataRoot,
+				Vault:    v,
+				Listener: ln,
+
+				MetricsProjects: cfg.Metrics.Projects,
 			}
 			if err := srv.Start(); err != nil {
 				return fmt.Errorf("start server: %w", err)
@@ -48,28 +80,47 @@ func newWebCmd() *cobra.Command {
 
 			url := fmt.Sprintf("http://127.0.0.1:%d/dashboard/index.html", cfg.Port)
 			fmt.Printf("Dashboard URL: %s\n", url)
-			fmt.Printf("Auto-sync interval: %ds\n", cfg.Interval)
 
-			// Start background sync daemon
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			serveOnly := func() error {
+				<-sigCh
+				return srv.Stop(context.Background())
+			}
+			if noSync {
+				return serveOnly()
+			}
+
+			// Start background sync daemon, unless another process already
+			// runs the sync loop for this data root.
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
-			d := &daemon.Daemon{
-				Interval: cfg.Interval,
-				DataRoot: cfg.DataRoot,
-				SyncFn: func() error {
-					_, err := internalSync.Run(cfg)
+			d, s := newSyncDaemon(cfg)
+			defer s.Close()
+			s.override = override
+			if err := d.Acquire(); err != nil {
+				var running *daemon.RunningError
+				if !errors.As(err, &running) {
 					return err
-				},
+				}
+				slog.Warn("sync loop already running; serving the dashboard only", "pid", running.PID)
+				fmt.Printf("Auto-sync: handled by pid %d\n", running.PID)
+				return serveOnly()
 			}
+			fmt.Printf("Auto-sync interval: %ds\n", cfg.Interval)
 
-			sigCh := make(chan os.Signal, 1)
-			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			shutdown := func() {
+				cancel()
+				srv.Stop(context.Background())
+			}
+			ctl := serveControl(d, s, shutdown)
+			defer ctl.Close()
+			s.reloadOnHangup(ctx, d)
 
 			go func() {
 				<-sigCh
-				cancel()
-				srv.Stop(context.Background())
+				shutdown()
 			}()
 
 			return d.Run(ctx)
@@ -78,6 +129,35 @@ func newWebCmd() *cobra.Command {
 
 	cmd.Flags().IntVar(&port, "port", defaults.Port, "HTTP server port")
 	cmd.Flags().IntVar(&interval, "interval", defaults.Interval, "Sync interval in seconds")
+	cmd.Flags().BoolVar(&watchFlag, "watch", defaults.Watch, "Also sync as soon as session files change")
+	cmd.Flags().BoolVar(&noSync, "no-sync", false, "Only serve the dashboard; leave syncing to a separate daemon")
 
 	return cmd
 }
+
+// newSyncDaemon builds the background sync daemon for cfg and the syncer
+// behind it. With cfg.Watch it also syncs on source file changes; close the
+// syncer to stop the watcher.
+func newSyncDaemon(cfg model.Config) (*daemon.Daemon, *syncer) {
+	s := &syncer{cfg: cfg, cache: internalSync.NewCache()}
+	d := &daemon.Daemon{
+		DataRoot: cfg.DataRoot,
+		SyncFn:   s.sync,
+		Alert:    s.alert,
+		Version:  Version,
+	}
+	applyDaemonConfig(d, cfg)
+	if !cfg.Watch {
+		return d, s
+	}
+
+	w, err := watch.New(cfg.SourceDir, watch.DefaultDebounce)
+	if err != nil {
+		slog.Warn("watch disabled", "interval", cfg.Interval, "err", err)
+		return d, s
+	}
+	fmt.Printf("Watching %s for changes\n", cfg.SourceDir)
+	s.watcher = w
+	d.Changes = w.C
+	return d, s
+}
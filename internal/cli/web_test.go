@@ -2,8 +2,11 @@ package cli
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -51,3 +54,29 @@ func TestWebCmdFlags(t *testing.T) {
 	require.NotNil(t, intervalFlag)
 	assert.Equal(t, "15", intervalFlag.DefValue)
 }
+
+func TestSyncDaemonAlertsHooks(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "alert")
+	cfg := model.DefaultConfig()
+	cfg.DataRoot = t.TempDir()
+	cfg.Hooks = []model.HookConfig{{Command: `printf '%s' "$JEVONS_ALERT" > ` + out}}
+	d, s := newSyncDaemon(cfg)
+	defer s.Close()
+
+	require.NotNil(t, d.Alert)
+	d.Alert("sync paused after 3 consecutive failures")
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "sync paused after 3 consecutive failures", string(data))
+}
+
+func TestWebCmdRejectsZeroInterval(t *testing.T) {
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", t.TempDir())
+
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"web", "--interval", "0"})
+	cmd.SilenceUsage = true
+	cmd.SetErr(new(bytes.Buffer))
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "--interval must be a positive integer")
+}
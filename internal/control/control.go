@@ -0,0 +1,270 @@
+// Package control serves a small JSON-RPC 2.0 API for a running jevons
+// process over a Unix domain socket in the data root, and calls it.
+//
+// Each connection carries newline-delimited requests and responses:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"state"}
+//	{"jsonrpc":"2.0","id":1,"result":{...}}
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+)
+
+// SocketFile is the control socket under DataRoot/pids.
+const SocketFile = "control.sock"
+
+// Methods served by jevons web, app and daemon run.
+const (
+	MethodSync        = "sync"
+	MethodState       = "state"
+	MethodSetInterval = "set_interval"
+	MethodReload      = "reload_config"
+	MethodShutdown    = "shutdown"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// DialTimeout bounds connecting to the socket; calls themselves may take as
+// long as the method needs (a sync, for instance).
+const DialTimeout = 2 * time.Second
+
+var (
+	// ErrUnavailable means nothing is listening on the control socket.
+	ErrUnavailable = errors.New("no running jevons process")
+	// ErrInUse means another process already serves the control socket.
+	ErrInUse = errors.New("control socket already in use")
+)
+
+// SocketPath returns the control socket for dataRoot.
+func SocketPath(dataRoot string) string {
+	return filepath.Join(dataRoot, "pids", SocketFile)
+}
+
+// Method handles one call. params is nil when the request has none.
+type Method func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Error is a JSON-RPC error, returned by Call for failed calls. Methods may
+// return one to choose the code; other errors are reported as CodeServerError.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// InvalidParams wraps err as a CodeInvalidParams error.
+func InvalidParams(err error) error {
+	return &Error{Code: CodeInvalidParams, Message: err.Error()}
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Server serves Methods on the socket at Path.
+type Server struct {
+	Path    string
+	Methods map[string]Method
+
+	ln     net.Listener
+	ctx    context.Context
+	cancel context.CancelFunc
+	calls  sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// Start listens on Path, replacing a stale socket left by a process that
+// died. It returns ErrInUse if another process answers on it.
+func (s *Server) Start() error {
+	if conn, err := net.DialTimeout("unix", s.Path, DialTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("%w: %s", ErrInUse, s.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), store.DirMode); err != nil {
+		return err
+	}
+	os.Remove(s.Path)
+	ln, err := net.Listen("unix", s.Path)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", s.Path, err)
+	}
+	if err := os.Chmod(s.Path, store.FileMode); err != nil {
+		ln.Close()
+		return err
+	}
+	s.ln = ln
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.conns = make(map[net.Conn]struct{})
+	go s.serve()
+	return nil
+}
+
+// Close stops accepting calls, cancels the context of calls in progress and
+// waits for them to send their response (so a shutdown call is acknowledged),
+// then removes the socket.
+func (s *Server) Close() error {
+	if s.ln == nil {
+		return nil
+	}
+	err := s.ln.Close()
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cancel()
+	s.calls.Wait()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	os.Remove(s.Path)
+	return err
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		s.calls.Add(1)
+		s.mu.Unlock()
+		resp := s.handle(scanner.Bytes())
+		err := enc.Encode(resp)
+		s.calls.Done()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(line []byte) rpcResponse {
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = &Error{Code: CodeParseError, Message: err.Error()}
+		return resp
+	}
+	if len(req.ID) > 0 {
+		resp.ID = req.ID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &Error{Code: CodeInvalidRequest, Message: "expected a JSON-RPC 2.0 request with a method"}
+		return resp
+	}
+	m, ok := s.Methods[req.Method]
+	if !ok {
+		resp.Error = &Error{Code: CodeMethodNotFound, Message: "unknown method: " + req.Method}
+		return resp
+	}
+
+	result, err := m(s.ctx, req.Params)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeServerError, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &Error{Code: CodeServerError, Message: err.Error()}
+		return resp
+	}
+	resp.Result = data
+	return resp
+}
+
+// Call invokes method on the process serving the socket at path, decoding
+// its result into result (which may be nil). It returns ErrUnavailable when
+// nothing is listening, and an *Error when the method fails.
+func Call(path, method string, params, result any) error {
+	conn, err := net.DialTimeout("unix", path, DialTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	req := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("send %s: %w", method, err)
+	}
+	var resp rpcResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("read %s response: %w", method, err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
@@ -0,0 +1,131 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shortTempDir keeps socket paths under the platform limit (104 bytes on macOS).
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "jvc")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func startServer(t *testing.T, path string) *Server {
+	t.Helper()
+	s := &Server{
+		Path: path,
+		Methods: map[string]Method{
+			"echo": func(ctx context.Context, params json.RawMessage) (any, error) {
+				var p struct {
+					Msg string `json:"msg"`
+				}
+				if err := json.Unmarshal(params, &p); err != nil {
+					return nil, InvalidParams(err)
+				}
+				return map[string]string{"msg": p.Msg}, nil
+			},
+			"fail": func(ctx context.Context, params json.RawMessage) (any, error) {
+				return nil, errors.New("boom")
+			},
+		},
+	}
+	require.NoError(t, s.Start())
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestCall(t *testing.T) {
+	path := SocketPath(shortTempDir(t))
+	startServer(t, path)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	tests := []struct {
+		name     string
+		method   string
+		params   any
+		want     string
+		wantCode int
+	}{
+		{"result", "echo", map[string]string{"msg": "hi"}, "hi", 0},
+		{"unknown method", "nope", nil, "", CodeMethodNotFound},
+		{"invalid params", "echo", nil, "", CodeInvalidParams},
+		{"method error", "fail", nil, "", CodeServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Msg string `json:"msg"`
+			}
+			err := Call(path, tt.method, tt.params, &got)
+			if tt.wantCode != 0 {
+				var rpcErr *Error
+				require.ErrorAs(t, err, &rpcErr)
+				assert.Equal(t, tt.wantCode, rpcErr.Code)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Msg)
+		})
+	}
+}
+
+func TestServerRawProtocol(t *testing.T) {
+	path := SocketPath(shortTempDir(t))
+	startServer(t, path)
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewScanner(conn)
+
+	// Several requests share a connection; ids are echoed back.
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","id":"a","method":"echo","params":{"msg":"x"}}` + "\n" + "{bad\n"))
+	require.NoError(t, err)
+	require.True(t, r.Scan())
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":"a","result":{"msg":"x"}}`, r.Text())
+	require.True(t, r.Scan())
+	var resp rpcResponse
+	require.NoError(t, json.Unmarshal(r.Bytes(), &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeParseError, resp.Error.Code)
+}
+
+func TestServerStart(t *testing.T) {
+	dir := shortTempDir(t)
+	path := SocketPath(dir)
+
+	// No server: calls report ErrUnavailable.
+	err := Call(path, "echo", nil, nil)
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	// A stale socket file left by a dead process is replaced.
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	s := startServer(t, path)
+	require.NoError(t, Call(path, "echo", map[string]string{"msg": "hi"}, nil))
+
+	// A second server on the same socket is refused.
+	err = (&Server{Path: path}).Start()
+	assert.ErrorIs(t, err, ErrInUse)
+
+	require.NoError(t, s.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Close should remove the socket")
+}
@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff spaces out retries after consecutive sync failures.
+type Backoff struct {
+	Base time.Duration // Wait after the first failure; doubles with each further one
+	Max  time.Duration // Cap on the wait, also the probe interval of an open breaker; 0 disables backoff
+}
+
+// Delay returns the wait before the next attempt after failures consecutive
+// failures. The doubled, capped delay is jittered down by up to half (r is
+// uniform in [0,1)) so daemons failing together don't retry in lockstep.
+func (b Backoff) Delay(failures int, r float64) time.Duration {
+	d := b.Base
+	for i := 1; i < failures && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	return d - time.Duration(r*float64(d)/2)
+}
+
+// held reports whether a backoff or the open breaker is holding back syncs.
+func (d *Daemon) held() bool {
+	return !d.retryAt.IsZero() && time.Now().Before(d.retryAt)
+}
+
+// schedule arms retry for the next attempt after a failed sync: a jittered
+// backoff, or a probe every Backoff.Max while the breaker is open.
+func (d *Daemon) schedule(retry *time.Timer) {
+	if d.failures == 0 || d.Backoff.Max <= 0 {
+		d.retryAt = time.Time{}
+		retry.Stop()
+		return
+	}
+	delay := d.Backoff.Delay(d.failures, rand.Float64())
+	if d.paused {
+		delay = d.Backoff.Max
+	}
+	d.retryAt = time.Now().Add(delay)
+	retry.Reset(delay)
+}
+
+// trip opens the breaker once BreakerFailures consecutive syncs have failed.
+func (d *Daemon) trip() {
+	if d.paused || d.BreakerFailures <= 0 || d.failures < d.BreakerFailures {
+		return
+	}
+	d.paused = true
+	d.alert(fmt.Sprintf("sync paused after %d consecutive failures, probing every %s: %s",
+		d.failures, d.Backoff.Max, d.lastError))
+}
+
+// reset closes the breaker after a successful sync.
+func (d *Daemon) reset(failures int) {
+	if !d.paused {
+		return
+	}
+	d.paused = false
+	d.alert(fmt.Sprintf("sync resumed after %d consecutive failures", failures))
+}
+
+func (d *Daemon) alert(msg string) {
+	if d.Alert != nil {
+		d.Alert(msg)
+		return
+	}
+	slog.Warn(msg)
+}
@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	b := Backoff{Base: 10 * time.Second, Max: time.Minute}
+	tests := []struct {
+		name     string
+		failures int
+		r        float64
+		want     time.Duration
+	}{
+		{"first failure", 1, 0, 10 * time.Second},
+		{"doubles", 2, 0, 20 * time.Second},
+		{"doubles again", 3, 0, 40 * time.Second},
+		{"capped", 4, 0, time.Minute},
+		{"stays capped", 50, 0, time.Minute},
+		{"jitter takes up to half", 2, 0.5, 15 * time.Second},
+		{"jitter on the cap", 10, 0.99, 30*time.Second + 300*time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, b.Delay(tt.failures, tt.r))
+		})
+	}
+}
+
+func TestDaemonBacksOffAfterFailures(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var calls atomic.Int32
+	changes := make(chan struct{})
+	d := &Daemon{
+		DataRoot: tmpDir,
+		SyncFn: func() error {
+			calls.Add(1)
+			return errors.New("disk full")
+		},
+		Changes: changes,
+		Backoff: Backoff{Base: time.Hour, Max: time.Hour},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+
+	// Changes during the backoff don't trigger syncs.
+	for i := 0; i < 5; i++ {
+		changes <- struct{}{}
+	}
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(1), calls.Load())
+
+	hb := ReadHeartbeatState(tmpDir)
+	require.NotNil(t, hb)
+	assert.Equal(t, "error", hb.Status)
+	assert.Greater(t, hb.NextAttemptEpoch, time.Now().Unix())
+}
+
+func TestDaemonCircuitBreaker(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var mu sync.Mutex
+	var alerts []string
+	var calls atomic.Int32
+	fail := atomic.Bool{}
+	fail.Store(true)
+	synced := make(chan struct{}, 100)
+	d := &Daemon{
+		DataRoot: tmpDir,
+		SyncFn: func() error {
+			calls.Add(1)
+			defer func() { synced <- struct{}{} }()
+			if fail.Load() {
+				return errors.New("source mount gone")
+			}
+			return nil
+		},
+		Backoff:         Backoff{Base: 5 * time.Millisecond, Max: 50 * time.Millisecond},
+		BreakerFailures: 3,
+		Alert: func(msg string) {
+			mu.Lock()
+			defer mu.Unlock()
+			alerts = append(alerts, msg)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+
+	waitSync := func() {
+		t.Helper()
+		select {
+		case <-synced:
+		case <-time.After(2 * time.Second):
+			t.Fatal("no sync attempt")
+		}
+	}
+	for i := 0; i < 3; i++ {
+		waitSync()
+	}
+	require.Eventually(t, func() bool {
+		hb := ReadHeartbeatState(tmpDir)
+		return hb != nil && hb.Status == "paused"
+	}, time.Second, 5*time.Millisecond)
+
+	// Probes keep failing while paused, then one succeeds.
+	waitSync()
+	fail.Store(false)
+	waitSync()
+	cancel()
+	require.NoError(t, <-done)
+
+	hb := ReadHeartbeatState(tmpDir)
+	require.NotNil(t, hb)
+	assert.Equal(t, "ok", hb.Status)
+	assert.Zero(t, hb.ConsecutiveFailures)
+	assert.Zero(t, hb.NextAttemptEpoch)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, alerts, 2)
+	assert.Contains(t, alerts[0], "sync paused after 3 consecutive failures")
+	assert.Contains(t, alerts[0], "source mount gone")
+	assert.Contains(t, alerts[1], "sync resumed after")
+}
@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoState is returned by State before the loop has written a heartbeat.
+var ErrNoState = errors.New("daemon has not started")
+
+// request is work handed to the Run loop: a sync when update is nil.
+type request struct {
+	update func(d *Daemon)
+	done   chan error
+}
+
+func (d *Daemon) requests() chan request {
+	d.reqOnce.Do(func() { d.reqC = make(chan request) })
+	return d.reqC
+}
+
+func (d *Daemon) do(ctx context.Context, r request) error {
+	r.done = make(chan error, 1)
+	select {
+	case d.requests() <- r:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-r.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SyncNow has the running loop sync immediately, even while it is backing
+// off or paused, and returns the sync's error. Syncs never overlap, so a
+// manual sync waits for one already in progress.
+func (d *Daemon) SyncNow(ctx context.Context) error {
+	return d.do(ctx, request{})
+}
+
+// Update runs fn on the loop goroutine between syncs, where it may change
+// Interval, Changes, Backoff, BreakerFailures, SnapshotInterval and SyncFn.
+// The tickers are restarted afterwards.
+func (d *Daemon) Update(ctx context.Context, fn func(d *Daemon)) error {
+	return d.do(ctx, request{update: fn})
+}
+
+// State returns the daemon's latest heartbeat without touching the disk.
+func (d *Daemon) State() (Heartbeat, error) {
+	hb := d.state.Load()
+	if hb == nil {
+		return Heartbeat{}, ErrNoState
+	}
+	return *hb, nil
+}
@@ -2,152 +2,435 @@ package daemon
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+)
+
+// HeartbeatFile is the JSON heartbeat under DataRoot/heartbeat. Older
+// versions and the shell script wrote "epoch,interval,pid,status" to
+// LegacyHeartbeatFile instead; ReadHeartbeatState still reads it.
+const (
+	HeartbeatFile       = "sync.json"
+	LegacyHeartbeatFile = "sync.txt"
 )
 
+// Heartbeat is the content of HeartbeatFile, rewritten when each sync starts
+// and finishes.
+type Heartbeat struct {
+	Epoch               int64  `json:"epoch"`
+	Interval            int    `json:"interval"`
+	PID                 int    `json:"pid"`
+	Status              string `json:"status"` // "ok", "working", "error", "paused", "skipped"
+	LastError           string `json:"last_error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastSuccessEpoch    int64  `json:"last_success_epoch,omitempty"`
+	NextAttemptEpoch    int64  `json:"next_attempt_epoch,omitempty"` // While backing off or paused
+	DurationMS          int64  `json:"duration_ms"`                  // Of the last finished sync
+	Version             string `json:"version,omitempty"`
+	Host                string `json:"host,omitempty"`
+}
+
 // HeartbeatState represents the parsed state of a heartbeat file.
 type HeartbeatState struct {
-	Mode     string // "running" or "stale"
-	PID      string
-	Interval int
-	Age      int
-	Status   string // "ok", "working", "error"
+	Mode     string `json:"mode"` // "running" or "stale"
+	PID      string `json:"pid"`
+	Interval int    `json:"interval"`
+	Age      int    `json:"age_s"`
+	Status   string `json:"status"` // "ok", "working", "error", "paused", "skipped"
+
+	// Details below are only present in JSON heartbeats.
+	Epoch               int64  `json:"epoch"`
+	LastError           string `json:"last_error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastSuccessEpoch    int64  `json:"last_success_epoch,omitempty"`
+	NextAttemptEpoch    int64  `json:"next_attempt_epoch,omitempty"`
+	DurationMS          int64  `json:"duration_ms"`
+	Version             string `json:"version,omitempty"`
+	Host                string `json:"host,omitempty"`
+	Legacy              bool   `json:"legacy,omitempty"` // Read from the CSV heartbeat
 }
 
 // SyncFunc is the function called on each sync iteration.
 type SyncFunc func() error
 
+// ErrSkipped is wrapped by a SyncFunc error when the sync did not run, e.g.
+// because another process holds the DataRoot lock. A skipped sync is
+// neither a success nor a failure: failures, backoff and breaker are left as
+// they were, and the heartbeat status is "skipped".
+var ErrSkipped = errors.New("sync skipped")
+
 // Daemon runs a background sync loop with heartbeat monitoring.
 type Daemon struct {
 	Interval int
 	DataRoot string
 	SyncFn   SyncFunc
+
+	// Changes, when set, triggers a sync as soon as source files change (see
+	// internal/watch). The Interval ticker keeps running as a safety net.
+	Changes <-chan struct{}
+
+	// SnapshotInterval schedules SnapshotFn (e.g. a data-root backup); 0 disables it.
+	SnapshotInterval time.Duration
+	SnapshotFn       func() error
+
+	// Backoff holds back syncs after failures; ticks and changes that arrive
+	// meanwhile are skipped.
+	Backoff Backoff
+	// BreakerFailures, when positive, pauses syncing after that many
+	// consecutive failures: only a probe runs every Backoff.Max until one
+	// succeeds. Alert is told when syncing pauses and resumes (default: the
+	// slog warning log).
+	BreakerFailures int
+	Alert           func(msg string)
+
+	// Version is recorded in the heartbeat.
+	Version string
+
+	// Outcome of recent syncs, reported in the heartbeat.
+	status           string
+	lastError        string
+	failures         int
+	lastSuccessEpoch int64
+	lastDuration     time.Duration
+	retryAt          time.Time
+	paused           bool
+
+	// The locked PID file while this process owns the sync loop.
+	pidFile *os.File
+
+	// Control requests from other goroutines, served by the Run loop.
+	reqOnce sync.Once
+	reqC    chan request
+	state   atomic.Pointer[Heartbeat]
 }
 
 func (d *Daemon) heartbeatPath() string {
-	return filepath.Join(d.DataRoot, "heartbeat", "sync.txt")
+	return filepath.Join(d.DataRoot, "heartbeat", HeartbeatFile)
 }
 
 func (d *Daemon) pidPath() string {
-	return filepath.Join(d.DataRoot, "pids", "sync.pid")
+	return PIDPath(d.DataRoot)
 }
 
-// WriteHeartbeat writes a heartbeat entry: epoch,interval,pid,status
+// WriteHeartbeat writes the JSON heartbeat with status and the outcome of
+// recent syncs. It is written on one line so it can be tailed or printed as
+// a key=value field.
 func (d *Daemon) WriteHeartbeat(status string) error {
 	path := d.heartbeatPath()
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), store.DirMode); err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	var next int64
+	if !d.retryAt.IsZero() {
+		next = d.retryAt.Unix()
+	}
+	hb := Heartbeat{
+		Epoch:               time.Now().Unix(),
+		Interval:            d.Interval,
+		PID:                 os.Getpid(),
+		Status:              status,
+		LastError:           d.lastError,
+		ConsecutiveFailures: d.failures,
+		LastSuccessEpoch:    d.lastSuccessEpoch,
+		NextAttemptEpoch:    next,
+		DurationMS:          d.lastDuration.Milliseconds(),
+		Version:             d.Version,
+		Host:                host,
+	}
+	d.state.Store(&hb)
+	data, err := json.Marshal(hb)
+	if err != nil {
 		return err
 	}
-	content := fmt.Sprintf("%d,%d,%d,%s\n", time.Now().Unix(), d.Interval, os.Getpid(), status)
-	return os.WriteFile(path, []byte(content), 0644)
+	return store.WriteFile(path, append(data, '\n'), nil)
+}
+
+// Acquire makes this process the one sync loop of DataRoot: it locks the PID
+// file, which it keeps until Run returns, and records its PID there. It fails
+// with a *RunningError while another process owns the PID file. Run calls it
+// when the caller has not; callers that must not set anything else up (like
+// the control socket) while another loop runs call it first.
+func (d *Daemon) Acquire() error {
+	if d.pidFile != nil {
+		return nil
+	}
+	path := d.pidPath()
+	f := inheritedFile(pidFDEnv)
+	if f != nil && !isFile(f, path) {
+		// Not the PID file of this DataRoot; leave it alone.
+		f = nil
+	}
+	if f == nil {
+		var err error
+		if f, err = lockPIDFile(path); err != nil {
+			return err
+		}
+	}
+	if err := writePIDFile(f, os.Getpid()); err != nil {
+		f.Close()
+		return fmt.Errorf("write PID: %w", err)
+	}
+	d.pidFile = f
+	return nil
 }
 
-// WritePID writes the current process PID to the PID file.
-func (d *Daemon) WritePID() error {
+// release removes the PID file if it still names this process, and unlocks it.
+func (d *Daemon) release() {
+	if d.pidFile == nil {
+		return
+	}
 	path := d.pidPath()
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return err
+	if isFile(d.pidFile, path) && readPIDFile(path) == os.Getpid() {
+		os.Remove(path)
 	}
-	return os.WriteFile(path, []byte(fmt.Sprintf("%d", os.Getpid())), 0644)
+	unlockFile(d.pidFile)
+	d.pidFile.Close()
+	d.pidFile = nil
 }
 
-// Run starts the sync loop. It blocks until the context is cancelled.
+// Run starts the sync loop. It blocks until the context is cancelled, and
+// fails with a *RunningError when another process already runs the loop for
+// DataRoot (see Acquire).
 func (d *Daemon) Run(ctx context.Context) error {
 	for _, dir := range []string{"heartbeat", "pids", "logs"} {
-		if err := os.MkdirAll(filepath.Join(d.DataRoot, dir), 0755); err != nil {
+		if err := os.MkdirAll(filepath.Join(d.DataRoot, dir), store.DirMode); err != nil {
 			return fmt.Errorf("create %s dir: %w", dir, err)
 		}
 	}
 
-	if err := d.WritePID(); err != nil {
-		return fmt.Errorf("write PID: %w", err)
+	if err := d.Acquire(); err != nil {
+		return err
 	}
+	defer d.release()
+
+	// Backoff retries fire from this timer; it only runs after a failure.
+	retry := time.NewTimer(time.Hour)
+	retry.Stop()
+	defer retry.Stop()
 
 	// Run sync immediately
-	d.runOnce()
+	d.attempt(retry)
 
-	// If interval is 0, just block until context is cancelled (no periodic sync)
-	if d.Interval <= 0 {
-		<-ctx.Done()
-		d.cleanup()
-		return nil
+	// A nil channel never fires, so disabled timers simply drop out of the select.
+	// If interval is 0, there is no periodic sync.
+	var syncT, snapshotT *time.Ticker
+	var syncC, snapshotC <-chan time.Time
+	startTickers := func() {
+		syncT, syncC = newTicker(time.Duration(d.Interval) * time.Second)
+		if d.SnapshotFn != nil {
+			snapshotT, snapshotC = newTicker(d.SnapshotInterval)
+		}
 	}
-
-	ticker := time.NewTicker(time.Duration(d.Interval) * time.Second)
-	defer ticker.Stop()
+	stopTickers := func() {
+		for _, t := range []*time.Ticker{syncT, snapshotT} {
+			if t != nil {
+				t.Stop()
+			}
+		}
+		syncT, snapshotT, syncC, snapshotC = nil, nil, nil, nil
+	}
+	startTickers()
+	defer stopTickers()
 
 	for {
 		select {
 		case <-ctx.Done():
-			d.cleanup()
 			return nil
-		case <-ticker.C:
-			d.runOnce()
+		case <-syncC:
+			d.attempt(retry)
+		case <-d.Changes:
+			d.attempt(retry)
+		case <-retry.C:
+			d.attempt(retry)
+		case r := <-d.requests():
+			if r.update != nil {
+				r.update(d)
+				stopTickers()
+				startTickers()
+				_ = d.WriteHeartbeat(d.status)
+				r.done <- nil
+				continue
+			}
+			err := d.runOnce()
+			d.schedule(retry)
+			_ = d.WriteHeartbeat(d.status)
+			r.done <- err
+		case <-snapshotC:
+			if err := d.SnapshotFn(); err != nil {
+				slog.Error("snapshot failed", "err", err)
+			}
 		}
 	}
 }
 
-func (d *Daemon) runOnce() {
-	_ = d.WriteHeartbeat("working")
-	if err := d.SyncFn(); err != nil {
-		_ = d.WriteHeartbeat("error")
-	} else {
-		_ = d.WriteHeartbeat("ok")
+// newTicker returns a ticker and its channel, or nils when every is not positive.
+func newTicker(every time.Duration) (*time.Ticker, <-chan time.Time) {
+	if every <= 0 {
+		return nil, nil
 	}
+	t := time.NewTicker(every)
+	return t, t.C
 }
 
-func (d *Daemon) cleanup() {
-	os.Remove(d.pidPath())
+// attempt syncs unless a backoff or the open breaker holds syncs back, in
+// which case it only refreshes the heartbeat so it doesn't go stale.
+func (d *Daemon) attempt(retry *time.Timer) {
+	if d.held() {
+		_ = d.WriteHeartbeat(d.status)
+		return
+	}
+	_ = d.runOnce()
+	d.schedule(retry)
+	_ = d.WriteHeartbeat(d.status)
 }
 
-// ReadHeartbeatState reads and parses the heartbeat file.
-func ReadHeartbeatState(dataRoot string) *HeartbeatState {
-	path := filepath.Join(dataRoot, "heartbeat", "sync.txt")
-	data, err := os.ReadFile(path)
+// runOnce runs SyncFn and records its outcome; attempt writes the heartbeat.
+func (d *Daemon) runOnce() error {
+	_ = d.WriteHeartbeat("working")
+	start := time.Now()
+	err := d.SyncFn()
+	if errors.Is(err, ErrSkipped) {
+		slog.Info("sync skipped", "reason", err)
+		d.status = "skipped"
+		return err
+	}
+	d.lastDuration = time.Since(start)
 	if err != nil {
-		return nil
+		d.lastError = err.Error()
+		d.failures++
+		slog.Error("sync failed", "err", err, "failures", d.failures, "duration", d.lastDuration)
+		d.trip()
+		d.status = "error"
+		if d.paused {
+			d.status = "paused"
+		}
+		return err
 	}
+	failures := d.failures
+	d.lastError = ""
+	d.failures = 0
+	d.lastSuccessEpoch = time.Now().Unix()
+	d.reset(failures)
+	d.status = "ok"
+	return nil
+}
 
-	line := strings.TrimSpace(string(data))
-	parts := strings.SplitN(line, ",", 4)
-	if len(parts) < 4 {
-		return nil
-	}
+// ReadHeartbeatState reads and parses the newer of the JSON and the legacy
+// CSV heartbeat (see ReadHeartbeat).
+func ReadHeartbeatState(dataRoot string) *HeartbeatState {
+	hb, _ := ReadHeartbeat(dataRoot)
+	return hb
+}
 
-	epoch, err := strconv.ParseInt(parts[0], 10, 64)
-	if err != nil {
-		return nil
+// ReadHeartbeat parses both heartbeat files and returns the one with the
+// newer epoch, with its raw content; the JSON heartbeat wins a tie. Both
+// exist when the shell script or an older binary ran after this one, and
+// then the CSV heartbeat is the current one. It returns nil when neither
+// file holds a heartbeat.
+func ReadHeartbeat(dataRoot string) (*HeartbeatState, []byte) {
+	dir := filepath.Join(dataRoot, "heartbeat")
+	var hb *HeartbeatState
+	var raw []byte
+	for _, f := range []struct {
+		name  string
+		parse func([]byte) *HeartbeatState
+	}{
+		{HeartbeatFile, parseJSONHeartbeat},
+		{LegacyHeartbeatFile, parseCSVHeartbeat},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, f.name))
+		if err != nil {
+			continue
+		}
+		if parsed := f.parse(data); parsed != nil && (hb == nil || parsed.Epoch > hb.Epoch) {
+			hb, raw = parsed, data
+		}
+	}
+	if hb == nil {
+		return nil, nil
 	}
+	hb.setMode()
+	return hb, raw
+}
 
-	interval, _ := strconv.Atoi(parts[1])
-	pid := parts[2]
-	status := parts[3]
+// setMode derives Age and Mode from Epoch and Interval.
+func (hb *HeartbeatState) setMode() {
+	hb.Age = int(time.Now().Unix() - hb.Epoch)
+	hb.Mode = "stale"
+	if hb.Interval > 0 && hb.Age <= hb.StaleAfter() {
+		hb.Mode = "running"
+	}
+}
 
-	now := time.Now().Unix()
-	age := int(now - epoch)
+// StaleAfter is how many seconds may pass without a heartbeat (or a
+// successful sync) before the loop counts as stale: twelve intervals, and at
+// least five minutes.
+func (hb *HeartbeatState) StaleAfter() int {
+	return max(hb.Interval*12, 300)
+}
 
-	healthyLimit := interval * 12
-	if healthyLimit < 300 {
-		healthyLimit = 300
+func parseJSONHeartbeat(data []byte) *HeartbeatState {
+	var h Heartbeat
+	if err := json.Unmarshal(data, &h); err != nil || h.Epoch == 0 {
+		return nil
 	}
+	return h.state()
+}
 
-	mode := "stale"
-	if interval > 0 && age <= healthyLimit {
-		mode = "running"
+// State converts a heartbeat (as returned by Daemon.State) into a
+// HeartbeatState, as if read from the heartbeat file.
+func (h Heartbeat) State() *HeartbeatState {
+	hb := h.state()
+	hb.setMode()
+	return hb
+}
+
+func (h Heartbeat) state() *HeartbeatState {
+	return &HeartbeatState{
+		PID:                 strconv.Itoa(h.PID),
+		Interval:            h.Interval,
+		Status:              h.Status,
+		Epoch:               h.Epoch,
+		LastError:           h.LastError,
+		ConsecutiveFailures: h.ConsecutiveFailures,
+		LastSuccessEpoch:    h.LastSuccessEpoch,
+		NextAttemptEpoch:    h.NextAttemptEpoch,
+		DurationMS:          h.DurationMS,
+		Version:             h.Version,
+		Host:                h.Host,
 	}
+}
 
+// parseCSVHeartbeat parses "epoch,interval,pid,status".
+func parseCSVHeartbeat(data []byte) *HeartbeatState {
+	parts := strings.SplitN(strings.TrimSpace(string(data)), ",", 4)
+	if len(parts) < 4 {
+		return nil
+	}
+	epoch, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil
+	}
+	interval, _ := strconv.Atoi(parts[1])
 	return &HeartbeatState{
-		Mode:     mode,
-		PID:      pid,
+		PID:      parts[2],
 		Interval: interval,
-		Age:      age,
-		Status:   status,
+		Status:   parts[3],
+		Epoch:    epoch,
+		Legacy:   true,
 	}
 }
 
@@ -166,13 +449,8 @@ func IsPIDRunning(pid int) bool {
 
 // IsSyncRunning checks if the sync daemon is running via PID file or heartbeat.
 func IsSyncRunning(dataRoot string) bool {
-	pidPath := filepath.Join(dataRoot, "pids", "sync.pid")
-	data, err := os.ReadFile(pidPath)
-	if err == nil {
-		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
-		if err == nil && IsPIDRunning(pid) {
-			return true
-		}
+	if _, alive := ReadPID(dataRoot); alive {
+		return true
 	}
 
 	hb := ReadHeartbeatState(dataRoot)
@@ -190,7 +468,7 @@ func EnsureDataDirs(dataRoot string) error {
 		filepath.Join(dataRoot, "dashboard"),
 	}
 	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := os.MkdirAll(dir, store.DirMode); err != nil {
 			return fmt.Errorf("create dir %s: %w", dir, err)
 		}
 	}
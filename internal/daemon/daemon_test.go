@@ -2,9 +2,11 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -48,6 +50,7 @@ func TestDaemonRunAndHeartbeat(t *testing.T) {
 func TestReadHeartbeatState(t *testing.T) {
 	tests := []struct {
 		name     string
+		file     string // defaults to the legacy CSV heartbeat
 		content  string
 		wantNil  bool
 		wantMode string
@@ -71,6 +74,18 @@ func TestReadHeartbeatState(t *testing.T) {
 			content:  "1000000000,15,12345,ok\n",
 			wantMode: "stale",
 		},
+		{
+			name:     "stale json heartbeat",
+			file:     HeartbeatFile,
+			content:  `{"epoch":1000000000,"interval":15,"pid":12345,"status":"error","last_error":"boom","consecutive_failures":3}`,
+			wantMode: "stale",
+		},
+		{
+			name:    "invalid json heartbeat",
+			file:    HeartbeatFile,
+			content: "{",
+			wantNil: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -79,8 +94,12 @@ func TestReadHeartbeatState(t *testing.T) {
 			hbDir := filepath.Join(tmpDir, "heartbeat")
 			require.NoError(t, os.MkdirAll(hbDir, 0755))
 
+			file := tt.file
+			if file == "" {
+				file = LegacyHeartbeatFile
+			}
 			if tt.content != "" {
-				require.NoError(t, os.WriteFile(filepath.Join(hbDir, "sync.txt"), []byte(tt.content), 0644))
+				require.NoError(t, os.WriteFile(filepath.Join(hbDir, file), []byte(tt.content), 0644))
 			} else if tt.wantMode == "running" {
 				// Write a fresh heartbeat
 				now := time.Now().Unix()
@@ -99,6 +118,122 @@ func TestReadHeartbeatState(t *testing.T) {
 	}
 }
 
+func TestHeartbeatErrorDetails(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fail := true
+	d := &Daemon{
+		Interval: 60,
+		DataRoot: tmpDir,
+		Version:  "v1.2.3",
+		SyncFn: func() error {
+			if fail {
+				return errors.New("parse failed")
+			}
+			return nil
+		},
+	}
+
+	retry := time.NewTimer(time.Hour)
+	retry.Stop()
+	d.attempt(retry)
+	d.attempt(retry)
+	hb := ReadHeartbeatState(tmpDir)
+	require.NotNil(t, hb)
+	assert.Equal(t, "running", hb.Mode)
+	assert.Equal(t, "error", hb.Status)
+	assert.Equal(t, "parse failed", hb.LastError)
+	assert.Equal(t, 2, hb.ConsecutiveFailures)
+	assert.Zero(t, hb.LastSuccessEpoch)
+	assert.Equal(t, "v1.2.3", hb.Version)
+	assert.Equal(t, strconv.Itoa(os.Getpid()), hb.PID)
+	assert.False(t, hb.Legacy)
+
+	fail = false
+	d.attempt(retry)
+	hb = ReadHeartbeatState(tmpDir)
+	require.NotNil(t, hb)
+	assert.Equal(t, "ok", hb.Status)
+	assert.Empty(t, hb.LastError)
+	assert.Zero(t, hb.ConsecutiveFailures)
+	assert.NotZero(t, hb.LastSuccessEpoch)
+}
+
+func TestSkippedSyncIsNotASuccess(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	result := errors.New("parse failed")
+	d := &Daemon{
+		Interval: 60,
+		DataRoot: tmpDir,
+		Backoff:  Backoff{Base: time.Minute, Max: time.Hour},
+		SyncFn:   func() error { return result },
+	}
+
+	retry := time.NewTimer(time.Hour)
+	retry.Stop()
+	defer retry.Stop()
+	d.attempt(retry)
+	require.Equal(t, 1, d.failures)
+
+	// A skip neither resets nor adds to the failures, and keeps the backoff.
+	result = fmt.Errorf("%w: sync already in progress", ErrSkipped)
+	err := d.runOnce()
+	require.ErrorIs(t, err, ErrSkipped)
+	d.schedule(retry)
+	_ = d.WriteHeartbeat(d.status)
+	hb := ReadHeartbeatState(tmpDir)
+	require.NotNil(t, hb)
+	assert.Equal(t, "skipped", hb.Status)
+	assert.Equal(t, 1, hb.ConsecutiveFailures)
+	assert.Equal(t, "parse failed", hb.LastError)
+	assert.Zero(t, hb.LastSuccessEpoch)
+	assert.False(t, d.retryAt.IsZero(), "the backoff is still armed")
+}
+
+func TestReadHeartbeatStatePicksNewer(t *testing.T) {
+	now := time.Now().Unix()
+	jsonHB := func(epoch int64) string {
+		return fmt.Sprintf(`{"epoch":%d,"interval":15,"pid":2,"status":"working"}`, epoch)
+	}
+	csvHB := func(epoch int64) string { return fmt.Sprintf("%d,15,1,ok\n", epoch) }
+
+	tests := []struct {
+		name    string
+		json    string
+		csv     string
+		wantPID string
+	}{
+		{name: "JSON newer", json: jsonHB(now), csv: csvHB(now - 60), wantPID: "2"},
+		{name: "CSV newer", json: jsonHB(now - 60), csv: csvHB(now), wantPID: "1"},
+		{name: "tie prefers JSON", json: jsonHB(now), csv: csvHB(now), wantPID: "2"},
+		{name: "unparsable JSON", json: "{", csv: csvHB(now - 60), wantPID: "1"},
+		{name: "JSON only", json: jsonHB(now), wantPID: "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			hbDir := filepath.Join(tmpDir, "heartbeat")
+			require.NoError(t, os.MkdirAll(hbDir, 0755))
+			for name, content := range map[string]string{HeartbeatFile: tt.json, LegacyHeartbeatFile: tt.csv} {
+				if content != "" {
+					require.NoError(t, os.WriteFile(filepath.Join(hbDir, name), []byte(content), 0644))
+				}
+			}
+
+			hb, raw := ReadHeartbeat(tmpDir)
+			require.NotNil(t, hb)
+			assert.Equal(t, tt.wantPID, hb.PID)
+			assert.Equal(t, tt.wantPID == "1", hb.Legacy)
+			want := tt.json
+			if hb.Legacy {
+				want = tt.csv
+			}
+			assert.Equal(t, want, string(raw))
+		})
+	}
+}
+
 func TestEnsureDataDirs(t *testing.T) {
 	tmpDir := t.TempDir()
 	dataRoot := filepath.Join(tmpDir, "data")
@@ -111,3 +246,113 @@ func TestEnsureDataDirs(t *testing.T) {
 		assert.True(t, info.IsDir())
 	}
 }
+
+func TestDaemonScheduledSnapshots(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var snapshots atomic.Int32
+	d := &Daemon{
+		Interval:         0,
+		DataRoot:         tmpDir,
+		SyncFn:           func() error { return nil },
+		SnapshotInterval: 100 * time.Millisecond,
+		SnapshotFn: func() error {
+			snapshots.Add(1)
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 450*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, d.Run(ctx))
+	assert.GreaterOrEqual(t, snapshots.Load(), int32(3))
+}
+
+func TestDaemonSyncsOnChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	changes := make(chan struct{})
+	synced := make(chan struct{}, 10)
+	d := &Daemon{
+		Interval: 0,
+		DataRoot: tmpDir,
+		SyncFn: func() error {
+			synced <- struct{}{}
+			return nil
+		},
+		Changes: changes,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+
+	<-synced // initial sync
+	for i := 0; i < 2; i++ {
+		changes <- struct{}{}
+		select {
+		case <-synced:
+		case <-time.After(time.Second):
+			t.Fatal("change did not trigger a sync")
+		}
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestDaemonSingleInstance(t *testing.T) {
+	tmpDir := t.TempDir()
+	synced := make(chan struct{}, 10)
+	first := &Daemon{DataRoot: tmpDir, SyncFn: func() error {
+		synced <- struct{}{}
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- first.Run(ctx) }()
+	<-synced
+
+	pid, alive := ReadPID(tmpDir)
+	assert.Equal(t, os.Getpid(), pid)
+	assert.True(t, alive)
+
+	// A second loop on the same DataRoot is refused and leaves the PID file alone.
+	second := &Daemon{DataRoot: tmpDir, SyncFn: func() error { return nil }}
+	err := second.Run(context.Background())
+	require.ErrorIs(t, err, ErrRunning)
+	assert.Equal(t, os.Getpid(), err.(*RunningError).PID)
+	assert.FileExists(t, PIDPath(tmpDir))
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.NoFileExists(t, PIDPath(tmpDir))
+
+	// Once the first has stopped, the lock is free again.
+	require.NoError(t, second.Acquire())
+	second.release()
+}
+
+func TestDaemonKeepsForeignPIDFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	synced := make(chan struct{}, 10)
+	d := &Daemon{DataRoot: tmpDir, SyncFn: func() error {
+		synced <- struct{}{}
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	<-synced
+
+	// The PID file now names another process: it is not ours to remove.
+	require.NoError(t, os.WriteFile(PIDPath(tmpDir), []byte("4242"), 0600))
+	cancel()
+	require.NoError(t, <-done)
+	data, err := os.ReadFile(PIDPath(tmpDir))
+	require.NoError(t, err)
+	assert.Equal(t, "4242", string(data))
+}
@@ -0,0 +1,208 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+)
+
+// LogFile is where a detached daemon's output goes, under DataRoot/logs
+// (same name as the shell script's sync loop log).
+const LogFile = "sync.log"
+
+// DefaultStopTimeout is how long Stop waits after SIGTERM before killing.
+const DefaultStopTimeout = 10 * time.Second
+
+// ErrRunning is returned by Spawn when a daemon already owns the PID file.
+var ErrRunning = errors.New("daemon already running")
+
+// ErrNotRunning is returned by Stop when no live daemon owns the PID file.
+var ErrNotRunning = errors.New("daemon not running")
+
+// RunningError reports which process is already running.
+type RunningError struct {
+	PID int
+}
+
+func (e *RunningError) Error() string {
+	return fmt.Sprintf("%v (pid %d)", ErrRunning, e.PID)
+}
+
+// Unwrap lets errors.Is(err, ErrRunning) match.
+func (e *RunningError) Unwrap() error { return ErrRunning }
+
+// PIDPath returns the sync daemon PID file under dataRoot.
+func PIDPath(dataRoot string) string {
+	return filepath.Join(dataRoot, "pids", "sync.pid")
+}
+
+// LogPath returns the detached daemon log under dataRoot.
+func LogPath(dataRoot string) string {
+	return filepath.Join(dataRoot, "logs", LogFile)
+}
+
+// pidFDEnv tells a spawned daemon which inherited descriptor holds the
+// locked PID file, so the lock is never released between Spawn and Run.
+const pidFDEnv = "JEVONS_PID_FD"
+
+// ReadPID returns the PID recorded in the PID file (0 if there is none) and
+// whether that process is alive. The process that owns the PID file holds a
+// lock on it for as long as it runs, so a PID that was reused by an
+// unrelated process after a crash or reboot does not count as alive.
+func ReadPID(dataRoot string) (int, bool) {
+	path := PIDPath(dataRoot)
+	pid := readPIDFile(path)
+	if pid == 0 {
+		return 0, false
+	}
+	return pid, IsPIDRunning(pid) && isLocked(path)
+}
+
+func readPIDFile(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// lockPIDFile opens and locks the PID file at path. It fails with a
+// *RunningError when another process holds the lock. A file unlinked by its
+// previous owner between open and lock is not ours, so it tries again.
+func lockPIDFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), store.DirMode); err != nil {
+		return nil, err
+	}
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, store.FileMode)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := lockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock PID file: %w", err)
+		}
+		if !ok {
+			f.Close()
+			return nil, &RunningError{PID: readPIDFile(path)}
+		}
+		if isFile(f, path) {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+// isFile reports whether f is still the file at path.
+func isFile(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(path)
+	return err == nil && os.SameFile(fi, pi)
+}
+
+// writePIDFile replaces the content of the locked PID file with pid.
+func writePIDFile(f *os.File, pid int) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(pid)), 0)
+	return err
+}
+
+// Spawn starts name with args as a detached background process (its own
+// session, no terminal, stdin from /dev/null, output appended to LogPath)
+// and records its PID. The child inherits the locked PID file, so it owns it
+// from its first instruction. Spawn refuses with a *RunningError when a live
+// process owns the PID file.
+func Spawn(dataRoot, name string, args ...string) (int, error) {
+	if err := EnsureDataDirs(dataRoot); err != nil {
+		return 0, err
+	}
+	pidFile, err := lockPIDFile(PIDPath(dataRoot))
+	if err != nil {
+		return 0, err
+	}
+	// The child keeps its own copy of the descriptor, and with it the lock.
+	defer pidFile.Close()
+
+	logFile, err := os.OpenFile(LogPath(dataRoot), os.O_WRONLY|os.O_CREATE|os.O_APPEND, store.FileMode)
+	if err != nil {
+		return 0, fmt.Errorf("open log: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.ExtraFiles = []*os.File{pidFile}
+	cmd.Env = append(os.Environ(), pidFDEnv+"=3")
+	cmd.SysProcAttr = detachedAttr()
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start daemon: %w", err)
+	}
+	pid := cmd.Process.Pid
+
+	// The daemon writes its own PID too; writing it here means ReadPID
+	// names the child as soon as Spawn returns.
+	if err := writePIDFile(pidFile, pid); err != nil {
+		cmd.Process.Kill()
+		return 0, fmt.Errorf("write PID: %w", err)
+	}
+	// Reap the child if this process outlives it, so it never lingers as a zombie.
+	go cmd.Wait()
+	return pid, nil
+}
+
+// Stop asks the daemon to exit with SIGTERM, kills it if it is still alive
+// after timeout, and removes its PID and heartbeat files. It returns the
+// stopped PID; a stale PID file, including one whose PID now belongs to an
+// unrelated process, is cleaned up and reported as ErrNotRunning.
+func Stop(dataRoot string, timeout time.Duration) (int, error) {
+	pid, alive := ReadPID(dataRoot)
+	if !alive {
+		removeState(dataRoot)
+		return pid, ErrNotRunning
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return pid, err
+	}
+	if err := terminate(proc); err != nil && IsPIDRunning(pid) {
+		return pid, fmt.Errorf("signal pid %d: %w", pid, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for IsPIDRunning(pid) {
+		if time.Now().After(deadline) {
+			if err := proc.Kill(); err != nil && IsPIDRunning(pid) {
+				return pid, fmt.Errorf("kill pid %d: %w", pid, err)
+			}
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	removeState(dataRoot)
+	return pid, nil
+}
+
+func removeState(dataRoot string) {
+	os.Remove(PIDPath(dataRoot))
+	os.Remove(filepath.Join(dataRoot, "heartbeat", HeartbeatFile))
+	os.Remove(filepath.Join(dataRoot, "heartbeat", LegacyHeartbeatFile))
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+func detachedAttr() *syscall.SysProcAttr { return nil }
+
+// Without SIGTERM there is no graceful stop; Stop falls back to killing.
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
+
+// Without flock, ownership of the PID file rests on the PID alone.
+func lockFile(f *os.File) (bool, error) { return true, nil }
+
+func unlockFile(f *os.File) error { return nil }
+
+func isLocked(path string) bool { return true }
+
+func inheritedFile(env string) *os.File { return nil }
@@ -0,0 +1,97 @@
+//go:build unix
+
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpawnAndStop(t *testing.T) {
+	dataRoot := t.TempDir()
+
+	pid, err := Spawn(dataRoot, "sleep", "30")
+	require.NoError(t, err)
+	got, alive := ReadPID(dataRoot)
+	assert.Equal(t, pid, got)
+	assert.True(t, alive)
+	assert.FileExists(t, LogPath(dataRoot))
+
+	_, err = Spawn(dataRoot, "sleep", "30")
+	require.ErrorIs(t, err, ErrRunning, "a second instance is refused")
+	assert.Equal(t, pid, err.(*RunningError).PID)
+
+	stopped, err := Stop(dataRoot, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, pid, stopped)
+	assert.NoFileExists(t, PIDPath(dataRoot))
+	assert.Eventually(t, func() bool { return !IsPIDRunning(pid) }, time.Second, 10*time.Millisecond)
+}
+
+func TestStopKillsAfterTimeout(t *testing.T) {
+	dataRoot := t.TempDir()
+
+	pid, err := Spawn(dataRoot, "sh", "-c", "trap '' TERM; sleep 30")
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond) // let the shell install its trap
+
+	start := time.Now()
+	_, err = Stop(dataRoot, 300*time.Millisecond)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond, "SIGTERM was ignored, so Stop waited")
+	assert.Eventually(t, func() bool { return !IsPIDRunning(pid) }, time.Second, 10*time.Millisecond)
+}
+
+func TestStopNotRunning(t *testing.T) {
+	dataRoot := t.TempDir()
+
+	_, err := Stop(dataRoot, time.Second)
+	assert.ErrorIs(t, err, ErrNotRunning)
+
+	// A stale PID file is cleaned up.
+	require.NoError(t, EnsureDataDirs(dataRoot))
+	require.NoError(t, os.WriteFile(PIDPath(dataRoot), []byte(strconv.Itoa(1<<22)), 0600))
+	pid, err := Stop(dataRoot, time.Second)
+	assert.ErrorIs(t, err, ErrNotRunning)
+	assert.Equal(t, 1<<22, pid)
+	assert.NoFileExists(t, PIDPath(dataRoot))
+}
+
+func TestStopIgnoresReusedPID(t *testing.T) {
+	dataRoot := t.TempDir()
+	require.NoError(t, EnsureDataDirs(dataRoot))
+
+	// A live process that never owned the PID file, as after a PID is
+	// reused following a crash or reboot.
+	other := exec.Command("sleep", "30")
+	require.NoError(t, other.Start())
+	t.Cleanup(func() {
+		other.Process.Kill()
+		other.Wait()
+	})
+	require.NoError(t, os.WriteFile(PIDPath(dataRoot), []byte(strconv.Itoa(other.Process.Pid)), 0600))
+
+	pid, alive := ReadPID(dataRoot)
+	assert.Equal(t, other.Process.Pid, pid)
+	assert.False(t, alive, "an unlocked PID file is stale")
+
+	_, err := Stop(dataRoot, time.Second)
+	assert.ErrorIs(t, err, ErrNotRunning)
+	assert.True(t, IsPIDRunning(other.Process.Pid), "the unrelated process is not signalled")
+	assert.NoFileExists(t, PIDPath(dataRoot))
+
+	// Spawn takes over the stale file.
+	pid, err = Spawn(dataRoot, "sleep", "30")
+	require.NoError(t, err)
+	_, alive = ReadPID(dataRoot)
+	assert.True(t, alive)
+	_, err = Stop(dataRoot, 5*time.Second)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return !IsPIDRunning(pid) }, time.Second, 10*time.Millisecond)
+}
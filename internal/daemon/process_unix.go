@@ -0,0 +1,71 @@
+//go:build unix
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// detachedAttr puts the daemon in its own session so it survives the
+// terminal that started it.
+func detachedAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+func terminate(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
+
+// lockFile takes an exclusive lock on f without blocking. It reports false
+// when another open file holds the lock.
+func lockFile(f *os.File) (bool, error) {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		switch {
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// isLocked reports whether some process holds the lock on the file at path.
+func isLocked(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	ok, err := lockFile(f)
+	if err != nil {
+		return false
+	}
+	if ok {
+		unlockFile(f)
+	}
+	return !ok
+}
+
+// inheritedFile returns the descriptor named by the environment variable
+// env if the process inherited one, and keeps it from leaking into the
+// commands this process runs.
+func inheritedFile(env string) *os.File {
+	fd, err := strconv.Atoi(os.Getenv(env))
+	os.Unsetenv(env)
+	if err != nil || fd < 3 {
+		return nil
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), env)
+}
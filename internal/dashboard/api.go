@@ -0,0 +1,107 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/giannimassi/jevons/internal/query"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Limits for one page of /api/v1/events.
+const (
+	defaultEventsLimit = 1000
+	maxEventsLimit     = 10000
+)
+
+// handleEvents serves the change feed: events ingested after the "after"
+// cursor, in sequence order, optionally filtered by project, session, model,
+// account and org.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	q := r.URL.Query()
+
+	filter := query.Filter{
+		Project: q.Get("project"),
+		Session: q.Get("session"),
+		Model:   q.Get("model"),
+		Account: q.Get("account"),
+		Org:     q.Get("org"),
+	}
+	after, err := int64Param(q.Get("after"), 0)
+	if err != nil || after < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid after: %q", q.Get("after")))
+		return
+	}
+	filter.AfterSeq = after
+	limit, err := int64Param(q.Get("limit"), defaultEventsLimit)
+	if err != nil || limit < 1 || limit > maxEventsLimit {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxEventsLimit))
+		return
+	}
+
+	page, err := query.ReadFeed(filepath.Join(s.DataRoot, "events.tsv"), s.Vault, filter, int(limit))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// accountsResponse is the /api/v1/accounts document. Totals is keyed by
+// account UUID; "" collects events synced before any account was known.
+type accountsResponse struct {
+	History model.AccountHistory     `json:"history"`
+	Totals  map[string]*query.Totals `json:"totals"`
+}
+
+// handleAccounts serves the account snapshot history with usage totals per
+// account, so the dashboard can label and filter by account.
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	history, err := store.ReadAccounts(filepath.Join(s.DataRoot, store.AccountsFile), s.Vault)
+	if err != nil && !os.IsNotExist(err) {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if history == nil {
+		history = model.AccountHistory{}
+	}
+
+	groups := query.NewGroupBy(func(e model.TokenEvent) string { return e.Account })
+	err = query.Scan(filepath.Join(s.DataRoot, "events.tsv"), s.Vault, query.Filter{}, groups)
+	if err != nil && !os.IsNotExist(err) {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, accountsResponse{History: history, Totals: groups.Groups})
+}
+
+func int64Param(raw string, def int64) (int64, error) {
+	if raw == "" {
+		return def, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,96 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/giannimassi/jevons/internal/query"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleEvents(t *testing.T) {
+	dataRoot := t.TempDir()
+	events := []model.TokenEvent{
+		{TSEpoch: 1000, TSISO: "-", ProjectSlug: "alpha", SessionID: "s1", ContentType: "text", Signature: "a", ID: "e1", Seq: 2, Account: "acct-a"},
+		{TSEpoch: 1001, TSISO: "-", ProjectSlug: "beta", SessionID: "s2", ContentType: "text", Signature: "b", ID: "e2", Seq: 1},
+		{TSEpoch: 1002, TSISO: "-", ProjectSlug: "alpha", SessionID: "s1", ContentType: "text", Signature: "c", ID: "e3", Seq: 3, Account: "acct-a"},
+	}
+	require.NoError(t, store.WriteEventsTSV(filepath.Join(dataRoot, "events.tsv"), events, nil))
+	srv := &Server{DataRoot: dataRoot}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantIDs    []string
+		wantCursor int64
+		wantMore   bool
+	}{
+		{name: "all events in seq order", target: "/api/v1/events", wantStatus: http.StatusOK, wantIDs: []string{"e2", "e1", "e3"}, wantCursor: 3},
+		{name: "after cursor", target: "/api/v1/events?after=1", wantStatus: http.StatusOK, wantIDs: []string{"e1", "e3"}, wantCursor: 3},
+		{name: "limit pages", target: "/api/v1/events?after=0&limit=1", wantStatus: http.StatusOK, wantIDs: []string{"e2"}, wantCursor: 1, wantMore: true},
+		{name: "project filter", target: "/api/v1/events?project=beta", wantStatus: http.StatusOK, wantIDs: []string{"e2"}, wantCursor: 1},
+		{name: "account filter", target: "/api/v1/events?account=acct-a", wantStatus: http.StatusOK, wantIDs: []string{"e1", "e3"}, wantCursor: 3},
+		{name: "caught up", target: "/api/v1/events?after=3", wantStatus: http.StatusOK, wantIDs: []string{}, wantCursor: 3},
+		{name: "bad cursor", target: "/api/v1/events?after=abc", wantStatus: http.StatusBadRequest},
+		{name: "limit too large", target: "/api/v1/events?limit=100000", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.handleEvents(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var page query.Page
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+			ids := []string{}
+			for _, e := range page.Events {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantCursor, page.Cursor)
+			assert.Equal(t, tt.wantMore, page.HasMore)
+		})
+	}
+}
+
+func TestHandleAccounts(t *testing.T) {
+	dataRoot := t.TempDir()
+	srv := &Server{DataRoot: dataRoot}
+
+	rec := httptest.NewRecorder()
+	srv.handleAccounts(rec, httptest.NewRequest(http.MethodGet, "/api/v1/accounts", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"history": [], "totals": {}}`, rec.Body.String())
+
+	events := []model.TokenEvent{
+		{TSEpoch: 1000, TSISO: "-", ProjectSlug: "alpha", SessionID: "s1", Billable: 5, ContentType: "text", Signature: "a", ID: "e1", Seq: 1},
+		{TSEpoch: 1001, TSISO: "-", ProjectSlug: "alpha", SessionID: "s1", Billable: 7, ContentType: "text", Signature: "b", ID: "e2", Seq: 2, Account: "acct-a", Org: "org-1"},
+	}
+	require.NoError(t, store.WriteEventsTSV(filepath.Join(dataRoot, "events.tsv"), events, nil))
+	history := model.AccountHistory{{AccountUUID: "acct-a", OrganizationUUID: "org-1", Email: "a@example.com", FirstSeen: 1001, LastSeen: 1001}}
+	require.NoError(t, store.WriteAccounts(filepath.Join(dataRoot, store.AccountsFile), history, nil))
+
+	rec = httptest.NewRecorder()
+	srv.handleAccounts(rec, httptest.NewRequest(http.MethodGet, "/api/v1/accounts", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got accountsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, history, got.History)
+	require.Contains(t, got.Totals, "acct-a")
+	assert.Equal(t, int64(7), got.Totals["acct-a"].Billable)
+	assert.Equal(t, int64(5), got.Totals[""].Billable, "events from before account tracking")
+}
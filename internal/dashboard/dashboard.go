@@ -5,8 +5,16 @@ import (
 	"embed"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/vault"
 )
 
 //go:embed assets/index.html
@@ -16,13 +24,31 @@ var dashboardFS embed.FS
 type Server struct {
 	Port     int
 	DataRoot string
-	server   *http.Server
+	Vault    *vault.Vault // Decrypts sealed data files; nil for plaintext stores
+	Listener net.Listener // Serve on this instead of listening on Port, e.g. a systemd socket
+
+	// MetricsProjects lists the projects (globs on slug or path) that get
+	// their own label on /metrics; the rest are summed as "other". Empty
+	// labels every project.
+	MetricsProjects []string
+
+	server  *http.Server
+	started time.Time
+	metrics metrics
 }
 
 // Start starts the HTTP server.
 // Routes:
 //   - /dashboard/ → embedded dashboard HTML
-//   - / → data files from DataRoot (events.tsv, projects.json, etc.)
+//   - /api/v1/events → change feed of events ingested after a cursor
+//   - /api/v1/accounts → account snapshot history and usage per account
+//   - /api/v1/totals, series, tree, sessions, live → usage aggregated by range, scope and metric
+//   - /healthz, /readyz → liveness and readiness with heartbeat details
+//   - /metrics → Prometheus metrics for usage, syncs and HTTP requests
+//   - / → the dashboard's data files from DataRoot (projects.json, account.json,
+//     sync-status.json, heartbeat/), decrypted if sealed; nothing else
+//
+// Requests must name a loopback host.
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 
@@ -31,19 +57,39 @@ func (s *Server) Start() error {
 		return fmt.Errorf("embed sub: %w", err)
 	}
 	mux.Handle("/dashboard/", http.StripPrefix("/dashboard/", http.FileServer(http.FS(sub))))
-	mux.Handle("/", http.FileServer(http.Dir(s.DataRoot)))
+	mux.HandleFunc("/api/v1/events", s.handleEvents)
+	mux.HandleFunc("/api/v1/accounts", s.handleAccounts)
+	mux.HandleFunc("/api/v1/totals", s.handleTotals)
+	mux.HandleFunc("/api/v1/series", s.handleSeries)
+	mux.HandleFunc("/api/v1/tree", s.handleTree)
+	mux.HandleFunc("/api/v1/sessions", s.handleSessions)
+	mux.HandleFunc("/api/v1/live", s.handleLive)
+	mux.HandleFunc("/api/v1/view", s.handleView)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.Handle("/", http.FileServer(servedFS{dataFS{root: http.Dir(s.DataRoot), vault: s.Vault}}))
 
 	s.server = &http.Server{
 		Addr:    fmt.Sprintf("127.0.0.1:%d", s.Port),
-		Handler: mux,
+		Handler: s.observe(localOnly(mux)),
 	}
 
-	ln, err := net.Listen("tcp", s.server.Addr)
-	if err != nil {
-		return fmt.Errorf("listen on port %d: %w", s.Port, err)
+	ln := s.Listener
+	if ln == nil {
+		ln, err = net.Listen("tcp", s.server.Addr)
+		if err != nil {
+			return fmt.Errorf("listen on port %d: %w", s.Port, err)
+		}
 	}
 
-	go s.server.Serve(ln)
+	s.started = time.Now()
+	slog.Info("dashboard listening", "addr", ln.Addr().String())
+	go func() {
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			slog.Error("dashboard server stopped", "err", err)
+		}
+	}()
 	return nil
 }
 
@@ -54,3 +100,34 @@ func (s *Server) Stop(ctx context.Context) error {
 	}
 	return nil
 }
+
+// Proxy forwards requests to the dashboard server on port, for the app's
+// webview. The webview's own Host header isn't a loopback name, so requests
+// are sent with the server's.
+func Proxy(port int) http.Handler {
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+	return &httputil.ReverseProxy{
+		Rewrite:       func(r *httputil.ProxyRequest) { r.SetURL(target) },
+		FlushInterval: -1, // Immediate flush for SSE
+	}
+}
+
+// SystemdListener returns the first socket passed by systemd socket
+// activation (LISTEN_PID/LISTEN_FDS), or nil when the process was not
+// socket-activated.
+func SystemdListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	if n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS")); n < 1 {
+		return nil, nil
+	}
+	// Passed sockets start at fd 3 (SD_LISTEN_FDS_START).
+	f := os.NewFile(3, "systemd-socket")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket: %w", err)
+	}
+	return ln, nil
+}
@@ -1,17 +1,20 @@
 package dashboard
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/giannimassi/jevons/internal/vault"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -52,6 +55,11 @@ func TestServerRoutes(t *testing.T) {
 		[]byte(`[{"slug":"test","path":"/test"}]`),
 		0644,
 	))
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "config.json"), []byte(`{"hooks":[{"secret":"s3cret"}]}`), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dataRoot, "logs"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "logs", "jevons.log"), []byte("level=INFO\n"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dataRoot, "heartbeat"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "heartbeat", "sync.json"), []byte(`{"epoch":1234}`), 0600))
 
 	srv := &Server{Port: 0, DataRoot: dataRoot}
 
@@ -79,18 +87,38 @@ func TestServerRoutes(t *testing.T) {
 			wantStatus: http.StatusOK,
 			wantBody:   "<!doctype html>",
 		},
-		{
-			name:       "events.tsv from data root",
-			path:       "/events.tsv",
-			wantStatus: http.StatusOK,
-			wantBody:   "ts_epoch",
-		},
 		{
 			name:       "projects.json from data root",
 			path:       "/projects.json",
 			wantStatus: http.StatusOK,
 			wantBody:   "test",
 		},
+		{
+			name:       "heartbeat from data root",
+			path:       "/heartbeat/sync.json",
+			wantStatus: http.StatusOK,
+			wantBody:   "epoch",
+		},
+		{
+			name:       "events.tsv only through the API",
+			path:       "/events.tsv",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "config.json is never served",
+			path:       "/config.json",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "logs are never served",
+			path:       "/logs/jevons.log",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "no directory listings",
+			path:       "/",
+			wantStatus: http.StatusNotFound,
+		},
 		{
 			name:       "nonexistent file returns 404",
 			path:       "/nonexistent.txt",
@@ -115,6 +143,78 @@ func TestServerRoutes(t *testing.T) {
 	}
 }
 
+func TestServerDecryptsSealedFiles(t *testing.T) {
+	dataRoot := t.TempDir()
+
+	v, err := vault.New(bytes.Repeat([]byte{3}, 32))
+	require.NoError(t, err)
+	sealed, err := v.Seal([]byte(`{"email":"a@example.com"}`))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "account.json"), sealed, 0600))
+
+	tests := []struct {
+		name       string
+		vault      *vault.Vault
+		wantStatus int
+	}{
+		{name: "with key serves plaintext", vault: v, wantStatus: http.StatusOK},
+		{name: "without key is forbidden", vault: nil, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.FileServer(servedFS{dataFS{root: http.Dir(dataRoot), vault: tt.vault}})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/account.json", nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Contains(t, rec.Body.String(), "a@example.com")
+			}
+		})
+	}
+}
+
+func TestLocalOnly(t *testing.T) {
+	h := localOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		host       string
+		wantStatus int
+	}{
+		{host: "127.0.0.1:8765", wantStatus: http.StatusOK},
+		{host: "localhost:8765", wantStatus: http.StatusOK},
+		{host: "[::1]:8765", wantStatus: http.StatusOK},
+		{host: "localhost", wantStatus: http.StatusOK},
+		{host: "evil.example.com:8765", wantStatus: http.StatusForbidden},
+		{host: "192.168.1.10:8765", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/projects.json", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestProxyReachesServer(t *testing.T) {
+	port := findFreePort(t)
+	srv := &Server{Port: port, DataRoot: t.TempDir()}
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+
+	// The app's webview names its own host, which the server would reject.
+	req := httptest.NewRequest(http.MethodGet, "http://wails/healthz", nil)
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		Proxy(port).ServeHTTP(rec, req)
+		return rec.Code == http.StatusOK
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
 func findFreePort(t *testing.T) int {
 	t.Helper()
 	// Start from a high port range to avoid conflicts
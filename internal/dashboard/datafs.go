@@ -0,0 +1,97 @@
+package dashboard
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/giannimassi/jevons/internal/vault"
+)
+
+// servedFiles are the data-root files the dashboard reads directly. Anything
+// else (events, config.json with hook secrets, vault.json, logs, backups) is
+// only reachable through the API, or not at all.
+var servedFiles = map[string]bool{
+	"/projects.json":       true,
+	"/account.json":        true,
+	"/sync-status.json":    true,
+	"/ui-context.json":     true,
+	"/heartbeat/sync.json": true,
+	"/heartbeat/sync.txt":  true,
+}
+
+// servedFS is the part of dataFS the server exposes: the servedFiles.
+type servedFS struct{ dataFS }
+
+func (s servedFS) Open(name string) (http.File, error) {
+	if !servedFiles[path.Clean("/"+name)] {
+		return nil, fs.ErrNotExist
+	}
+	return s.dataFS.Open(name)
+}
+
+// dataFS opens files of DataRoot, decrypting sealed files on the fly so the
+// dashboard sees plaintext regardless of the store's encryption mode.
+type dataFS struct {
+	root  http.Dir
+	vault *vault.Vault
+}
+
+func (d dataFS) Open(name string) (http.File, error) {
+	f, err := d.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return f, nil
+	}
+
+	// Plaintext files are streamed as-is; only sealed ones are buffered.
+	head := make([]byte, 16)
+	n, _ := io.ReadFull(f, head)
+	if !vault.IsSealed(head[:n]) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
+	rest, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	data := append(head[:n], rest...)
+	plain, err := d.vault.Open(data)
+	if err != nil {
+		return nil, fs.ErrPermission
+	}
+	return &memFile{Reader: bytes.NewReader(plain), info: sizedInfo{FileInfo: info, size: int64(len(plain))}}, nil
+}
+
+// memFile is an in-memory http.File for a single regular file.
+type memFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+func (m *memFile) Close() error                             { return nil }
+func (m *memFile) Stat() (os.FileInfo, error)               { return m.info, nil }
+func (m *memFile) Readdir(count int) ([]os.FileInfo, error) { return nil, fs.ErrInvalid }
+
+// sizedInfo reports the decrypted size in place of the on-disk size.
+type sizedInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (s sizedInfo) Size() int64 { return s.size }
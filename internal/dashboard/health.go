@@ -0,0 +1,176 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/daemon"
+)
+
+// ProbeTimeout bounds each request of Probe.
+const ProbeTimeout = time.Second
+
+// readyFiles are the data files the dashboard needs to render anything.
+var readyFiles = []string{"events.tsv", "projects.json"}
+
+// Health is the body of /healthz: the process is up and serving.
+type Health struct {
+	Status    string                 `json:"status"` // Always "ok"
+	PID       int                    `json:"pid"`
+	Uptime    int64                  `json:"uptime_s"`
+	Heartbeat *daemon.HeartbeatState `json:"heartbeat"` // nil before the first sync
+}
+
+// Readiness is the body of /readyz, served with 200 when Ready and 503
+// otherwise.
+type Readiness struct {
+	Ready     bool                   `json:"ready"`
+	Checks    []Check                `json:"checks"`
+	Heartbeat *daemon.HeartbeatState `json:"heartbeat"`
+}
+
+// Check is one readiness condition.
+type Check struct {
+	Name   string `json:"name"` // "heartbeat", "sync" or "data"
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Failed returns the failing checks as "name: detail", joined by "; ".
+func (r *Readiness) Failed() string {
+	var failed []string
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, c.Name+": "+c.Detail)
+		}
+	}
+	return strings.Join(failed, "; ")
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Health{
+		Status:    "ok",
+		PID:       os.Getpid(),
+		Uptime:    int64(time.Since(s.started).Seconds()),
+		Heartbeat: daemon.ReadHeartbeatState(s.DataRoot),
+	})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready := s.readiness()
+	status := http.StatusOK
+	if !ready.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, ready)
+}
+
+// readiness checks that the sync loop is alive, its last sync succeeded
+// recently, and the data files can be read (and decrypted).
+func (s *Server) readiness() Readiness {
+	hb := daemon.ReadHeartbeatState(s.DataRoot)
+	r := Readiness{Heartbeat: hb}
+	r.Checks = append(r.Checks, heartbeatCheck(hb), syncCheck(hb), s.dataCheck())
+	r.Ready = true
+	for _, c := range r.Checks {
+		r.Ready = r.Ready && c.OK
+	}
+	return r
+}
+
+func heartbeatCheck(hb *daemon.HeartbeatState) Check {
+	c := Check{Name: "heartbeat"}
+	switch {
+	case hb == nil:
+		c.Detail = "no heartbeat"
+	case hb.Mode != "running":
+		c.Detail = fmt.Sprintf("stale, last written %ds ago", hb.Age)
+	default:
+		c.OK = true
+		c.Detail = fmt.Sprintf("written %ds ago", hb.Age)
+	}
+	return c
+}
+
+func syncCheck(hb *daemon.HeartbeatState) Check {
+	c := Check{Name: "sync"}
+	switch {
+	case hb == nil:
+		c.Detail = "no sync yet"
+	case hb.Status == "error" || hb.Status == "paused" || hb.ConsecutiveFailures > 0:
+		c.Detail = fmt.Sprintf("%d consecutive failures: %s", max(hb.ConsecutiveFailures, 1), hb.LastError)
+	case hb.Legacy:
+		// The CSV heartbeat has no success time; its status has to do.
+		c.OK = hb.Status == "ok"
+		c.Detail = "status " + hb.Status
+	case hb.LastSuccessEpoch == 0:
+		c.Detail = "no successful sync yet"
+	default:
+		age := time.Now().Unix() - hb.LastSuccessEpoch
+		c.Detail = fmt.Sprintf("last succeeded %ds ago", age)
+		c.OK = age <= int64(hb.StaleAfter())
+	}
+	return c
+}
+
+func (s *Server) dataCheck() Check {
+	c := Check{Name: "data"}
+	fsys := dataFS{root: http.Dir(s.DataRoot), vault: s.Vault}
+	for _, name := range readyFiles {
+		f, err := fsys.Open("/" + name)
+		if err == nil {
+			_, err = f.Read(make([]byte, 1))
+			f.Close()
+		}
+		if err != nil && err != io.EOF {
+			c.Detail = fmt.Sprintf("%s: %v", name, err)
+			return c
+		}
+	}
+	c.OK = true
+	c.Detail = strings.Join(readyFiles, ", ") + " readable"
+	return c
+}
+
+// Probe asks the dashboard at baseURL (e.g. http://127.0.0.1:8765) for
+// /healthz and /readyz. An error means the server isn't responding; a
+// server that responds but isn't ready returns its Readiness with Ready false.
+func Probe(ctx context.Context, baseURL string) (*Health, *Readiness, error) {
+	var health Health
+	if _, err := probeJSON(ctx, baseURL+"/healthz", &health); err != nil {
+		return nil, nil, err
+	}
+	var ready Readiness
+	status, err := probeJSON(ctx, baseURL+"/readyz", &ready)
+	if err != nil {
+		return &health, nil, err
+	}
+	if status != http.StatusOK && status != http.StatusServiceUnavailable {
+		return &health, nil, fmt.Errorf("GET /readyz: unexpected status %d", status)
+	}
+	return &health, &ready, nil
+}
+
+func probeJSON(ctx context.Context, url string, v any) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("GET %s: %w", url, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,148 @@
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeHealthFixture writes a heartbeat and, with data, the files readyz reads.
+func writeHealthFixture(t *testing.T, dataRoot string, hb *daemon.Heartbeat, data bool) {
+	t.Helper()
+	if hb != nil {
+		require.NoError(t, os.MkdirAll(filepath.Join(dataRoot, "heartbeat"), 0700))
+		raw, err := json.Marshal(hb)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "heartbeat", daemon.HeartbeatFile), raw, 0600))
+	}
+	if data {
+		require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "events.tsv"), []byte("ts_epoch\n"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "projects.json"), []byte("[]"), 0600))
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	now := time.Now().Unix()
+	healthy := &daemon.Heartbeat{Epoch: now, Interval: 15, PID: 1, Status: "ok", LastSuccessEpoch: now}
+
+	tests := []struct {
+		name      string
+		heartbeat *daemon.Heartbeat
+		data      bool
+		wantReady bool
+		wantFail  string
+	}{
+		{name: "healthy", heartbeat: healthy, data: true, wantReady: true},
+		{name: "no heartbeat", data: true, wantFail: "heartbeat: no heartbeat"},
+		{
+			name:      "stale heartbeat",
+			heartbeat: &daemon.Heartbeat{Epoch: now - 3600, Interval: 15, Status: "ok", LastSuccessEpoch: now - 3600},
+			data:      true,
+			wantFail:  "heartbeat: stale",
+		},
+		{
+			name:      "failing syncs",
+			heartbeat: &daemon.Heartbeat{Epoch: now, Interval: 15, Status: "error", ConsecutiveFailures: 3, LastError: "disk full", LastSuccessEpoch: now - 60},
+			data:      true,
+			wantFail:  "sync: 3 consecutive failures: disk full",
+		},
+		{
+			name:      "no successful sync yet",
+			heartbeat: &daemon.Heartbeat{Epoch: now, Interval: 15, Status: "working"},
+			data:      true,
+			wantFail:  "sync: no successful sync yet",
+		},
+		{
+			name:      "last success too old",
+			heartbeat: &daemon.Heartbeat{Epoch: now, Interval: 15, Status: "working", LastSuccessEpoch: now - 3600},
+			data:      true,
+			wantFail:  "sync: last succeeded",
+		},
+		{name: "missing data files", heartbeat: healthy, wantFail: "data: events.tsv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataRoot := t.TempDir()
+			writeHealthFixture(t, dataRoot, tt.heartbeat, tt.data)
+			srv := &Server{DataRoot: dataRoot}
+
+			rec := httptest.NewRecorder()
+			srv.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var got Readiness
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.wantReady, got.Ready)
+			require.Len(t, got.Checks, 3)
+			if tt.wantReady {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Empty(t, got.Failed())
+				return
+			}
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.Contains(t, got.Failed(), tt.wantFail)
+		})
+	}
+}
+
+func TestReadyzSealedData(t *testing.T) {
+	dataRoot := t.TempDir()
+	now := time.Now().Unix()
+	writeHealthFixture(t, dataRoot, &daemon.Heartbeat{Epoch: now, Interval: 15, Status: "ok", LastSuccessEpoch: now}, true)
+	v, err := vault.New(bytes.Repeat([]byte{5}, 32))
+	require.NoError(t, err)
+	sealed, err := v.Seal([]byte("ts_epoch\n"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, "events.tsv"), sealed, 0600))
+
+	ready := (&Server{DataRoot: dataRoot, Vault: v}).readiness()
+	assert.True(t, ready.Ready, ready.Failed())
+
+	// Without the key the data can't be served.
+	ready = (&Server{DataRoot: dataRoot}).readiness()
+	assert.False(t, ready.Ready)
+	assert.Contains(t, ready.Failed(), "data: events.tsv")
+}
+
+func TestProbe(t *testing.T) {
+	dataRoot := t.TempDir()
+	now := time.Now().Unix()
+	writeHealthFixture(t, dataRoot, &daemon.Heartbeat{Epoch: now, Interval: 15, PID: 42, Status: "ok", LastSuccessEpoch: now}, false)
+
+	port := findFreePort(t)
+	srv := &Server{Port: port, DataRoot: dataRoot}
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	health, ready, err := Probe(context.Background(), base)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", health.Status)
+	assert.Equal(t, os.Getpid(), health.PID)
+	require.NotNil(t, health.Heartbeat)
+	assert.Equal(t, "42", health.Heartbeat.PID)
+	assert.False(t, ready.Ready)
+	assert.Contains(t, ready.Failed(), "data: ")
+
+	writeHealthFixture(t, dataRoot, nil, true)
+	_, ready, err = Probe(context.Background(), base)
+	require.NoError(t, err)
+	assert.True(t, ready.Ready)
+
+	require.NoError(t, srv.Stop(context.Background()))
+	health, _, err = Probe(context.Background(), base)
+	assert.Error(t, err)
+	assert.Nil(t, health)
+}
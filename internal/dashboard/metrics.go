@@ -0,0 +1,290 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/giannimassi/jevons/internal/query"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Label values for projects outside the allowlist and for events synced
+// before model names were kept.
+const (
+	otherProject = "other"
+	unknownModel = "unknown"
+)
+
+// tokenTypes are the values of the type label of jevons_tokens_total.
+var tokenTypes = []string{"input", "output", "cache_read", "cache_create"}
+
+// usageKey identifies one series of the usage metrics.
+type usageKey struct {
+	project, model string
+}
+
+// usage holds the usage metrics computed from one version of events.tsv.
+type usage struct {
+	modTime time.Time
+	size    int64
+	tokens  map[usageKey][4]int64 // Indexed like tokenTypes
+	cost    map[usageKey]float64
+}
+
+// requestKey identifies one series of jevons_http_requests_total.
+type requestKey struct {
+	handler, method string
+	code            int
+}
+
+// metrics is the state behind /metrics: request counts of this process,
+// and usage totals cached until events.tsv changes.
+type metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	usage    *usage
+}
+
+func (m *metrics) countRequest(handler, method string, code int) {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions:
+	default:
+		method = "other"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests == nil {
+		m.requests = make(map[requestKey]uint64)
+	}
+	m.requests[requestKey{handler, method, code}]++
+}
+
+// handleMetrics serves the Prometheus text exposition format: token and
+// cost counters per project, model and token type, the state of the last
+// sync, and this server's request counts.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	u, err := s.usage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var b expfmt
+	b.family("jevons_tokens_total", "counter", "Tokens used, by project, model and token type.")
+	for _, k := range sortedUsageKeys(u.tokens) {
+		for i, typ := range tokenTypes {
+			b.sample("jevons_tokens_total", float64(u.tokens[k][i]), "project", k.project, "model", k.model, "type", typ)
+		}
+	}
+	b.family("jevons_cost_usd_total", "counter", "Estimated cost in USD at list prices, by project and model; models without a known price are left out.")
+	for _, k := range sortedUsageKeys(u.cost) {
+		b.sample("jevons_cost_usd_total", u.cost[k], "project", k.project, "model", k.model)
+	}
+
+	s.writeSyncMetrics(&b)
+
+	b.family("jevons_http_requests_total", "counter", "HTTP requests served by the dashboard server, by route, method and status code.")
+	s.metrics.mu.Lock()
+	keys := make([]requestKey, 0, len(s.metrics.requests))
+	for k := range s.metrics.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.handler != b.handler {
+			return a.handler < b.handler
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	for _, k := range keys {
+		b.sample("jevons_http_requests_total", float64(s.metrics.requests[k]),
+			"handler", k.handler, "method", k.method, "code", strconv.Itoa(k.code))
+	}
+	s.metrics.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// writeSyncMetrics adds the operational metrics the sync loop leaves in
+// sync-status.json and the heartbeat, so they are right whichever process
+// syncs.
+func (s *Server) writeSyncMetrics(b *expfmt) {
+	var status struct {
+		LastSyncEpoch int64 `json:"last_sync_epoch"`
+		DurationMS    int64 `json:"duration_ms"`
+		NewEvents     int64 `json:"new_events"`
+		LastSeq       int64 `json:"last_seq"`
+		ParseErrors   int64 `json:"parse_errors"`
+		SessionFiles  int64 `json:"session_files"`
+	}
+	if data, err := os.ReadFile(filepath.Join(s.DataRoot, store.SyncStatusFile)); err == nil {
+		_ = json.Unmarshal(data, &status)
+	}
+	gauges := []struct {
+		name, help string
+		value      float64
+	}{
+		{"jevons_sync_last_timestamp_seconds", "Unix time the last successful sync finished.", float64(status.LastSyncEpoch)},
+		{"jevons_sync_duration_seconds", "Duration of the last successful sync.", float64(status.DurationMS) / 1000},
+		{"jevons_sync_new_events", "Events ingested by the last successful sync.", float64(status.NewEvents)},
+		{"jevons_sync_parse_errors", "Session files the last successful sync could not read or parse.", float64(status.ParseErrors)},
+		{"jevons_sync_session_files", "Session files seen by the last successful sync.", float64(status.SessionFiles)},
+	}
+	for _, g := range gauges {
+		b.family(g.name, "gauge", g.help)
+		b.sample(g.name, g.value)
+	}
+	b.family("jevons_events_ingested_total", "counter", "Events ingested since the data root was created (the ingest sequence high-water mark).")
+	b.sample("jevons_events_ingested_total", float64(status.LastSeq))
+
+	var failures float64
+	if hb := daemon.ReadHeartbeatState(s.DataRoot); hb != nil {
+		failures = float64(hb.ConsecutiveFailures)
+		b.family("jevons_sync_heartbeat_timestamp_seconds", "gauge", "Unix time the sync loop last wrote its heartbeat.")
+		b.sample("jevons_sync_heartbeat_timestamp_seconds", float64(hb.Epoch))
+	}
+	b.family("jevons_sync_consecutive_failures", "gauge", "Syncs that failed in a row; 0 after a success.")
+	b.sample("jevons_sync_consecutive_failures", failures)
+}
+
+// usage returns the usage totals of events.tsv, recomputed only when the
+// file changed since the last scrape.
+func (s *Server) usage() (*usage, error) {
+	path := filepath.Join(s.DataRoot, "events.tsv")
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return &usage{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.metrics.mu.Lock()
+	cached := s.metrics.usage
+	s.metrics.mu.Unlock()
+	if cached != nil && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached, nil
+	}
+
+	label, err := s.projectLabeler()
+	if err != nil {
+		return nil, err
+	}
+	u := &usage{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		tokens:  make(map[usageKey][4]int64),
+		cost:    make(map[usageKey]float64),
+	}
+	err = query.Scan(path, s.Vault, query.Filter{}, query.AggregatorFunc(func(e model.TokenEvent) {
+		k := usageKey{project: label(e.ProjectSlug), model: e.Model}
+		if k.model == "" {
+			k.model = unknownModel
+		}
+		t := u.tokens[k]
+		t[0] += e.Input
+		t[1] += e.Output
+		t[2] += e.CacheRead
+		t[3] += e.CacheCreate
+		u.tokens[k] = t
+		if p, ok := model.PriceFor(e.Model); ok {
+			u.cost[k] += p.Cost(e)
+		}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("scan events: %w", err)
+	}
+
+	s.metrics.mu.Lock()
+	s.metrics.usage = u
+	s.metrics.mu.Unlock()
+	return u, nil
+}
+
+// projectLabeler returns the project label for a slug: the slug itself when
+// the project is on the MetricsProjects allowlist (or there is none), and
+// "other" otherwise.
+func (s *Server) projectLabeler() (func(slug string) string, error) {
+	if len(s.MetricsProjects) == 0 {
+		return func(slug string) string { return slug }, nil
+	}
+	projects, err := store.ReadProjects(filepath.Join(s.DataRoot, "projects.json"), s.Vault)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read projects.json: %w", err)
+	}
+	paths := make(map[string]string, len(projects))
+	for _, p := range projects {
+		paths[p.Slug] = p.Path
+	}
+	rules := model.ProjectRules{Include: s.MetricsProjects}
+	labels := make(map[string]string)
+	return func(slug string) string {
+		l, ok := labels[slug]
+		if !ok {
+			l = otherProject
+			if match, _ := rules.Match(slug, paths[slug]); match {
+				l = slug
+			}
+			labels[slug] = l
+		}
+		return l
+	}, nil
+}
+
+func sortedUsageKeys[V any](m map[usageKey]V) []usageKey {
+	keys := make([]usageKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].project != keys[j].project {
+			return keys[i].project < keys[j].project
+		}
+		return keys[i].model < keys[j].model
+	})
+	return keys
+}
+
+// expfmt builds a Prometheus text exposition.
+type expfmt struct {
+	bytes.Buffer
+}
+
+func (b *expfmt) family(name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes one sample; labels are name, value pairs.
+func (b *expfmt) sample(name string, value float64, labels ...string) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	b.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,155 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeMetricsFixture(t *testing.T, dataRoot string) {
+	t.Helper()
+	events := []model.TokenEvent{
+		{TSEpoch: 1000, TSISO: "-", ProjectSlug: "-code-alpha", SessionID: "s1", Input: 1000, Output: 2000, CacheRead: 1e6, CacheCreate: 10000, ContentType: "text", Signature: "a", Model: "claude-sonnet-4-5-20250929"},
+		{TSEpoch: 1001, TSISO: "-", ProjectSlug: "-code-alpha", SessionID: "s1", Input: 10, Output: 20, ContentType: "text", Signature: "b", Model: "claude-sonnet-4-5-20250929"},
+		{TSEpoch: 1002, TSISO: "-", ProjectSlug: "-tmp-beta", SessionID: "s2", Input: 5, Output: 5, ContentType: "text", Signature: "c"},
+		{TSEpoch: 1003, TSISO: "-", ProjectSlug: "-tmp-gamma", SessionID: "s3", Input: 1e6, Output: 0, ContentType: "text", Signature: "d", Model: "claude-3-5-haiku-20241022"},
+	}
+	require.NoError(t, store.WriteEventsTSV(filepath.Join(dataRoot, "events.tsv"), events, nil))
+	require.NoError(t, store.WriteProjects(filepath.Join(dataRoot, "projects.json"), []model.Project{
+		{Slug: "-code-alpha", Path: "/code/alpha"},
+		{Slug: "-tmp-beta", Path: "/tmp/beta"},
+		{Slug: "-tmp-gamma", Path: "/tmp/gamma"},
+	}, nil))
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, store.SyncStatusFile),
+		[]byte(`{"last_sync_epoch": 1700000000, "duration_ms": 1500, "new_events": 4, "last_seq": 42, "parse_errors": 2, "session_files": 3}`), 0600))
+}
+
+func scrape(t *testing.T, srv *Server) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	return rec.Body.String()
+}
+
+func TestHandleMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist []string
+		want      []string
+		notWant   []string
+	}{
+		{
+			name: "every project labelled",
+			want: []string{
+				`jevons_tokens_total{project="-code-alpha",model="claude-sonnet-4-5-20250929",type="input"} 1010`,
+				`jevons_tokens_total{project="-code-alpha",model="claude-sonnet-4-5-20250929",type="cache_read"} 1e+06`,
+				`jevons_tokens_total{project="-tmp-beta",model="unknown",type="output"} 5`,
+				`jevons_cost_usd_total{project="-code-alpha",model="claude-sonnet-4-5-20250929"} 0.37083`,
+				`jevons_cost_usd_total{project="-tmp-gamma",model="claude-3-5-haiku-20241022"} 0.8`,
+			},
+			notWant: []string{`project="other"`, `jevons_cost_usd_total{project="-tmp-beta"`},
+		},
+		{
+			name:      "allowlist folds the rest into other",
+			allowlist: []string{"/code/**"},
+			want: []string{
+				`jevons_tokens_total{project="-code-alpha",model="claude-sonnet-4-5-20250929",type="output"} 2020`,
+				`jevons_tokens_total{project="other",model="unknown",type="input"} 5`,
+				`jevons_tokens_total{project="other",model="claude-3-5-haiku-20241022",type="input"} 1e+06`,
+			},
+			notWant: []string{"-tmp-beta", "-tmp-gamma"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataRoot := t.TempDir()
+			writeMetricsFixture(t, dataRoot)
+			out := scrape(t, &Server{DataRoot: dataRoot, MetricsProjects: tt.allowlist})
+
+			assert.Contains(t, out, "# TYPE jevons_tokens_total counter\n")
+			for _, s := range tt.want {
+				assert.Contains(t, out, s+"\n")
+			}
+			for _, s := range tt.notWant {
+				assert.NotContains(t, out, s)
+			}
+			for _, s := range []string{
+				"jevons_sync_last_timestamp_seconds 1.7e+09\n",
+				"jevons_sync_duration_seconds 1.5\n",
+				"jevons_sync_parse_errors 2\n",
+				"jevons_events_ingested_total 42\n",
+				"jevons_sync_consecutive_failures 0\n",
+			} {
+				assert.Contains(t, out, s)
+			}
+		})
+	}
+}
+
+func TestHandleMetricsEmptyDataRoot(t *testing.T) {
+	out := scrape(t, &Server{DataRoot: t.TempDir()})
+	assert.Contains(t, out, "# TYPE jevons_tokens_total counter\n")
+	assert.Contains(t, out, "jevons_events_ingested_total 0\n")
+	assert.NotContains(t, out, "jevons_tokens_total{")
+}
+
+func TestMetricsUsageCache(t *testing.T) {
+	dataRoot := t.TempDir()
+	writeMetricsFixture(t, dataRoot)
+	srv := &Server{DataRoot: dataRoot}
+	first, err := srv.usage()
+	require.NoError(t, err)
+	again, err := srv.usage()
+	require.NoError(t, err)
+	assert.Same(t, first, again, "unchanged events.tsv is not rescanned")
+
+	events := []model.TokenEvent{{TSEpoch: 2000, TSISO: "-", ProjectSlug: "-code-alpha", SessionID: "s9", Input: 1, ContentType: "text", Signature: "z"}}
+	require.NoError(t, store.WriteEventsTSV(filepath.Join(dataRoot, "events.tsv"), events, nil))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dataRoot, "events.tsv"), future, future))
+	changed, err := srv.usage()
+	require.NoError(t, err)
+	assert.NotSame(t, first, changed)
+	assert.Len(t, changed.tokens, 1)
+}
+
+func TestMetricsCountsRequests(t *testing.T) {
+	dataRoot := t.TempDir()
+	writeMetricsFixture(t, dataRoot)
+	port := findFreePort(t)
+	srv := &Server{Port: port, DataRoot: dataRoot}
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	for _, path := range []string{"/sync-status.json", "/projects.json", "/events.tsv", "/healthz", "/api/v1/events?after=x"} {
+		resp, err := http.Get(base + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	resp, err := http.Get(base + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	out := string(body)
+	assert.Contains(t, out, `jevons_http_requests_total{handler="/",method="GET",code="200"} 2`+"\n")
+	assert.Contains(t, out, `jevons_http_requests_total{handler="/",method="GET",code="404"} 1`+"\n")
+	assert.Contains(t, out, `jevons_http_requests_total{handler="/healthz",method="GET",code="200"} 1`+"\n")
+	assert.Contains(t, out, `jevons_http_requests_total{handler="/api/v1/events",method="GET",code="400"} 1`+"\n")
+}
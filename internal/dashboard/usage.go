@@ -0,0 +1,699 @@
+package dashboard
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/query"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Limits for /api/v1/sessions and /api/v1/live.
+const (
+	defaultSessionsLimit = 100
+	maxSessionsLimit     = 10000
+	defaultLiveLimit     = 80
+	maxLiveLimit         = 1000
+)
+
+// metricCost selects the estimated cost in USD instead of a token count.
+const metricCost = "cost"
+
+// metrics the usage endpoints accept: the token columns and metricCost.
+var usageMetrics = []string{"billable", "input", "output", "cache_read", "cache_create", "total_with_cache", metricCost}
+
+// fallbackPrice prices events whose model is unknown, including those synced
+// before model names were kept, at the Sonnet rates the dashboard always used.
+var fallbackPrice = model.Price{Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75}
+
+// Query holds the parameters shared by the usage endpoints, echoed back in
+// each response.
+type Query struct {
+	Range  string `json:"range"`  // Named range, e.g. "30m", "24h", "7d" or "all"
+	Scope  string `json:"scope"`  // "all", or "slug:", "repo:" or "path:" followed by a value
+	Metric string `json:"metric"` // Token column or "cost"; what "value" holds
+	Since  int64  `json:"since"`  // First epoch included; 0 for all time
+	Until  int64  `json:"until"`  // Time of the query
+
+	inScope func(slug string) bool
+}
+
+// Cost is an estimated cost in USD, split by token type.
+type Cost struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	Cache  float64 `json:"cache"` // Cache reads and writes
+	Total  float64 `json:"total"`
+}
+
+// Usage sums the events of a range, bucket, project or session.
+type Usage struct {
+	query.Totals
+	Cost       Cost    `json:"cost"`
+	Value      float64 `json:"value"` // The queried metric
+	FirstEpoch int64   `json:"first_epoch,omitempty"`
+	LastEpoch  int64   `json:"last_epoch,omitempty"`
+}
+
+func (u *Usage) add(e model.TokenEvent) {
+	u.Totals.Add(e)
+	p, ok := model.PriceFor(e.Model)
+	if !ok {
+		p = fallbackPrice
+	}
+	in := float64(e.Input) * p.Input / 1e6
+	out := float64(e.Output) * p.Output / 1e6
+	cache := (float64(e.CacheRead)*p.CacheRead + float64(e.CacheCreate)*p.CacheWrite) / 1e6
+	u.Cost.Input += in
+	u.Cost.Output += out
+	u.Cost.Cache += cache
+	u.Cost.Total += in + out + cache
+	if u.FirstEpoch == 0 || e.TSEpoch < u.FirstEpoch {
+		u.FirstEpoch = e.TSEpoch
+	}
+	if e.TSEpoch > u.LastEpoch {
+		u.LastEpoch = e.TSEpoch
+	}
+}
+
+// finish sets Value to the queried metric.
+func (u *Usage) finish(metric string) {
+	if metric == metricCost {
+		u.Value = u.Cost.Total
+		return
+	}
+	u.Value = float64(u.Totals.Value(metric))
+}
+
+// TotalsResponse is the /api/v1/totals document.
+type TotalsResponse struct {
+	Query Query `json:"query"`
+	Usage Usage `json:"usage"`
+}
+
+// Point is one bucket of a time series, keyed by the bucket start.
+type Point struct {
+	Epoch int64 `json:"epoch"`
+	Usage
+}
+
+// SeriesResponse is the /api/v1/series document. Buckets without events
+// are left out.
+type SeriesResponse struct {
+	Query     Query   `json:"query"`
+	BucketSec int64   `json:"bucket_sec"`
+	Points    []Point `json:"points"`
+}
+
+// ProjectUsage is a projects.json entry with its usage.
+type ProjectUsage struct {
+	model.Project
+	Usage Usage `json:"usage"`
+}
+
+// TreeResponse is the /api/v1/tree document: every project in scope, with
+// or without usage in the range, for the dashboard to build its tree from.
+type TreeResponse struct {
+	Query    Query          `json:"query"`
+	Projects []ProjectUsage `json:"projects"`
+}
+
+// SessionUsage is the usage of one session.
+type SessionUsage struct {
+	SessionID   string `json:"session_id"`
+	ProjectSlug string `json:"project_slug"`
+	Usage
+}
+
+// SessionsResponse is the /api/v1/sessions document, sessions ordered by
+// value, highest first. Total counts the sessions before the limit.
+type SessionsResponse struct {
+	Query    Query          `json:"query"`
+	Total    int            `json:"total"`
+	Sessions []SessionUsage `json:"sessions"`
+}
+
+// LiveRow is a live-events.tsv row with the value of the queried metric.
+type LiveRow struct {
+	model.LiveEvent
+	Value float64 `json:"value"`
+}
+
+// LiveResponse is the /api/v1/live document, newest rows first. Total
+// counts the rows before the limit.
+type LiveResponse struct {
+	Query Query     `json:"query"`
+	Total int       `json:"total"`
+	Rows  []LiveRow `json:"rows"`
+}
+
+// ViewResponse is the /api/v1/view document: everything a dashboard refresh
+// shows, so it costs one scan of events.tsv instead of one per part.
+type ViewResponse struct {
+	Query     Query          `json:"query"`
+	Tree      TreeResponse   `json:"tree"` // Every project, not only those in scope
+	Totals    TotalsResponse `json:"totals"`
+	Last1h    TotalsResponse `json:"last_1h"`
+	Last30m   TotalsResponse `json:"last_30m"`
+	Series    SeriesResponse `json:"series"` // Of series_metric, in "bucket" buckets
+	Daily     SeriesResponse `json:"daily"`  // The last 30 days, one bucket per day
+	Live      LiveResponse   `json:"live"`   // Over live_range
+	Prompts1h int            `json:"prompts_1h"`
+}
+
+// handleTotals serves the usage of the range and scope.
+func (s *Server) handleTotals(w http.ResponseWriter, r *http.Request) {
+	q, ok := s.usageQuery(w, r)
+	if !ok {
+		return
+	}
+	totals := &totalsAgg{q: q}
+	if err := s.scanUsage(totals); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, totals.response())
+}
+
+// handleSeries serves the usage of the range and scope in time buckets of
+// "bucket" seconds, or of a width picked for the range with "auto".
+func (s *Server) handleSeries(w http.ResponseWriter, r *http.Request) {
+	q, ok := s.usageQuery(w, r)
+	if !ok {
+		return
+	}
+	bucket, err := bucketParam(r.URL.Query().Get("bucket"), q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	series := newSeriesAgg(q, bucket)
+	if err := s.scanUsage(series); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, series.response())
+}
+
+// handleTree serves the usage of each project in scope over the range.
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	q, ok := s.usageQuery(w, r)
+	if !ok {
+		return
+	}
+	projects, err := s.readProjects()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	tree := newTreeAgg(q, projects)
+	if err := s.scanUsage(tree); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tree.response())
+}
+
+// handleSessions serves the usage of each session in the range and scope.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	q, ok := s.usageQuery(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := limitParam(w, r, defaultSessionsLimit, maxSessionsLimit)
+	if !ok {
+		return
+	}
+	bySession := make(map[string]*SessionUsage)
+	err := s.scanUsage(aggregatorFunc{q, func(e model.TokenEvent) {
+		su, ok := bySession[e.SessionID]
+		if !ok {
+			su = &SessionUsage{SessionID: e.SessionID, ProjectSlug: e.ProjectSlug}
+			bySession[e.SessionID] = su
+		}
+		su.add(e)
+	}})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sessions := make([]SessionUsage, 0, len(bySession))
+	for _, su := range bySession {
+		su.finish(q.Metric)
+		sessions = append(sessions, *su)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Value != sessions[j].Value {
+			return sessions[i].Value > sessions[j].Value
+		}
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+	resp := SessionsResponse{Query: q, Total: len(sessions), Sessions: sessions}
+	if len(resp.Sessions) > limit {
+		resp.Sessions = resp.Sessions[:limit]
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleLive serves the rows of live-events.tsv in the range and scope.
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	q, ok := s.usageQuery(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := limitParam(w, r, defaultLiveLimit, maxLiveLimit)
+	if !ok {
+		return
+	}
+	events, err := s.readLive()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, liveResponse(q, events, limit))
+}
+
+// handleView serves the parts of /api/v1/tree, totals, series and live the
+// dashboard shows on each refresh, from one scan of events.tsv. Besides
+// range, scope and metric it takes series_metric (default metric) and
+// bucket for the main series, and live_range (default 30m) and limit for
+// the live rows.
+func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
+	q, ok := s.usageQuery(w, r)
+	if !ok {
+		return
+	}
+	params := r.URL.Query()
+	seriesQ := q
+	seriesQ.Metric = paramOr(params.Get("series_metric"), q.Metric)
+	if !validMetric(seriesQ.Metric) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown series_metric: %s (want one of %s)", seriesQ.Metric, strings.Join(usageMetrics, ", ")))
+		return
+	}
+	bucket, err := bucketParam(params.Get("bucket"), seriesQ)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	liveQ, err := q.withRange(paramOr(params.Get("live_range"), "30m"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, ok := limitParam(w, r, defaultLiveLimit, maxLiveLimit)
+	if !ok {
+		return
+	}
+	projects, err := s.readProjects()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	treeQ := q
+	treeQ.Scope, treeQ.inScope = "all", allScopes
+	hourQ, _ := q.withRange("1h")
+	halfHourQ, _ := q.withRange("30m")
+	dailyQ, _ := q.withRange("30d")
+	tree := newTreeAgg(treeQ, projects)
+	totals, hour, halfHour := &totalsAgg{q: q}, &totalsAgg{q: hourQ}, &totalsAgg{q: halfHourQ}
+	series, daily := newSeriesAgg(seriesQ, bucket), newSeriesAgg(dailyQ, 86400)
+	if err := s.scanUsage(tree, totals, hour, halfHour, series, daily); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	live, err := s.readLive()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ViewResponse{
+		Query:     q,
+		Tree:      tree.response(),
+		Totals:    totals.response(),
+		Last1h:    hour.response(),
+		Last30m:   halfHour.response(),
+		Series:    series.response(),
+		Daily:     daily.response(),
+		Live:      liveResponse(liveQ, live, limit),
+		Prompts1h: liveResponse(hourQ, live, 0).Total,
+	})
+}
+
+// aggregator collects one usage document from the events of its query's
+// range and scope; scanUsage feeds several from a single scan.
+type aggregator interface {
+	query() Query
+	add(e model.TokenEvent)
+}
+
+// aggregatorFunc is an aggregator for a one-off collection.
+type aggregatorFunc struct {
+	q Query
+	f func(e model.TokenEvent)
+}
+
+func (a aggregatorFunc) query() Query           { return a.q }
+func (a aggregatorFunc) add(e model.TokenEvent) { a.f(e) }
+
+// totalsAgg sums the events.
+type totalsAgg struct {
+	q     Query
+	usage Usage
+}
+
+func (a *totalsAgg) query() Query           { return a.q }
+func (a *totalsAgg) add(e model.TokenEvent) { a.usage.add(e) }
+
+func (a *totalsAgg) response() TotalsResponse {
+	a.usage.finish(a.q.Metric)
+	return TotalsResponse{Query: a.q, Usage: a.usage}
+}
+
+// seriesAgg sums the events by time bucket.
+type seriesAgg struct {
+	q       Query
+	bucket  int64
+	buckets map[int64]*Usage
+}
+
+func newSeriesAgg(q Query, bucket int64) *seriesAgg {
+	return &seriesAgg{q: q, bucket: bucket, buckets: make(map[int64]*Usage)}
+}
+
+func (a *seriesAgg) query() Query { return a.q }
+
+func (a *seriesAgg) add(e model.TokenEvent) {
+	b := (e.TSEpoch / a.bucket) * a.bucket
+	u, ok := a.buckets[b]
+	if !ok {
+		u = &Usage{}
+		a.buckets[b] = u
+	}
+	u.add(e)
+}
+
+func (a *seriesAgg) response() SeriesResponse {
+	resp := SeriesResponse{Query: a.q, BucketSec: a.bucket, Points: make([]Point, 0, len(a.buckets))}
+	for epoch, u := range a.buckets {
+		u.finish(a.q.Metric)
+		resp.Points = append(resp.Points, Point{Epoch: epoch, Usage: *u})
+	}
+	sort.Slice(resp.Points, func(i, j int) bool { return resp.Points[i].Epoch < resp.Points[j].Epoch })
+	return resp
+}
+
+// treeAgg sums the events by project.
+type treeAgg struct {
+	q        Query
+	projects []model.Project
+	bySlug   map[string]*Usage
+}
+
+func newTreeAgg(q Query, projects []model.Project) *treeAgg {
+	return &treeAgg{q: q, projects: projects, bySlug: make(map[string]*Usage)}
+}
+
+func (a *treeAgg) query() Query { return a.q }
+
+func (a *treeAgg) add(e model.TokenEvent) {
+	u, ok := a.bySlug[e.ProjectSlug]
+	if !ok {
+		u = &Usage{}
+		a.bySlug[e.ProjectSlug] = u
+	}
+	u.add(e)
+}
+
+func (a *treeAgg) response() TreeResponse {
+	resp := TreeResponse{Query: a.q, Projects: []ProjectUsage{}}
+	for _, p := range a.projects {
+		if p.Slug == "" || !a.q.inScope(p.Slug) {
+			continue
+		}
+		pu := ProjectUsage{Project: p}
+		if u := a.bySlug[p.Slug]; u != nil {
+			pu.Usage = *u
+		}
+		pu.Usage.finish(a.q.Metric)
+		resp.Projects = append(resp.Projects, pu)
+	}
+	return resp
+}
+
+// readLive reads the rows of live-events.tsv; a missing file has none.
+func (s *Server) readLive() ([]model.LiveEvent, error) {
+	data, err := store.ReadFile(filepath.Join(s.DataRoot, "live-events.tsv"), s.Vault)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var events []model.LiveEvent
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for first := true; sc.Scan(); first = false {
+		line := strings.TrimSpace(sc.Text())
+		if first || line == "" {
+			continue // header
+		}
+		if e, err := store.UnmarshalLiveEvent(line); err == nil {
+			events = append(events, e)
+		}
+	}
+	return events, sc.Err()
+}
+
+// liveResponse picks the live rows in q's range and scope, newest first.
+func liveResponse(q Query, events []model.LiveEvent, limit int) LiveResponse {
+	rows := []LiveRow{}
+	for _, e := range events {
+		if e.TSEpoch >= q.Since && q.inScope(e.ProjectSlug) {
+			rows = append(rows, LiveRow{LiveEvent: e, Value: liveValue(e, q.Metric)})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].TSEpoch > rows[j].TSEpoch })
+	resp := LiveResponse{Query: q, Total: len(rows), Rows: rows}
+	if len(resp.Rows) > limit {
+		resp.Rows = resp.Rows[:limit]
+	}
+	return resp
+}
+
+// liveValue is the metric of a live row. Live rows carry no model, so their
+// cost is at the fallback price.
+func liveValue(e model.LiveEvent, metric string) float64 {
+	var u Usage
+	u.add(e.TokenEvent)
+	u.finish(metric)
+	return u.Value
+}
+
+// usageQuery parses the range, scope and metric parameters of r, writing
+// the error response and returning false when they are invalid.
+func (s *Server) usageQuery(w http.ResponseWriter, r *http.Request) (Query, bool) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return Query{}, false
+	}
+	params := r.URL.Query()
+	q := Query{
+		Range:  paramOr(params.Get("range"), "24h"),
+		Scope:  paramOr(params.Get("scope"), "all"),
+		Metric: paramOr(params.Get("metric"), "billable"),
+		Until:  time.Now().Unix(),
+	}
+
+	q, err := q.withRange(q.Range)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return Query{}, false
+	}
+
+	if !validMetric(q.Metric) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown metric: %s (want one of %s)", q.Metric, strings.Join(usageMetrics, ", ")))
+		return Query{}, false
+	}
+
+	q.inScope, err = s.scopeMatcher(q.Scope)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return Query{}, false
+	}
+	return q, true
+}
+
+// withRange returns q over the named range instead, ending at q.Until.
+func (q Query) withRange(name string) (Query, error) {
+	sec, err := rangeSeconds(name)
+	if err != nil {
+		return Query{}, err
+	}
+	q.Range, q.Since = name, 0
+	if sec > 0 {
+		q.Since = q.Until - sec
+	}
+	return q, nil
+}
+
+// bucketParam parses the bucket width of a series over q: seconds, or
+// "auto" (the default) for a width picked for the range.
+func bucketParam(raw string, q Query) (int64, error) {
+	if raw != "" && raw != "auto" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid bucket: %q", raw)
+		}
+		return n, nil
+	}
+	if q.Since > 0 {
+		return autoBucketSec(q.Until - q.Since), nil
+	}
+	return autoBucketSec(0), nil
+}
+
+// rangeSeconds accepts the ranges of jevons total and graph, plus "30m",
+// the dashboard's shortest live window.
+func rangeSeconds(name string) (int64, error) {
+	if name == "30m" {
+		return 1800, nil
+	}
+	return query.RangeSeconds(name)
+}
+
+// scopeMatcher returns which project slugs scope selects: "all", a single
+// "slug:<slug>", every directory of "repo:<repo>" (see model.Project.RepoKey),
+// or every project at or below "path:<path>".
+func (s *Server) scopeMatcher(scope string) (func(slug string) bool, error) {
+	if scope == "all" {
+		return allScopes, nil
+	}
+	kind, value, ok := strings.Cut(scope, ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid scope: %q (want all, slug:, repo: or path:)", scope)
+	}
+	if kind == "slug" {
+		return func(slug string) bool { return slug == value }, nil
+	}
+	if kind != "repo" && kind != "path" {
+		return nil, fmt.Errorf("unknown scope kind: %s", kind)
+	}
+
+	projects, err := s.readProjects()
+	if err != nil {
+		return nil, err
+	}
+	base := normalizePath(value)
+	slugs := make(map[string]bool)
+	for _, p := range projects {
+		switch kind {
+		case "repo":
+			key := p.RepoKey()
+			if key == "" {
+				key = p.Slug
+			}
+			slugs[p.Slug] = key == value
+		case "path":
+			path := normalizePath(p.Path)
+			slugs[p.Slug] = p.Path != "" && (path == base || strings.HasPrefix(path, strings.TrimSuffix(base, "/")+"/"))
+		}
+	}
+	return func(slug string) bool { return slugs[slug] }, nil
+}
+
+// allScopes is the matcher of scope "all".
+func allScopes(string) bool { return true }
+
+func (s *Server) readProjects() ([]model.Project, error) {
+	projects, err := store.ReadProjects(filepath.Join(s.DataRoot, "projects.json"), s.Vault)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read projects.json: %w", err)
+	}
+	return projects, nil
+}
+
+// scanUsage streams events.tsv once into every aggregator, each getting the
+// events of its own range and scope.
+func (s *Server) scanUsage(aggs ...aggregator) error {
+	since := aggs[0].query().Since
+	for _, a := range aggs[1:] {
+		since = min(since, a.query().Since)
+	}
+	err := query.Scan(filepath.Join(s.DataRoot, "events.tsv"), s.Vault, query.Filter{Since: since},
+		query.AggregatorFunc(func(e model.TokenEvent) {
+			for _, a := range aggs {
+				if q := a.query(); e.TSEpoch >= q.Since && q.inScope(e.ProjectSlug) {
+					a.add(e)
+				}
+			}
+		}))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("scan events: %w", err)
+	}
+	return nil
+}
+
+// autoBucketSec picks a bucket width that keeps a range's series readable;
+// 0 (all time) gets hourly buckets.
+func autoBucketSec(rangeSec int64) int64 {
+	switch {
+	case rangeSec <= 0:
+		return 3600
+	case rangeSec <= 3*3600:
+		return 60
+	case rangeSec <= 12*3600:
+		return 300
+	case rangeSec <= 48*3600:
+		return 900
+	case rangeSec <= 7*86400:
+		return 3600
+	case rangeSec <= 30*86400:
+		return 21600
+	}
+	return 86400
+}
+
+// normalizePath drops trailing slashes, keeping "/" for the root.
+func normalizePath(path string) string {
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func validMetric(metric string) bool {
+	for _, m := range usageMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+func paramOr(raw, def string) string {
+	if raw == "" {
+		return def
+	}
+	return raw
+}
+
+// limitParam parses the "limit" parameter of r, between 0 and max.
+func limitParam(w http.ResponseWriter, r *http.Request, def, max int64) (int, bool) {
+	limit, err := int64Param(r.URL.Query().Get("limit"), def)
+	if err != nil || limit < 0 || limit > max {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 0 and %d", max))
+		return 0, false
+	}
+	return int(limit), true
+}
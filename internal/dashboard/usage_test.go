@@ -0,0 +1,388 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usageFixture writes three projects: alpha and its worktree beta share the
+// /work/app repository, gamma is outside git. It returns the server and the
+// time the events are relative to.
+func usageFixture(t *testing.T) (*Server, int64) {
+	t.Helper()
+	dataRoot := t.TempDir()
+	now := time.Now().Unix()
+
+	projects := []model.Project{
+		{Slug: "alpha", Path: "/work/app", Git: &model.GitInfo{Repo: "/work/app", Root: "/work/app"}},
+		{Slug: "beta", Path: "/work/app-wt", Git: &model.GitInfo{Repo: "/work/app", Root: "/work/app-wt", Worktree: true}},
+		{Slug: "gamma", Path: "/other/tool"},
+	}
+	require.NoError(t, store.WriteProjects(filepath.Join(dataRoot, "projects.json"), projects, nil))
+
+	events := []model.TokenEvent{
+		{TSEpoch: now - 3*86400, ProjectSlug: "gamma", SessionID: "s3", Input: 500, Billable: 500, TotalWithCache: 500, Signature: "d"},
+		{TSEpoch: now - 7200, ProjectSlug: "alpha", SessionID: "s1", Input: 2000, Output: 200, Billable: 2200, TotalWithCache: 2200, Model: "claude-sonnet-4-5", Signature: "c"},
+		// Unknown model: priced at the fallback (Sonnet) rates.
+		{TSEpoch: now - 1200, ProjectSlug: "beta", SessionID: "s2", Input: 1_000_000, Billable: 1_000_000, TotalWithCache: 1_000_000, Signature: "b"},
+		{TSEpoch: now - 600, ProjectSlug: "alpha", SessionID: "s1", Input: 1_000_000, Output: 100_000, CacheRead: 1_000_000, Billable: 1_100_000, TotalWithCache: 2_100_000, Model: "claude-sonnet-4-5", Signature: "a"},
+	}
+	for i := range events {
+		events[i].TSISO = time.Unix(events[i].TSEpoch, 0).UTC().Format(time.RFC3339)
+		events[i].ContentType = "text"
+	}
+	require.NoError(t, store.WriteEventsTSV(filepath.Join(dataRoot, "events.tsv"), events, nil))
+
+	live := []model.LiveEvent{
+		{TokenEvent: events[1], PromptPreview: "refactor"},
+		{TokenEvent: events[2], PromptPreview: "add tests"},
+		{TokenEvent: events[3], PromptPreview: "fix bug"},
+	}
+	require.NoError(t, store.WriteLiveEventsTSV(filepath.Join(dataRoot, "live-events.tsv"), live, nil))
+
+	return &Server{DataRoot: dataRoot}, now
+}
+
+func getJSON(t *testing.T, h http.HandlerFunc, target string, wantStatus int, v any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, wantStatus, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	if v != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+}
+
+func TestHandleTotals(t *testing.T) {
+	srv, _ := usageFixture(t)
+
+	tests := []struct {
+		name         string
+		target       string
+		wantStatus   int
+		wantEvents   int64
+		wantBillable int64
+		wantValue    float64
+	}{
+		{name: "defaults to 24h of all projects", target: "/api/v1/totals", wantStatus: http.StatusOK, wantEvents: 3, wantBillable: 2_102_200, wantValue: 2_102_200},
+		{name: "last hour", target: "/api/v1/totals?range=1h", wantStatus: http.StatusOK, wantEvents: 2, wantBillable: 2_100_000, wantValue: 2_100_000},
+		{name: "thirty minutes", target: "/api/v1/totals?range=30m", wantStatus: http.StatusOK, wantEvents: 2, wantBillable: 2_100_000, wantValue: 2_100_000},
+		{name: "all time", target: "/api/v1/totals?range=all", wantStatus: http.StatusOK, wantEvents: 4, wantBillable: 2_102_700, wantValue: 2_102_700},
+		{name: "slug scope", target: "/api/v1/totals?range=all&scope=slug:gamma", wantStatus: http.StatusOK, wantEvents: 1, wantBillable: 500, wantValue: 500},
+		{name: "repo scope includes worktree", target: "/api/v1/totals?range=all&scope=repo:/work/app", wantStatus: http.StatusOK, wantEvents: 3, wantBillable: 2_102_200, wantValue: 2_102_200},
+		{name: "path scope is a directory prefix", target: "/api/v1/totals?range=all&scope=path:/work/app/", wantStatus: http.StatusOK, wantEvents: 2, wantBillable: 1_102_200, wantValue: 1_102_200},
+		{name: "path scope of a parent", target: "/api/v1/totals?range=all&scope=path:/work", wantStatus: http.StatusOK, wantEvents: 3, wantBillable: 2_102_200, wantValue: 2_102_200},
+		{name: "metric", target: "/api/v1/totals?range=1h&metric=cache_read", wantStatus: http.StatusOK, wantEvents: 2, wantBillable: 2_100_000, wantValue: 1_000_000},
+		// alpha at Sonnet list price: 3 + 1.5 + 0.3; beta at the fallback: 3.
+		{name: "cost", target: "/api/v1/totals?range=1h&metric=cost", wantStatus: http.StatusOK, wantEvents: 2, wantBillable: 2_100_000, wantValue: 7.8},
+		{name: "unknown range", target: "/api/v1/totals?range=2d", wantStatus: http.StatusBadRequest},
+		{name: "unknown metric", target: "/api/v1/totals?metric=prompts", wantStatus: http.StatusBadRequest},
+		{name: "invalid scope", target: "/api/v1/totals?scope=gamma", wantStatus: http.StatusBadRequest},
+		{name: "unknown scope kind", target: "/api/v1/totals?scope=host:gamma", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TotalsResponse
+			getJSON(t, srv.handleTotals, tt.target, tt.wantStatus, &got)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantEvents, got.Usage.Events)
+			assert.Equal(t, tt.wantBillable, got.Usage.Billable)
+			assert.InDelta(t, tt.wantValue, got.Usage.Value, 1e-9)
+		})
+	}
+}
+
+func TestHandleTotalsCost(t *testing.T) {
+	srv, now := usageFixture(t)
+
+	var got TotalsResponse
+	getJSON(t, srv.handleTotals, "/api/v1/totals?range=1h&scope=slug:alpha", http.StatusOK, &got)
+	assert.InDelta(t, 3.0, got.Usage.Cost.Input, 1e-9)
+	assert.InDelta(t, 1.5, got.Usage.Cost.Output, 1e-9)
+	assert.InDelta(t, 0.3, got.Usage.Cost.Cache, 1e-9)
+	assert.InDelta(t, 4.8, got.Usage.Cost.Total, 1e-9)
+	assert.Equal(t, now-600, got.Usage.FirstEpoch)
+	assert.Equal(t, now-600, got.Usage.LastEpoch)
+	assert.Equal(t, "1h", got.Query.Range)
+	assert.Equal(t, "slug:alpha", got.Query.Scope)
+	assert.Equal(t, "billable", got.Query.Metric)
+	assert.Equal(t, got.Query.Until-3600, got.Query.Since)
+}
+
+func TestHandleSeries(t *testing.T) {
+	srv, now := usageFixture(t)
+	hour := func(ts int64) int64 { return ts / 3600 * 3600 }
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBucket int64
+		wantPoints map[int64]float64 // bucket start → value
+	}{
+		{
+			name:       "auto bucket for 24h",
+			target:     "/api/v1/series?range=24h&scope=slug:beta",
+			wantStatus: http.StatusOK,
+			wantBucket: 900,
+			wantPoints: map[int64]float64{(now - 1200) / 900 * 900: 1_000_000},
+		},
+		{
+			name:       "auto bucket for all time",
+			target:     "/api/v1/series?range=all&scope=slug:gamma&metric=input",
+			wantStatus: http.StatusOK,
+			wantBucket: 3600,
+			wantPoints: map[int64]float64{hour(now - 3*86400): 500},
+		},
+		{
+			name:       "explicit bucket",
+			target:     "/api/v1/series?range=24h&bucket=3600&scope=slug:alpha&metric=output",
+			wantStatus: http.StatusOK,
+			wantBucket: 3600,
+			wantPoints: func() map[int64]float64 {
+				m := map[int64]float64{hour(now - 7200): 200}
+				m[hour(now-600)] += 100_000
+				return m
+			}(),
+		},
+		{name: "empty scope", target: "/api/v1/series?range=1h&scope=slug:gamma", wantStatus: http.StatusOK, wantBucket: 60, wantPoints: map[int64]float64{}},
+		{name: "bad bucket", target: "/api/v1/series?bucket=0", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got SeriesResponse
+			getJSON(t, srv.handleSeries, tt.target, tt.wantStatus, &got)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantBucket, got.BucketSec)
+			points := map[int64]float64{}
+			for i, p := range got.Points {
+				if i > 0 {
+					assert.Less(t, got.Points[i-1].Epoch, p.Epoch, "points are in time order")
+				}
+				points[p.Epoch] = p.Value
+			}
+			assert.Equal(t, tt.wantPoints, points)
+		})
+	}
+}
+
+func TestHandleTree(t *testing.T) {
+	srv, _ := usageFixture(t)
+
+	tests := []struct {
+		name      string
+		target    string
+		wantValue map[string]float64
+	}{
+		{name: "every project, with or without usage", target: "/api/v1/tree?range=1h", wantValue: map[string]float64{"alpha": 1_100_000, "beta": 1_000_000, "gamma": 0}},
+		{name: "all time", target: "/api/v1/tree?range=all&metric=input", wantValue: map[string]float64{"alpha": 1_002_000, "beta": 1_000_000, "gamma": 500}},
+		{name: "scoped to a repository", target: "/api/v1/tree?range=all&scope=repo:/work/app", wantValue: map[string]float64{"alpha": 1_102_200, "beta": 1_000_000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TreeResponse
+			getJSON(t, srv.handleTree, tt.target, http.StatusOK, &got)
+			values := map[string]float64{}
+			for _, p := range got.Projects {
+				values[p.Slug] = p.Usage.Value
+			}
+			assert.Equal(t, tt.wantValue, values)
+		})
+	}
+
+	var got TreeResponse
+	getJSON(t, srv.handleTree, "/api/v1/tree?scope=slug:beta", http.StatusOK, &got)
+	require.Len(t, got.Projects, 1)
+	require.NotNil(t, got.Projects[0].Git)
+	assert.True(t, got.Projects[0].Git.Worktree, "tree entries carry the projects.json metadata")
+}
+
+func TestHandleSessions(t *testing.T) {
+	srv, _ := usageFixture(t)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantIDs    []string
+		wantTotal  int
+	}{
+		{name: "highest value first", target: "/api/v1/sessions?range=all", wantStatus: http.StatusOK, wantIDs: []string{"s1", "s2", "s3"}, wantTotal: 3},
+		{name: "ties by session id", target: "/api/v1/sessions?range=all&metric=output", wantStatus: http.StatusOK, wantIDs: []string{"s1", "s2", "s3"}, wantTotal: 3},
+		{name: "range", target: "/api/v1/sessions?range=1h&metric=cost", wantStatus: http.StatusOK, wantIDs: []string{"s1", "s2"}, wantTotal: 2},
+		{name: "limit", target: "/api/v1/sessions?range=all&limit=1", wantStatus: http.StatusOK, wantIDs: []string{"s1"}, wantTotal: 3},
+		{name: "scope", target: "/api/v1/sessions?range=all&scope=path:/other", wantStatus: http.StatusOK, wantIDs: []string{"s3"}, wantTotal: 1},
+		{name: "limit too large", target: "/api/v1/sessions?limit=100000", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got SessionsResponse
+			getJSON(t, srv.handleSessions, tt.target, tt.wantStatus, &got)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			ids := []string{}
+			for _, s := range got.Sessions {
+				ids = append(ids, s.SessionID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantTotal, got.Total)
+		})
+	}
+}
+
+func TestHandleLive(t *testing.T) {
+	srv, _ := usageFixture(t)
+
+	tests := []struct {
+		name        string
+		target      string
+		wantStatus  int
+		wantPrompts []string
+		wantTotal   int
+	}{
+		{name: "newest first", target: "/api/v1/live?range=3h", wantStatus: http.StatusOK, wantPrompts: []string{"fix bug", "add tests", "refactor"}, wantTotal: 3},
+		{name: "live window", target: "/api/v1/live?range=30m", wantStatus: http.StatusOK, wantPrompts: []string{"fix bug", "add tests"}, wantTotal: 2},
+		{name: "scope", target: "/api/v1/live?range=3h&scope=slug:alpha", wantStatus: http.StatusOK, wantPrompts: []string{"fix bug", "refactor"}, wantTotal: 2},
+		{name: "count only", target: "/api/v1/live?range=1h&limit=0", wantStatus: http.StatusOK, wantPrompts: []string{}, wantTotal: 2},
+		{name: "bad limit", target: "/api/v1/live?limit=-1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got LiveResponse
+			getJSON(t, srv.handleLive, tt.target, tt.wantStatus, &got)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			prompts := []string{}
+			for _, row := range got.Rows {
+				prompts = append(prompts, row.PromptPreview)
+			}
+			assert.Equal(t, tt.wantPrompts, prompts)
+			assert.Equal(t, tt.wantTotal, got.Total)
+		})
+	}
+
+	var got LiveResponse
+	getJSON(t, srv.handleLive, "/api/v1/live?range=30m&metric=cost&limit=1", http.StatusOK, &got)
+	require.Len(t, got.Rows, 1)
+	assert.InDelta(t, 4.8, got.Rows[0].Value, 1e-9, "live rows are priced at the fallback rates")
+}
+
+func TestHandleView(t *testing.T) {
+	srv, _ := usageFixture(t)
+
+	tests := []struct {
+		name       string
+		params     string
+		wantStatus int
+	}{
+		{name: "defaults", params: "", wantStatus: http.StatusOK},
+		{name: "scoped with series metric", params: "range=7d&scope=repo:/work/app&series_metric=cost&bucket=3600&live_range=1h&limit=1", wantStatus: http.StatusOK},
+		{name: "all time", params: "range=all&metric=output", wantStatus: http.StatusOK},
+		{name: "bad series metric", params: "series_metric=bogus", wantStatus: http.StatusBadRequest},
+		{name: "bad bucket", params: "bucket=0", wantStatus: http.StatusBadRequest},
+		{name: "bad live range", params: "live_range=1y", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var view ViewResponse
+			getJSON(t, srv.handleView, "/api/v1/view?"+tt.params, tt.wantStatus, &view)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			// Each part matches what its own endpoint returns.
+			params, err := url.ParseQuery(tt.params)
+			require.NoError(t, err)
+			get := func(h http.HandlerFunc, endpoint string, set map[string]string, v any) {
+				p := url.Values{}
+				for _, k := range []string{"range", "scope", "metric"} {
+					if params.Has(k) {
+						p.Set(k, params.Get(k))
+					}
+				}
+				for k, val := range set {
+					p.Set(k, val)
+				}
+				getJSON(t, h, endpoint+"?"+p.Encode(), http.StatusOK, v)
+			}
+			seriesMetric := params.Get("series_metric")
+			if seriesMetric == "" {
+				seriesMetric = params.Get("metric")
+			}
+			liveRange := params.Get("live_range")
+			if liveRange == "" {
+				liveRange = "30m"
+			}
+
+			var tree TreeResponse
+			get(srv.handleTree, "/api/v1/tree", map[string]string{"scope": "all"}, &tree)
+			assert.Equal(t, tree.Projects, view.Tree.Projects)
+			for part, want := range map[string]Usage{"": view.Totals.Usage, "1h": view.Last1h.Usage, "30m": view.Last30m.Usage} {
+				var totals TotalsResponse
+				set := map[string]string{}
+				if part != "" {
+					set["range"] = part
+				}
+				get(srv.handleTotals, "/api/v1/totals", set, &totals)
+				assert.Equal(t, totals.Usage, want, part)
+			}
+			var series, daily SeriesResponse
+			set := map[string]string{"bucket": params.Get("bucket")}
+			if seriesMetric != "" {
+				set["metric"] = seriesMetric
+			}
+			get(srv.handleSeries, "/api/v1/series", set, &series)
+			assert.Equal(t, series.BucketSec, view.Series.BucketSec)
+			assert.Equal(t, series.Points, view.Series.Points)
+			get(srv.handleSeries, "/api/v1/series", map[string]string{"range": "30d", "bucket": "86400"}, &daily)
+			assert.Equal(t, daily.Points, view.Daily.Points)
+			var live, prompts LiveResponse
+			get(srv.handleLive, "/api/v1/live", map[string]string{"range": liveRange, "limit": paramOr(params.Get("limit"), "80")}, &live)
+			assert.Equal(t, live.Rows, view.Live.Rows)
+			get(srv.handleLive, "/api/v1/live", map[string]string{"range": "1h", "limit": "0"}, &prompts)
+			assert.Equal(t, prompts.Total, view.Prompts1h)
+		})
+	}
+}
+
+func TestUsageEndpointsEmptyDataRoot(t *testing.T) {
+	srv := &Server{DataRoot: t.TempDir()}
+	for target, h := range map[string]http.HandlerFunc{
+		"/api/v1/totals":   srv.handleTotals,
+		"/api/v1/series":   srv.handleSeries,
+		"/api/v1/tree":     srv.handleTree,
+		"/api/v1/sessions": srv.handleSessions,
+		"/api/v1/live":     srv.handleLive,
+		"/api/v1/view":     srv.handleView,
+	} {
+		t.Run(target, func(t *testing.T) {
+			getJSON(t, h, target, http.StatusOK, nil)
+
+			rec := httptest.NewRecorder()
+			h(rec, httptest.NewRequest(http.MethodPost, target, nil))
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		})
+	}
+}
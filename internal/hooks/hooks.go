@@ -0,0 +1,231 @@
+// Package hooks notifies user automation about newly ingested events: shell
+// commands get the events as JSON on stdin, webhooks get them POSTed.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Hook kinds reported in Result.Kind.
+const (
+	KindCommand = "command"
+	KindWebhook = "webhook"
+)
+
+// Webhook request headers. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the hook secret, prefixed with "sha256=".
+const (
+	SignatureHeader = "X-Jevons-Signature"
+	TimestampHeader = "X-Jevons-Timestamp"
+)
+
+// DefaultTimeout bounds a single attempt when the hook sets no timeout.
+const DefaultTimeout = 10 * time.Second
+
+// maxOutput caps how much command output or response body ends up in errors.
+const maxOutput = 512
+
+// retryDelay is the wait before the first webhook retry; it doubles after
+// each attempt. A variable so tests don't sleep.
+var retryDelay = time.Second
+
+// Payload is the JSON document every hook receives. An alert from the sync
+// daemon carries Alert and no events.
+type Payload struct {
+	SyncedAt string             `json:"synced_at"`
+	Count    int                `json:"count"`
+	LastSeq  int64              `json:"last_seq"`
+	Events   []model.TokenEvent `json:"events"`
+	Alert    string             `json:"alert,omitempty"`
+}
+
+// Result records the outcome of one hook.
+type Result struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	OK         bool   `json:"ok"`
+	Attempts   int    `json:"attempts"`
+	Status     int    `json:"status,omitempty"` // Last HTTP status for webhooks
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Run calls every hook in order with p and returns one Result per hook. A
+// failing hook never stops the others.
+func Run(hooks []model.HookConfig, p Payload) []Result {
+	if len(hooks) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		// TokenEvent always marshals; keep going with an empty body anyway.
+		body = []byte("{}")
+	}
+
+	results := make([]Result, 0, len(hooks))
+	for _, h := range hooks {
+		start := time.Now()
+		var r Result
+		if h.Command != "" {
+			r = runCommand(h, body, p)
+		} else {
+			r = runWebhook(h, body)
+		}
+		r.Name = hookName(h)
+		r.DurationMS = time.Since(start).Milliseconds()
+		results = append(results, r)
+	}
+	return results
+}
+
+// Alert sends msg to every hook as a payload without events, for problems
+// the user should see, such as the daemon pausing after repeated failures.
+func Alert(hooks []model.HookConfig, msg string) []Result {
+	return Run(hooks, Payload{
+		SyncedAt: time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Events:   []model.TokenEvent{},
+		Alert:    msg,
+	})
+}
+
+func hookName(h model.HookConfig) string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.Command != "":
+		return h.Command
+	default:
+		return h.URL
+	}
+}
+
+func timeout(h model.HookConfig) time.Duration {
+	if h.Timeout > 0 {
+		return time.Duration(h.Timeout) * time.Second
+	}
+	return DefaultTimeout
+}
+
+// runCommand runs h.Command with sh -c, the payload on stdin and the event
+// count, last seq and any alert in JEVONS_EVENT_COUNT, JEVONS_LAST_SEQ and
+// JEVONS_ALERT.
+func runCommand(h model.HookConfig, body []byte, p Payload) Result {
+	r := Result{Kind: KindCommand, Attempts: 1}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout(h))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Stdin = bytes.NewReader(body)
+	// Don't wait on output pipes held open by the command's children.
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(cmd.Environ(),
+		"JEVONS_EVENT_COUNT="+strconv.Itoa(p.Count),
+		"JEVONS_LAST_SEQ="+strconv.FormatInt(p.LastSeq, 10),
+	)
+	if p.Alert != "" {
+		cmd.Env = append(cmd.Env, "JEVONS_ALERT="+p.Alert)
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		r.Error = fmt.Sprintf("timed out after %s", timeout(h))
+		return r
+	}
+	if err != nil {
+		r.Error = withOutput(err.Error(), out)
+		return r
+	}
+	r.OK = true
+	return r
+}
+
+// runWebhook POSTs body to h.URL, retrying transport errors, 429 and 5xx
+// responses up to h.Retries times with exponential backoff.
+func runWebhook(h model.HookConfig, body []byte) Result {
+	r := Result{Kind: KindWebhook}
+	client := &http.Client{Timeout: timeout(h)}
+	delay := retryDelay
+
+	for attempt := 0; attempt <= h.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		r.Attempts++
+
+		status, err := post(client, h, body)
+		r.Status = status
+		if err == nil {
+			r.OK = true
+			r.Error = ""
+			return r
+		}
+		r.Error = err.Error()
+		if status != 0 && status != http.StatusTooManyRequests && status < 500 {
+			// The receiver rejected the request; repeating it won't help.
+			return r
+		}
+	}
+	return r
+}
+
+func post(client *http.Client, h model.HookConfig, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "jevons-hooks")
+	if h.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, Sign(h.Secret, ts, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, errors.New(withOutput(resp.Status, out))
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the SignatureHeader value for body sent at timestamp ts.
+// Receivers recompute it to check the request came from jevons.
+func Sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func withOutput(msg string, out []byte) string {
+	s := strings.TrimSpace(string(out))
+	if len(s) > maxOutput {
+		s = s[:maxOutput]
+	}
+	if s == "" {
+		return msg
+	}
+	return msg + ": " + s
+}
@@ -0,0 +1,177 @@
+package hooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPayload() Payload {
+	return Payload{
+		SyncedAt: "2025-01-15T10:00:00Z",
+		Count:    1,
+		LastSeq:  7,
+		Events:   []model.TokenEvent{{ProjectSlug: "p", SessionID: "s", Billable: 150, ID: "abc", Seq: 7}},
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.json")
+
+	tests := []struct {
+		name    string
+		hook    model.HookConfig
+		wantOK  bool
+		wantErr string
+	}{
+		{"receives payload on stdin", model.HookConfig{Command: "cat > " + out}, true, ""},
+		{"non-zero exit", model.HookConfig{Name: "fails", Command: "echo boom >&2; exit 3"}, false, "boom"},
+		{"timeout", model.HookConfig{Command: "sleep 5", Timeout: 1}, false, "timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Run([]model.HookConfig{tt.hook}, testPayload())
+			require.Len(t, results, 1)
+			r := results[0]
+			assert.Equal(t, KindCommand, r.Kind)
+			assert.Equal(t, tt.wantOK, r.OK)
+			assert.Contains(t, r.Error, tt.wantErr)
+		})
+	}
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var got Payload
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, testPayload(), got)
+}
+
+func TestRunCommandEnv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env")
+	results := Run([]model.HookConfig{{Command: `echo "$JEVONS_EVENT_COUNT $JEVONS_LAST_SEQ" > ` + out}}, testPayload())
+	require.True(t, results[0].OK, results[0].Error)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "1 7\n", string(data))
+}
+
+func TestAlert(t *testing.T) {
+	dir := t.TempDir()
+	env, stdin := filepath.Join(dir, "env"), filepath.Join(dir, "stdin")
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	results := Alert([]model.HookConfig{
+		{Command: `echo "$JEVONS_EVENT_COUNT $JEVONS_ALERT" > ` + env + `; cat > ` + stdin},
+		{URL: srv.URL},
+	}, "sync paused")
+	require.Len(t, results, 2)
+	for _, r := range results {
+		require.True(t, r.OK, r.Error)
+	}
+
+	data, err := os.ReadFile(env)
+	require.NoError(t, err)
+	assert.Equal(t, "0 sync paused\n", string(data))
+	for _, raw := range [][]byte{mustRead(t, stdin), body} {
+		var got map[string]any
+		require.NoError(t, json.Unmarshal(raw, &got))
+		assert.Equal(t, "sync paused", got["alert"])
+		assert.Equal(t, []any{}, got["events"], "events is an empty list, not null")
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
+
+func TestRunWebhook(t *testing.T) {
+	retryDelay = time.Millisecond
+
+	tests := []struct {
+		name         string
+		statuses     []int // Responses in order; the last one repeats
+		retries      int
+		wantOK       bool
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"success", []int{200}, 2, true, 1, 200},
+		{"retries server errors", []int{503, 502, 204}, 2, true, 3, 204},
+		{"gives up after retries", []int{500}, 1, false, 2, 500},
+		{"retries rate limiting", []int{429, 200}, 1, true, 2, 200},
+		{"client error is not retried", []int{400}, 3, false, 1, 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			var body []byte
+			var ts, sig string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1)) - 1
+				body, _ = io.ReadAll(r.Body)
+				ts = r.Header.Get(TimestampHeader)
+				sig = r.Header.Get(SignatureHeader)
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses)-1)])
+			}))
+			defer srv.Close()
+
+			results := Run([]model.HookConfig{{URL: srv.URL, Secret: "s3cret", Retries: tt.retries}}, testPayload())
+			require.Len(t, results, 1)
+			r := results[0]
+			assert.Equal(t, KindWebhook, r.Kind)
+			assert.Equal(t, srv.URL, r.Name)
+			assert.Equal(t, tt.wantOK, r.OK, r.Error)
+			assert.Equal(t, tt.wantAttempts, r.Attempts)
+			assert.Equal(t, tt.wantStatus, r.Status)
+
+			require.NotEmpty(t, ts)
+			assert.Equal(t, Sign("s3cret", ts, body), sig)
+			var got Payload
+			require.NoError(t, json.Unmarshal(body, &got))
+			assert.Equal(t, int64(7), got.LastSeq)
+		})
+	}
+}
+
+func TestRunWebhookUnsigned(t *testing.T) {
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(SignatureHeader)
+	}))
+	defer srv.Close()
+
+	results := Run([]model.HookConfig{{URL: srv.URL}}, testPayload())
+	require.True(t, results[0].OK)
+	assert.Empty(t, sig)
+}
+
+func TestRunContinuesAfterFailure(t *testing.T) {
+	results := Run([]model.HookConfig{
+		{Name: "bad", Command: "exit 1"},
+		{Name: "good", Command: "true"},
+	}, testPayload())
+	require.Len(t, results, 2)
+	assert.False(t, results[0].OK)
+	assert.True(t, results[1].OK)
+	assert.Equal(t, "good", results[1].Name)
+}
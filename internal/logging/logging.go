@@ -0,0 +1,290 @@
+// Package logging writes the structured (log/slog) log of jevons to
+// DataRoot/logs/jevons.log, rotating it by size, and reads it back for
+// jevons logs.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// File is the log under DataRoot/logs; rotated copies get .1, .2, ... suffixes.
+const File = "jevons.log"
+
+// Path returns the log file for dataRoot.
+func Path(dataRoot string) string {
+	return filepath.Join(dataRoot, "logs", File)
+}
+
+// ParseLevel maps a config level name to a slog level; "" is info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level: %s", name)
+}
+
+// New returns a logger writing cfg's level and format to the rotated log
+// file of dataRoot. When console is not nil, warnings and errors are also
+// written there as text. Close the returned closer to close the file.
+func New(cfg model.LogConfig, dataRoot string, console io.Writer) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := OpenRotating(Path(dataRoot), int64(cfg.MaxSizeMB)<<20, cfg.Keep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler = slog.NewTextHandler(f, opts)
+	if cfg.Format == model.LogFormatJSON {
+		h = slog.NewJSONHandler(f, opts)
+	}
+	if console != nil {
+		h = tee{h, slog.NewTextHandler(console, &slog.HandlerOptions{Level: slog.LevelWarn})}
+	}
+	return slog.New(h), f, nil
+}
+
+// tee sends each record to every handler that accepts its level.
+type tee []slog.Handler
+
+func (t tee) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t tee) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range t {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (t tee) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(tee, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t tee) WithGroup(name string) slog.Handler {
+	out := make(tee, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// RotatingFile is an append-only log file that is rotated once it grows
+// past MaxBytes: path becomes path.1, path.1 becomes path.2, and so on,
+// keeping Keep old copies.
+type RotatingFile struct {
+	path     string
+	maxBytes int64
+	keep     int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotating opens (or creates) path for appending. maxBytes <= 0
+// disables rotation.
+func OpenRotating(path string, maxBytes int64, keep int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxBytes: maxBytes, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), store.DirMode); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, store.FileMode)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past the limit.
+// The web server, the app, the daemon and CLI commands all write the same
+// log, so Write follows the path when another process rotated it and counts
+// what the others appended.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reopenIfMoved(); err != nil {
+		return 0, err
+	}
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// reopenIfMoved reopens the path when it no longer names the open file and
+// otherwise refreshes the size from it.
+func (r *RotatingFile) reopenIfMoved() error {
+	info, err := os.Stat(r.path)
+	if err == nil {
+		if cur, err := r.f.Stat(); err == nil && os.SameFile(info, cur) {
+			r.size = info.Size()
+			return nil
+		}
+	}
+	r.f.Close()
+	return r.open()
+}
+
+func (r *RotatingFile) rotate() error {
+	r.f.Close()
+	if r.keep <= 0 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.keep))
+		for i := r.keep - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotate log: %w", err)
+		}
+	}
+	return r.open()
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// Tail returns the last n lines of the file at path.
+func Tail(path string, n int) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil, nil
+	}
+	if n >= 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// Follow copies what is appended to path to w until ctx is done, checking
+// every poll. It starts at the current end of the file, or at its start with
+// fromStart. After a rotation it carries on with the new file from its start.
+func Follow(ctx context.Context, path string, w io.Writer, poll time.Duration, fromStart bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+	var offset int64
+	if !fromStart {
+		if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, 32*1024)
+	var partial []byte
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			offset += int64(n)
+			// Only whole lines, so a line written in two parts isn't split.
+			partial = append(partial, buf[:n]...)
+			if i := bytes.LastIndexByte(partial, '\n'); i >= 0 {
+				if _, werr := w.Write(partial[:i+1]); werr != nil {
+					return werr
+				}
+				partial = append(partial[:0], partial[i+1:]...)
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(poll):
+		}
+
+		// Reopen when the file was rotated away or truncated.
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			continue // between rotation's rename and the new file
+		}
+		cur, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(info, cur) || info.Size() < offset {
+			nf, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			// Lines written to the old file right before the rotation.
+			if rest, _ := io.ReadAll(f); !os.SameFile(info, cur) && len(rest) > 0 {
+				if _, err := w.Write(append(partial, rest...)); err != nil {
+					nf.Close()
+					return err
+				}
+			}
+			f.Close()
+			f, offset, partial = nf, 0, partial[:0]
+		}
+	}
+}
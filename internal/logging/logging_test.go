@@ -0,0 +1,231 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         model.LogConfig
+		wantFile    []string
+		notFile     []string
+		wantConsole []string
+		notConsole  []string
+	}{
+		{
+			name:        "text at info",
+			cfg:         model.LogConfig{Level: "info", Format: model.LogFormatText},
+			wantFile:    []string{"level=INFO msg=started", "level=WARN msg=slow"},
+			notFile:     []string{"details"},
+			wantConsole: []string{"level=WARN msg=slow"},
+			notConsole:  []string{"started"},
+		},
+		{
+			name:     "json at debug",
+			cfg:      model.LogConfig{Level: "debug", Format: model.LogFormatJSON},
+			wantFile: []string{`"msg":"details"`, `"msg":"started"`, `"n":3`},
+		},
+		{
+			name:    "error only",
+			cfg:     model.LogConfig{Level: "error", Format: model.LogFormatText},
+			notFile: []string{"started", "slow"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataRoot := t.TempDir()
+			var console bytes.Buffer
+			logger, closer, err := New(tt.cfg, dataRoot, &console)
+			require.NoError(t, err)
+			logger.Debug("details")
+			logger.Info("started", "n", 3)
+			logger.Warn("slow")
+			require.NoError(t, closer.Close())
+
+			data, err := os.ReadFile(Path(dataRoot))
+			require.NoError(t, err)
+			for _, s := range tt.wantFile {
+				assert.Contains(t, string(data), s)
+			}
+			for _, s := range tt.notFile {
+				assert.NotContains(t, string(data), s)
+			}
+			for _, s := range tt.wantConsole {
+				assert.Contains(t, console.String(), s)
+			}
+			for _, s := range tt.notConsole {
+				assert.NotContains(t, console.String(), s)
+			}
+			if tt.cfg.Format == model.LogFormatJSON {
+				for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+					assert.True(t, json.Valid([]byte(line)), "line is not JSON: %s", line)
+				}
+			}
+		})
+	}
+
+	_, _, err := New(model.LogConfig{Level: "loud"}, t.TempDir(), nil)
+	assert.Error(t, err)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", File)
+	f, err := OpenRotating(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"one-----\n", "two-----\n", "three---\n", "four----\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(p string) string {
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "four----\n", read(path))
+	assert.Equal(t, "three---\n", read(path+".1"))
+	assert.Equal(t, "two-----\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "only keep rotated files are retained")
+
+	// Reopening appends and keeps counting the existing size.
+	f, err = OpenRotating(path, 10, 2)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("five\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "five\n", read(path))
+	assert.Equal(t, "four----\n", read(path+".1"))
+}
+
+func TestRotatingFileSharedByProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", File)
+	a, err := OpenRotating(path, 20, 5)
+	require.NoError(t, err)
+	defer a.Close()
+	b, err := OpenRotating(path, 20, 5)
+	require.NoError(t, err)
+	defer b.Close()
+
+	var want []string
+	for i := 0; i < 8; i++ {
+		w := a
+		if i%2 == 1 {
+			w = b
+		}
+		line := fmt.Sprintf("line-%d\n", i)
+		_, err := w.Write([]byte(line))
+		require.NoError(t, err)
+		want = append(want, strings.TrimSpace(line))
+	}
+
+	// Oldest generation first: every line lands exactly once, in order, and
+	// no generation grows past the limit.
+	var got []string
+	for i := 5; i >= 0; i-- {
+		p := path
+		if i > 0 {
+			p = fmt.Sprintf("%s.%d", path, i)
+		}
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(data), 20, p)
+		got = append(got, strings.Fields(string(data))...)
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), File)
+	require.NoError(t, os.WriteFile(path, []byte("a\nb\nc\n"), 0600))
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{2, []string{"b", "c"}},
+		{10, []string{"a", "b", "c"}},
+		{0, []string{}},
+	}
+	for _, tt := range tests {
+		got, err := Tail(path, tt.n)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	got, err := Tail(path, 5)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+// syncBuffer is a bytes.Buffer safe to read while Follow writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", File)
+	f, err := OpenRotating(path, 20, 1)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- Follow(ctx, path, &out, 5*time.Millisecond, false) }()
+	time.Sleep(20 * time.Millisecond) // let Follow seek to the end
+
+	// A partial line is held back until it is complete.
+	_, err = f.Write([]byte("after"))
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, out.String())
+	_, err = f.Write([]byte("\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return out.String() == "after\n" }, 2*time.Second, 5*time.Millisecond)
+
+	// This write rotates the file; Follow moves on to the new one.
+	_, err = f.Write([]byte("rotated-line\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return out.String() == "after\nrotated-line\n" }, 2*time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.NotContains(t, out.String(), "before")
+}
@@ -22,7 +22,9 @@ type jsonRow struct {
 }
 
 type messageWrapper struct {
+	ID      string          `json:"id"`
 	Role    string          `json:"role"`
+	Model   string          `json:"model"`
 	Content json.RawMessage `json:"content"`
 	Usage   *usageBlock     `json:"usage"`
 }
@@ -92,7 +94,7 @@ func ParseSessionFile(path string, projectSlug string, sessionID string) ([]mode
 				continue
 			}
 
-			epoch := parseEpoch(row.Timestamp)
+			epoch := ParseEpoch(row.Timestamp)
 			billable := u.InputTokens + u.OutputTokens
 			totalWithCache := billable + u.CacheReadInputTokens + u.CacheCreationInputTokens
 
@@ -109,6 +111,8 @@ func ParseSessionFile(path string, projectSlug string, sessionID string) ([]mode
 				TotalWithCache: totalWithCache,
 				ContentType:    contentType(row.Message.Content),
 				Signature:      sig,
+				Model:          row.Message.Model,
+				ID:             model.EventID(model.ProviderClaude, sessionID, model.MessageKey(row.Message.ID, row.Timestamp, sig)),
 			})
 
 			lastSig = sig
@@ -173,7 +177,7 @@ func ParseSessionFileLive(path string, projectSlug string, sessionID string) ([]
 				continue
 			}
 
-			epoch := parseEpoch(row.Timestamp)
+			epoch := ParseEpoch(row.Timestamp)
 			billable := u.InputTokens + u.OutputTokens
 			totalWithCache := billable + u.CacheReadInputTokens + u.CacheCreationInputTokens
 
@@ -191,6 +195,8 @@ func ParseSessionFileLive(path string, projectSlug string, sessionID string) ([]
 					TotalWithCache: totalWithCache,
 					ContentType:    contentType(row.Message.Content),
 					Signature:      sig,
+					Model:          row.Message.Model,
+					ID:             model.EventID(model.ProviderClaude, sessionID, model.MessageKey(row.Message.ID, row.Timestamp, sig)),
 				},
 				PromptPreview: lastPrompt,
 			})
@@ -226,9 +232,9 @@ func ExtractProjectPath(path string) string {
 	return ""
 }
 
-// parseEpoch parses an ISO timestamp to Unix epoch.
+// ParseEpoch parses an ISO timestamp to Unix epoch.
 // Handles fractional seconds by stripping them before parsing.
-func parseEpoch(ts string) int64 {
+func ParseEpoch(ts string) int64 {
 	if ts == "" {
 		return 0
 	}
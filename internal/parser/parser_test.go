@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -52,6 +53,22 @@ func TestParseSessionFile(t *testing.T) {
 				assert.Equal(t, "200|150|40|15", e1.Signature)
 			},
 		},
+		{
+			name:      "session with model names and message IDs",
+			fixture:   "model_session.jsonl",
+			slug:      "model-project",
+			sessionID: "session-model",
+			wantCount: 2,
+			checkEvents: func(t *testing.T, events []TokenEventResult) {
+				assert.Equal(t, "claude-sonnet-4-5-20250929", events[0].Model)
+				assert.Equal(t, "claude-opus-4-1-20250805", events[1].Model)
+
+				// IDs come from the API message ID when logged, else the timestamp.
+				assert.Equal(t, model.EventID("claude", "session-model", "2025-01-15T10:30:00.000Z|100|50|0|0"), events[0].ID)
+				assert.Equal(t, model.EventID("claude", "session-model", "msg_01ABCdef|300|90|0|0"), events[1].ID)
+				assert.Len(t, events[1].ID, 32)
+			},
+		},
 		{
 			name:      "tool use session",
 			fixture:   "tool_use_session.jsonl",
@@ -154,6 +171,11 @@ type TokenEventResult = struct {
 	TotalWithCache int64  `json:"total_with_cache"`
 	ContentType    string `json:"content_type"`
 	Signature      string `json:"signature"`
+	Model          string `json:"model"`
+	ID             string `json:"id"`
+	Seq            int64  `json:"seq"`
+	Account        string `json:"account"`
+	Org            string `json:"org"`
 }
 
 func TestParseSessionFileLive(t *testing.T) {
@@ -290,7 +312,7 @@ func TestParseEpoch(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := parseEpoch(tt.ts)
+			got := ParseEpoch(tt.ts)
 			assert.Equal(t, tt.want, got)
 		})
 	}
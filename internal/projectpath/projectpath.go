@@ -0,0 +1,168 @@
+// Package projectpath recovers project directories for session slugs and
+// applies the user's alias file for projects that were renamed or moved.
+package projectpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AliasesFile is the optional alias file read from DataRoot. It maps an old
+// slug or absolute path to the directory the project lives in now:
+//
+//	{"-Users-me-old-name": "/Users/me/new-name", "/Users/me/archive": "/Volumes/x/archive"}
+//
+// Path keys also move everything below them.
+const AliasesFile = "project-aliases.json"
+
+// UnknownPrefix marks projects.json paths that could not be resolved.
+const UnknownPrefix = "/unknown/"
+
+// maxReadDirs bounds the directories a single FromSlug call may list, so a
+// pathological slug can't walk the whole filesystem.
+const maxReadDirs = 256
+
+// Aliases maps old slugs and paths to current project paths.
+type Aliases map[string]string
+
+// LoadAliases reads the alias file at path. A missing file yields no aliases.
+func LoadAliases(path string) (Aliases, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var a Aliases
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	for from, to := range a {
+		if !filepath.IsAbs(to) {
+			return nil, fmt.Errorf("parse %s: alias for %q must be an absolute path, got %q", filepath.Base(path), from, to)
+		}
+	}
+	return a, nil
+}
+
+// Apply returns where the project with slug and path lives now. An exact
+// slug alias wins; otherwise the longest path alias that is path or one of
+// its parents is substituted. Without a match path is returned unchanged.
+func (a Aliases) Apply(slug, path string) string {
+	if to, ok := a[slug]; ok {
+		return filepath.Clean(to)
+	}
+	best, to := "", ""
+	for from, target := range a {
+		if !filepath.IsAbs(from) {
+			continue
+		}
+		from = filepath.Clean(from)
+		if len(from) <= len(best) {
+			continue
+		}
+		if path == from || strings.HasPrefix(path, from+"/") {
+			best, to = from, target
+		}
+	}
+	if best == "" {
+		return path
+	}
+	return filepath.Clean(to + strings.TrimPrefix(path, best))
+}
+
+// Resolver reconstructs project paths from slugs by probing the filesystem.
+type Resolver struct {
+	// Root is prepended to every probed path; "" means the real filesystem
+	// root. Tests point it at a temporary directory.
+	Root string
+}
+
+// Encode returns the slug a session log directory gets for path: every
+// character other than an ASCII letter or digit becomes "-". Like the
+// JavaScript that names those directories, it counts UTF-16 code units, so
+// characters outside the BMP become "--".
+func Encode(path string) string {
+	var b strings.Builder
+	for _, c := range path {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			b.WriteRune(c)
+		case c > 0xFFFF:
+			b.WriteString("--")
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// FromSlug returns an existing directory whose Encode is slug, or "" when
+// none is found. Slugs are lossy ("/a-b" and "/a/b" both encode to "-a-b"),
+// so at each level the longest matching directory name is tried first.
+func (r Resolver) FromSlug(slug string) string {
+	if !strings.HasPrefix(slug, "-") {
+		return ""
+	}
+	budget := maxReadDirs
+	return r.probe("/", slug[1:], &budget)
+}
+
+func (r Resolver) probe(dir, rest string, budget *int) string {
+	if rest == "" {
+		return dir
+	}
+	if *budget <= 0 {
+		return ""
+	}
+	*budget--
+
+	entries, err := os.ReadDir(filepath.Join(r.root(), dir))
+	if err != nil {
+		return ""
+	}
+	type candidate struct{ name, enc string }
+	var candidates []candidate
+	for _, e := range entries {
+		enc := Encode(e.Name())
+		if rest != enc && !strings.HasPrefix(rest, enc+"-") {
+			continue
+		}
+		if !r.isDir(filepath.Join(dir, e.Name())) {
+			continue
+		}
+		candidates = append(candidates, candidate{e.Name(), enc})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(candidates[i].enc) != len(candidates[j].enc) {
+			return len(candidates[i].enc) > len(candidates[j].enc)
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	for _, c := range candidates {
+		next := strings.TrimPrefix(rest[len(c.enc):], "-")
+		if found := r.probe(filepath.Join(dir, c.name), next, budget); found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// isDir follows symlinks, since project directories are often linked.
+func (r Resolver) isDir(path string) bool {
+	fi, err := os.Stat(filepath.Join(r.root(), path))
+	return err == nil && fi.IsDir()
+}
+
+func (r Resolver) root() string {
+	if r.Root == "" {
+		return "/"
+	}
+	return r.Root
+}
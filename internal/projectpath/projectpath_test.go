@@ -0,0 +1,116 @@
+package projectpath
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/Users/me/my-project", "-Users-me-my-project"},
+		{"/Users/me/.config/app_v2", "-Users-me--config-app-v2"},
+		{"/home/me/café", "-home-me-caf-"},
+		{"/tmp/🚀", "-tmp---"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, Encode(tt.path))
+		})
+	}
+}
+
+func TestResolverFromSlug(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"Users/me/my-project",
+		"Users/me/.config/app_v2",
+		"Users/me/a/b",
+		"Users/me/a-b-c",
+		"Users/me/x-y",
+		"Users/me/x/y/z",
+		"home/me/café",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Users", "me", "file-x"), nil, 0644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "Users", "me", "my-project"), filepath.Join(root, "Users", "me", "linked")))
+
+	r := Resolver{Root: root}
+	tests := []struct {
+		name string
+		slug string
+		want string
+	}{
+		{"dash in directory name", "-Users-me-my-project", "/Users/me/my-project"},
+		{"dot and underscore", "-Users-me--config-app-v2", "/Users/me/.config/app_v2"},
+		{"nested directories", "-Users-me-a-b", "/Users/me/a/b"},
+		{"prefers the longest name", "-Users-me-a-b-c", "/Users/me/a-b-c"},
+		{"backtracks from a dead end", "-Users-me-x-y-z", "/Users/me/x/y/z"},
+		{"non-ASCII", "-home-me-caf-", "/home/me/café"},
+		{"follows symlinks", "-Users-me-linked", "/Users/me/linked"},
+		{"files are not projects", "-Users-me-file-x", ""},
+		{"missing directory", "-Users-me-gone", ""},
+		{"not a slug", "Users-me", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.FromSlug(tt.slug))
+		})
+	}
+}
+
+func TestAliases(t *testing.T) {
+	a := Aliases{
+		"-Users-me-old-name":     "/Users/me/new-name",
+		"/Users/me/archive/":     "/Volumes/ext/archive",
+		"/Users/me/archive/keep": "/Users/me/keep",
+	}
+
+	tests := []struct {
+		name string
+		slug string
+		path string
+		want string
+	}{
+		{"slug alias", "-Users-me-old-name", "/unknown/-Users-me-old-name", "/Users/me/new-name"},
+		{"path alias", "-Users-me-archive", "/Users/me/archive", "/Volumes/ext/archive"},
+		{"moves subdirectories", "-Users-me-archive-x", "/Users/me/archive/x/y", "/Volumes/ext/archive/x/y"},
+		{"longest path wins", "-Users-me-archive-keep", "/Users/me/archive/keep", "/Users/me/keep"},
+		{"prefix is not a parent", "-Users-me-archived", "/Users/me/archived", "/Users/me/archived"},
+		{"no alias", "-Users-me-other", "/Users/me/other", "/Users/me/other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, a.Apply(tt.slug, tt.path))
+		})
+	}
+}
+
+func TestLoadAliases(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := LoadAliases(filepath.Join(dir, AliasesFile))
+	require.NoError(t, err)
+	assert.Empty(t, a)
+
+	path := filepath.Join(dir, AliasesFile)
+	require.NoError(t, os.WriteFile(path, []byte(`{"-old": "/new"}`), 0600))
+	a, err = LoadAliases(path)
+	require.NoError(t, err)
+	assert.Equal(t, Aliases{"-old": "/new"}, a)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"-old": "relative"}`), 0600))
+	_, err = LoadAliases(path)
+	assert.ErrorContains(t, err, "absolute path")
+
+	require.NoError(t, os.WriteFile(path, []byte(`[`), 0600))
+	_, err = LoadAliases(path)
+	assert.Error(t, err)
+}
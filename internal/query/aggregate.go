@@ -0,0 +1,125 @@
+package query
+
+import (
+	"sort"
+
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Aggregator consumes the events produced by Scan.
+type Aggregator interface {
+	Add(e model.TokenEvent)
+}
+
+// AggregatorFunc adapts a plain function to the Aggregator interface.
+type AggregatorFunc func(e model.TokenEvent)
+
+// Add calls f(e).
+func (f AggregatorFunc) Add(e model.TokenEvent) { f(e) }
+
+// Totals sums token counts across events.
+type Totals struct {
+	Events         int64 `json:"events"`
+	Input          int64 `json:"input"`
+	Output         int64 `json:"output"`
+	CacheRead      int64 `json:"cache_read"`
+	CacheCreate    int64 `json:"cache_create"`
+	Billable       int64 `json:"billable"`
+	TotalWithCache int64 `json:"total_with_cache"`
+}
+
+// Add accumulates e.
+func (t *Totals) Add(e model.TokenEvent) {
+	t.Events++
+	t.Input += e.Input
+	t.Output += e.Output
+	t.CacheRead += e.CacheRead
+	t.CacheCreate += e.CacheCreate
+	t.Billable += e.Billable
+	t.TotalWithCache += e.TotalWithCache
+}
+
+// Value returns the named metric summed in t, defaulting to billable.
+func (t Totals) Value(metric string) int64 {
+	return MetricValue(model.TokenEvent{
+		Input:          t.Input,
+		Output:         t.Output,
+		CacheRead:      t.CacheRead,
+		CacheCreate:    t.CacheCreate,
+		Billable:       t.Billable,
+		TotalWithCache: t.TotalWithCache,
+	}, metric)
+}
+
+// Series sums one metric into fixed-width time buckets keyed by bucket start.
+type Series struct {
+	Metric  string
+	Width   int64
+	Buckets map[int64]int64
+}
+
+// NewSeries creates a Series for metric with buckets of width seconds.
+func NewSeries(metric string, width int64) *Series {
+	if width <= 0 {
+		width = 1
+	}
+	return &Series{Metric: metric, Width: width, Buckets: make(map[int64]int64)}
+}
+
+// Add accumulates e into its bucket.
+func (s *Series) Add(e model.TokenEvent) {
+	b := (e.TSEpoch / s.Width) * s.Width
+	s.Buckets[b] += MetricValue(e, s.Metric)
+}
+
+// Keys returns the bucket starts in ascending order.
+func (s *Series) Keys() []int64 {
+	keys := make([]int64, 0, len(s.Buckets))
+	for k := range s.Buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// GroupBy keeps separate Totals per key, e.g. per project or model.
+type GroupBy struct {
+	Key    func(e model.TokenEvent) string
+	Groups map[string]*Totals
+}
+
+// NewGroupBy creates a GroupBy keyed by key.
+func NewGroupBy(key func(e model.TokenEvent) string) *GroupBy {
+	return &GroupBy{Key: key, Groups: make(map[string]*Totals)}
+}
+
+// Add accumulates e into its group.
+func (g *GroupBy) Add(e model.TokenEvent) {
+	k := g.Key(e)
+	t, ok := g.Groups[k]
+	if !ok {
+		t = &Totals{}
+		g.Groups[k] = t
+	}
+	t.Add(e)
+}
+
+// MetricValue returns the named metric of e, defaulting to billable.
+func MetricValue(e model.TokenEvent, metric string) int64 {
+	switch metric {
+	case "input":
+		return e.Input
+	case "output":
+		return e.Output
+	case "cache_read":
+		return e.CacheRead
+	case "cache_create":
+		return e.CacheCreate
+	case "billable":
+		return e.Billable
+	case "total_with_cache":
+		return e.TotalWithCache
+	default:
+		return e.Billable
+	}
+}
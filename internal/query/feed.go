@@ -0,0 +1,91 @@
+package query
+
+import (
+	"container/heap"
+	"os"
+	"sort"
+
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Feed keeps the Limit lowest-sequence events it is given, so a change-feed
+// page can be cut from an epoch-ordered scan without holding the whole
+// tail in memory. Combine it with Filter.AfterSeq to read past a cursor.
+type Feed struct {
+	Limit   int // 0 keeps every event
+	Matched int // Events added, including those beyond Limit
+	kept    seqHeap
+}
+
+// NewFeed creates a Feed returning at most limit events.
+func NewFeed(limit int) *Feed {
+	return &Feed{Limit: limit}
+}
+
+// Add offers e to the feed.
+func (f *Feed) Add(e model.TokenEvent) {
+	f.Matched++
+	heap.Push(&f.kept, e)
+	if f.Limit > 0 && f.kept.Len() > f.Limit {
+		heap.Pop(&f.kept)
+	}
+}
+
+// Events returns the kept events in sequence order.
+func (f *Feed) Events() []model.TokenEvent {
+	events := append([]model.TokenEvent{}, f.kept...)
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events
+}
+
+// HasMore reports whether events were dropped because of Limit.
+func (f *Feed) HasMore() bool {
+	return f.Matched > f.kept.Len()
+}
+
+// Cursor returns the cursor to resume from after this page: the highest
+// kept sequence number, or after when the page is empty.
+func (f *Feed) Cursor(after int64) int64 {
+	for _, e := range f.kept {
+		if e.Seq > after {
+			after = e.Seq
+		}
+	}
+	return after
+}
+
+// seqHeap is a max-heap on Seq, so the event to evict is at the root.
+type seqHeap []model.TokenEvent
+
+func (h seqHeap) Len() int           { return len(h) }
+func (h seqHeap) Less(i, j int) bool { return h[i].Seq > h[j].Seq }
+func (h seqHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *seqHeap) Push(x any)        { *h = append(*h, x.(model.TokenEvent)) }
+func (h *seqHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Page is one read of the change feed.
+type Page struct {
+	Events  []model.TokenEvent `json:"events"`
+	Cursor  int64              `json:"cursor"`   // Pass as the next "after"
+	HasMore bool               `json:"has_more"` // More events are already past Cursor
+}
+
+// ReadFeed returns up to limit events matching f that were ingested after
+// f.AfterSeq, in sequence order. A missing events file is an empty page.
+func ReadFeed(eventsPath string, v *vault.Vault, f Filter, limit int) (*Page, error) {
+	feed := NewFeed(limit)
+	if err := Scan(eventsPath, v, f, feed); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	events := feed.Events()
+	if events == nil {
+		events = []model.TokenEvent{}
+	}
+	return &Page{Events: events, Cursor: feed.Cursor(f.AfterSeq), HasMore: feed.HasMore()}, nil
+}
@@ -0,0 +1,71 @@
+package query
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFeed(t *testing.T) {
+	t.Parallel()
+
+	// Sequence numbers deliberately disagree with epoch order, as they do
+	// when an old session file is picked up late.
+	events := []model.TokenEvent{
+		{TSEpoch: 1000, SessionID: "s", ProjectSlug: "alpha", ID: "e1", Seq: 3},
+		{TSEpoch: 1001, SessionID: "s", ProjectSlug: "beta", ID: "e2", Seq: 1},
+		{TSEpoch: 1002, SessionID: "s", ProjectSlug: "alpha", ID: "e3", Seq: 5},
+		{TSEpoch: 1003, SessionID: "s", ProjectSlug: "alpha", ID: "e4", Seq: 2},
+		{TSEpoch: 1004, SessionID: "s", ProjectSlug: "beta", ID: "e5", Seq: 4},
+	}
+	for i := range events {
+		events[i].TSISO, events[i].ContentType, events[i].Signature = "-", "text", "sig"
+	}
+	path := filepath.Join(t.TempDir(), "events.tsv")
+	require.NoError(t, store.WriteEventsTSV(path, events, nil))
+
+	tests := []struct {
+		name        string
+		filter      Filter
+		limit       int
+		wantIDs     []string
+		wantCursor  int64
+		wantHasMore bool
+	}{
+		{name: "everything", wantIDs: []string{"e2", "e4", "e1", "e5", "e3"}, wantCursor: 5},
+		{name: "after cursor", filter: Filter{AfterSeq: 2}, wantIDs: []string{"e1", "e5", "e3"}, wantCursor: 5},
+		{name: "paged", filter: Filter{AfterSeq: 1}, limit: 2, wantIDs: []string{"e4", "e1"}, wantCursor: 3, wantHasMore: true},
+		{name: "caught up", filter: Filter{AfterSeq: 5}, wantIDs: []string{}, wantCursor: 5},
+		{name: "filtered", filter: Filter{Project: "alpha", AfterSeq: 2}, wantIDs: []string{"e1", "e3"}, wantCursor: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			page, err := ReadFeed(path, nil, tt.filter, tt.limit)
+			require.NoError(t, err)
+
+			ids := []string{}
+			for _, e := range page.Events {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantCursor, page.Cursor)
+			assert.Equal(t, tt.wantHasMore, page.HasMore)
+		})
+	}
+}
+
+func TestReadFeedMissingStore(t *testing.T) {
+	t.Parallel()
+
+	page, err := ReadFeed(filepath.Join(t.TempDir(), "events.tsv"), nil, Filter{AfterSeq: 7}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Events)
+	assert.Equal(t, int64(7), page.Cursor)
+}
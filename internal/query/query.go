@@ -0,0 +1,245 @@
+// Package query streams token events from a sorted events.tsv without
+// loading the whole history into memory.
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Filter selects events. Zero values match everything; Until is exclusive.
+type Filter struct {
+	Since    int64
+	Until    int64
+	Project  string
+	Session  string
+	Model    string
+	Account  string // Account UUID
+	Org      string // Organization UUID
+	AfterSeq int64  // Only events ingested after this cursor
+}
+
+// ranges are the named time ranges of reports and the dashboard, in seconds.
+var ranges = map[string]int64{
+	"1h":  3600,
+	"3h":  10800,
+	"6h":  21600,
+	"12h": 43200,
+	"24h": 86400,
+	"30h": 108000,
+	"48h": 172800,
+	"7d":  604800,
+	"14d": 1209600,
+	"30d": 2592000,
+	"all": 0,
+}
+
+// RangeSeconds returns the length of a named range such as "24h" or "7d";
+// "all" is 0.
+func RangeSeconds(name string) (int64, error) {
+	sec, ok := ranges[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown range: %s", name)
+	}
+	return sec, nil
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e model.TokenEvent) bool {
+	if f.Since > 0 && e.TSEpoch < f.Since {
+		return false
+	}
+	if f.Until > 0 && e.TSEpoch >= f.Until {
+		return false
+	}
+	if f.Project != "" && e.ProjectSlug != f.Project {
+		return false
+	}
+	if f.Session != "" && e.SessionID != f.Session {
+		return false
+	}
+	if f.Model != "" && e.Model != f.Model {
+		return false
+	}
+	if f.Account != "" && e.Account != f.Account {
+		return false
+	}
+	if f.Org != "" && e.Org != f.Org {
+		return false
+	}
+	if f.AfterSeq > 0 && e.Seq <= f.AfterSeq {
+		return false
+	}
+	return true
+}
+
+// Scan streams the events in eventsPath that match f into each aggregator,
+// in file order. It uses the sparse index written at sync time to seek to
+// f.Since and stops at f.Until; a missing or stale index means a full scan.
+// Malformed rows are skipped. Plaintext stores are streamed from disk, but
+// sealed (encrypted) stores are decrypted fully into memory first, so their
+// memory use grows with the store.
+func Scan(eventsPath string, v *vault.Vault, f Filter, aggs ...Aggregator) error {
+	events, err := openSource(eventsPath, v)
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	ext, err := openSource(store.ExtPath(eventsPath), v)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if ext != nil {
+		defer ext.Close()
+	}
+
+	er := bufio.NewReaderSize(events, 64*1024)
+	var xr *bufio.Reader
+	var dec store.ExtDecoder
+	if ext != nil {
+		xr = bufio.NewReaderSize(ext, 64*1024)
+		header, err := xr.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		dec = store.NewExtDecoder(header)
+	}
+
+	// Always consume the events header; seeking below only ever moves forward.
+	if _, err := er.ReadString('\n'); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	// A stale index can't be used to seek, and neither can an older sidecar
+	// without row keys be trusted to line up with events.tsv.
+	ix, fresh := freshIndex(eventsPath, v, events, ext)
+	if xr != nil && !dec.Keyed() && !fresh {
+		xr = nil
+	}
+
+	if f.Since > 0 && fresh {
+		if entry, ok := ix.Lookup(f.Since); ok {
+			if err := events.seek(entry.Offset); err != nil {
+				return err
+			}
+			er.Reset(events)
+			if xr != nil {
+				if err := ext.seek(entry.ExtOffset); err != nil {
+					return err
+				}
+				xr.Reset(ext)
+			}
+		}
+	}
+
+	for {
+		line, err := er.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var extLine string
+		if xr != nil {
+			extLine, _ = xr.ReadString('\n')
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		e, perr := store.UnmarshalTokenEvent(line)
+		if perr != nil {
+			continue
+		}
+		if f.Until > 0 && e.TSEpoch >= f.Until {
+			return nil // rows are sorted by epoch
+		}
+		if xr != nil && extLine != "" {
+			dec.Apply(extLine, &e) // Lines of other rows are left out
+		}
+		store.FillID(&e)
+		if !f.Match(e) {
+			continue
+		}
+		for _, a := range aggs {
+			a.Add(e)
+		}
+	}
+}
+
+// freshIndex reads the index of eventsPath and reports whether it was
+// written with the current events and sidecar files.
+func freshIndex(eventsPath string, v *vault.Vault, events, ext *source) (*store.Index, bool) {
+	ix, err := store.ReadIndex(store.IndexPath(eventsPath), v)
+	if err != nil {
+		return nil, false
+	}
+	var extSize int64
+	if ext != nil {
+		extSize = ext.size
+	}
+	return ix, ix.Fresh(events.size, extSize)
+}
+
+// source is a plaintext view of a data-root file. Plaintext files are
+// streamed from disk; sealed files have to be decrypted into memory first.
+type source struct {
+	io.ReadSeeker
+	size  int64
+	close func() error
+}
+
+func (s *source) Close() error { return s.close() }
+
+func (s *source) seek(off int64) error {
+	_, err := s.Seek(off, io.SeekStart)
+	return err
+}
+
+// openSource opens path for streaming, or decrypts all of it into memory
+// when it is sealed.
+func openSource(path string, v *vault.Vault) (*source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 16)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	if !vault.IsSealed(head[:n]) {
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &source{ReadSeeker: f, size: info.Size(), close: f.Close}, nil
+	}
+	f.Close()
+
+	data, err := store.ReadFile(path, v)
+	if err != nil {
+		return nil, err
+	}
+	return &source{ReadSeeker: bytes.NewReader(data), size: int64(len(data)), close: func() error { return nil }}, nil
+}
@@ -0,0 +1,287 @@
+package query
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const header = "ts_epoch\tts_iso\tproject_slug\tsession_id\tinput\toutput\tcache_read\tcache_create\tbillable\ttotal_with_cache\tcontent_type\tsignature\n"
+
+func collect(t *testing.T, path string, v *vault.Vault, f Filter) []model.TokenEvent {
+	t.Helper()
+	var got []model.TokenEvent
+	require.NoError(t, Scan(path, v, f, AggregatorFunc(func(e model.TokenEvent) {
+		got = append(got, e)
+	})))
+	return got
+}
+
+func TestScanPlainFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		content    string
+		wantCount  int
+		wantInput0 int64
+	}{
+		{
+			name:      "empty file with header only",
+			content:   header,
+			wantCount: 0,
+		},
+		{
+			name:      "empty file",
+			content:   "",
+			wantCount: 0,
+		},
+		{
+			name:       "single event",
+			content:    header + "1736937000\t2025-01-15T10:30:00Z\ttest\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig\n",
+			wantCount:  1,
+			wantInput0: 100,
+		},
+		{
+			name: "multiple events",
+			content: header +
+				"1736937000\t2025-01-15T10:30:00Z\ttest\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig1\n" +
+				"1736937060\t2025-01-15T10:31:00Z\ttest\ts2\t200\t75\t30\t15\t275\t320\ttext\tsig2\n",
+			wantCount:  2,
+			wantInput0: 100,
+		},
+		{
+			name:       "missing trailing newline",
+			content:    header + "1736937000\t2025-01-15T10:30:00Z\ttest\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig",
+			wantCount:  1,
+			wantInput0: 100,
+		},
+		{
+			name: "skips blank lines",
+			content: "header\n" +
+				"\n" +
+				"1736937000\t2025-01-15T10:30:00Z\ttest\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig\n" +
+				"\n",
+			wantCount:  1,
+			wantInput0: 100,
+		},
+		{
+			name: "skips malformed lines",
+			content: "header\n" +
+				"bad\tdata\n" +
+				"1736937000\t2025-01-15T10:30:00Z\ttest\ts1\t100\t50\t20\t10\t150\t180\ttext\tsig\n",
+			wantCount:  1,
+			wantInput0: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "events.tsv")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+
+			events := collect(t, path, nil, Filter{})
+			assert.Len(t, events, tt.wantCount)
+			if tt.wantCount > 0 {
+				assert.Equal(t, tt.wantInput0, events[0].Input)
+			}
+		})
+	}
+}
+
+func TestScanFileNotFound(t *testing.T) {
+	t.Parallel()
+
+	err := Scan("/nonexistent/path/events.tsv", nil, Filter{})
+	assert.Error(t, err)
+}
+
+// writeStore writes n events one second apart starting at epoch 1000,
+// alternating projects, sessions and models.
+func writeStore(t *testing.T, n int, v *vault.Vault) string {
+	t.Helper()
+	events := make([]model.TokenEvent, n)
+	projects := []string{"alpha", "beta"}
+	models := []string{"claude-sonnet-4-5", "claude-opus-4-1", ""}
+	for i := range events {
+		events[i] = model.TokenEvent{
+			TSEpoch:     int64(1000 + i),
+			TSISO:       "-",
+			ProjectSlug: projects[i%2],
+			SessionID:   projects[i%2] + "-s",
+			Input:       1,
+			Output:      2,
+			Billable:    3,
+			ContentType: "text",
+			Signature:   "sig",
+			Model:       models[i%3],
+		}
+	}
+	path := filepath.Join(t.TempDir(), "events.tsv")
+	require.NoError(t, store.WriteEventsTSV(path, events, v))
+	return path
+}
+
+func TestScanFilters(t *testing.T) {
+	t.Parallel()
+
+	n := store.IndexStride*3 + 17
+	key := bytes.Repeat([]byte{4}, 32)
+	v, err := vault.New(key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		filter    Filter
+		wantCount int
+		wantFirst int64
+	}{
+		{name: "everything", filter: Filter{}, wantCount: n, wantFirst: 1000},
+		{name: "since mid stride", filter: Filter{Since: 1000 + 1500}, wantCount: n - 1500, wantFirst: 2500},
+		{name: "since on index entry", filter: Filter{Since: 1000 + 2*int64(store.IndexStride)}, wantCount: n - 2*store.IndexStride, wantFirst: 1000 + 2*int64(store.IndexStride)},
+		{name: "since past end", filter: Filter{Since: 99999}, wantCount: 0},
+		{name: "window", filter: Filter{Since: 1100, Until: 1110}, wantCount: 10, wantFirst: 1100},
+		{name: "project", filter: Filter{Project: "beta"}, wantCount: n / 2, wantFirst: 1001},
+		{name: "session", filter: Filter{Session: "alpha-s", Since: 1003}, wantCount: (n - 3) / 2, wantFirst: 1004},
+		{name: "model", filter: Filter{Model: "claude-opus-4-1", Until: 1010}, wantCount: 3, wantFirst: 1001},
+	}
+
+	for _, sealed := range []bool{false, true} {
+		var vv *vault.Vault
+		if sealed {
+			vv = v
+		}
+		path := writeStore(t, n, vv)
+
+		for _, tt := range tests {
+			name := tt.name
+			if sealed {
+				name += " sealed"
+			}
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				events := collect(t, path, vv, tt.filter)
+				require.Len(t, events, tt.wantCount)
+				if tt.wantCount > 0 {
+					assert.Equal(t, tt.wantFirst, events[0].TSEpoch)
+				}
+				for _, e := range events {
+					assert.True(t, tt.filter.Match(e))
+					assert.Equal(t, []string{"claude-sonnet-4-5", "claude-opus-4-1", ""}[(e.TSEpoch-1000)%3], e.Model, "ext row stays aligned")
+				}
+			})
+		}
+	}
+}
+
+func TestScanStaleIndex(t *testing.T) {
+	t.Parallel()
+
+	path := writeStore(t, store.IndexStride*2, nil)
+
+	// Rewrite events.tsv behind the index's back (e.g. by the shell script):
+	// the index no longer matches and Scan must fall back to a full read.
+	row := "1500\t-\tgamma\tg-s\t1\t1\t0\t0\t2\t2\ttext\tsig\n"
+	require.NoError(t, os.WriteFile(path, []byte(header+row), 0644))
+	require.NoError(t, os.Remove(store.ExtPath(path)))
+
+	events := collect(t, path, nil, Filter{Since: 1400})
+	require.Len(t, events, 1)
+	assert.Equal(t, "gamma", events[0].ProjectSlug)
+	assert.Equal(t, "", events[0].Model)
+}
+
+func TestScanIgnoresExtOfOtherRows(t *testing.T) {
+	t.Parallel()
+
+	// events.tsv rewritten behind the sidecar's back: the first row is still
+	// the one the sidecar was written for, the second is new.
+	rows := "1000\t-\talpha\talpha-s\t1\t2\t0\t0\t3\t0\ttext\tsig\n" +
+		"1500\t-\tgamma\tg-s\t1\t1\t0\t0\t2\t2\ttext\tsig\n"
+
+	tests := []struct {
+		name       string
+		ext        string // Replaces the sidecar when set
+		wantModels []string
+	}{
+		{name: "keyed sidecar", wantModels: []string{"claude-sonnet-4-5", ""}},
+		{name: "older sidecar with a stale index", ext: "model\nclaude-haiku\nclaude-haiku\n", wantModels: []string{"", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := writeStore(t, 6, nil)
+			require.NoError(t, os.WriteFile(path, []byte(header+rows), 0644))
+			if tt.ext != "" {
+				require.NoError(t, os.WriteFile(store.ExtPath(path), []byte(tt.ext), 0644))
+			}
+
+			events := collect(t, path, nil, Filter{})
+			require.Len(t, events, 2)
+			for i, e := range events {
+				assert.Equal(t, tt.wantModels[i], e.Model, e.TSEpoch)
+			}
+		})
+	}
+}
+
+func TestAggregators(t *testing.T) {
+	t.Parallel()
+
+	path := writeStore(t, 10, nil)
+
+	var totals Totals
+	series := NewSeries("output", 5)
+	byProject := NewGroupBy(func(e model.TokenEvent) string { return e.ProjectSlug })
+	require.NoError(t, Scan(path, nil, Filter{}, &totals, series, byProject))
+
+	assert.Equal(t, Totals{Events: 10, Input: 10, Output: 20, Billable: 30}, totals)
+	assert.Equal(t, []int64{1000, 1005}, series.Keys())
+	assert.Equal(t, int64(10), series.Buckets[1000])
+	require.Len(t, byProject.Groups, 2)
+	assert.Equal(t, int64(5), byProject.Groups["alpha"].Events)
+}
+
+func TestMetricValue(t *testing.T) {
+	t.Parallel()
+
+	e := model.TokenEvent{
+		Input:          100,
+		Output:         50,
+		CacheRead:      20,
+		CacheCreate:    10,
+		Billable:       150,
+		TotalWithCache: 180,
+	}
+
+	tests := []struct {
+		metric string
+		want   int64
+	}{
+		{"input", 100},
+		{"output", 50},
+		{"cache_read", 20},
+		{"cache_create", 10},
+		{"billable", 150},
+		{"total_with_cache", 180},
+		{"unknown_metric", 150}, // defaults to billable
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, MetricValue(e, tt.metric))
+		})
+	}
+}
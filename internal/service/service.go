@@ -0,0 +1,442 @@
+// Package service installs jevons as a per-user login service: systemd user
+// units on Linux, launchd agents on macOS.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/daemon"
+)
+
+// Unit and agent names.
+const (
+	SystemdSyncUnit  = "jevons-sync.service"
+	SystemdWebUnit   = "jevons-web.service"
+	SystemdWebSocket = "jevons-web.socket"
+	LaunchdSyncLabel = "com.giannimassi.jevons.sync"
+	LaunchdWebLabel  = "com.giannimassi.jevons.web"
+)
+
+// DefaultVerifyWait is how long install waits for a healthy heartbeat.
+const DefaultVerifyWait = 30 * time.Second
+
+const healthPoll = 250 * time.Millisecond
+
+// ErrUnsupported is returned on platforms without a supported service manager.
+var ErrUnsupported = errors.New("service install is only supported with systemd (Linux) and launchd (macOS)")
+
+// Spec describes the services to install.
+type Spec struct {
+	Exe       string // Absolute path of the jevons binary
+	DataRoot  string
+	SourceDir string // Passed through only when set, e.g. from CLAUDE_USAGE_SOURCE_DIR
+	Interval  int
+	Port      int
+	Web       bool // Also serve the dashboard
+	Socket    bool // Start the dashboard on the first connection (systemd only)
+}
+
+// File is a generated unit or agent definition.
+type File struct {
+	Path    string
+	Content string
+}
+
+// Manager is the service manager for the running platform.
+type Manager struct {
+	GOOS string
+	Home string
+	UID  int
+
+	// Run executes a service manager command; tests replace it.
+	Run func(name string, args ...string) ([]byte, error)
+}
+
+// NewManager returns the Manager for this machine.
+func NewManager() (*Manager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{GOOS: runtime.GOOS, Home: home, UID: os.Getuid(), Run: runCommand}, nil
+}
+
+func runCommand(name string, args ...string) ([]byte, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// Dir returns where unit or agent files are written.
+func (m *Manager) Dir() (string, error) {
+	switch m.GOOS {
+	case "linux":
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			return filepath.Join(xdg, "systemd", "user"), nil
+		}
+		return filepath.Join(m.Home, ".config", "systemd", "user"), nil
+	case "darwin":
+		return filepath.Join(m.Home, "Library", "LaunchAgents"), nil
+	default:
+		return "", ErrUnsupported
+	}
+}
+
+// Files generates the unit or agent files for s.
+func (m *Manager) Files(s Spec) ([]File, error) {
+	dir, err := m.Dir()
+	if err != nil {
+		return nil, err
+	}
+	if s.Socket && m.GOOS != "linux" {
+		return nil, fmt.Errorf("socket activation is only supported with systemd")
+	}
+	var files []File
+	add := func(name string, tmpl *template.Template, data any) error {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.Join(dir, name), Content: b.String()})
+		return nil
+	}
+
+	sync := unitData{Spec: s, Args: syncArgs(s), Log: daemon.LogPath(s.DataRoot)}
+	web := unitData{Spec: s, Args: webArgs(s), Log: filepath.Join(s.DataRoot, "web", "server.log")}
+	if m.GOOS == "linux" {
+		err = add(SystemdSyncUnit, systemdService, sync.with("Jevons background sync", ""))
+		if err == nil && s.Web {
+			requires := ""
+			if s.Socket {
+				requires = SystemdWebSocket
+			}
+			err = add(SystemdWebUnit, systemdService, web.with("Jevons dashboard", requires))
+		}
+		if err == nil && s.Socket {
+			err = add(SystemdWebSocket, systemdSocket, web)
+		}
+		return files, err
+	}
+
+	err = add(LaunchdSyncLabel+".plist", launchdPlist, sync.with(LaunchdSyncLabel, ""))
+	if err == nil && s.Web {
+		err = add(LaunchdWebLabel+".plist", launchdPlist, web.with(LaunchdWebLabel, ""))
+	}
+	return files, err
+}
+
+func syncArgs(s Spec) []string {
+	return []string{s.Exe, "daemon", "run", "--interval", strconv.Itoa(s.Interval)}
+}
+
+func webArgs(s Spec) []string {
+	return []string{s.Exe, "web", "--no-sync", "--port", strconv.Itoa(s.Port)}
+}
+
+// Install writes the files for s and enables and starts them. It refuses
+// while another process runs the sync loop for s.DataRoot (jevons web, app or
+// daemon start): the service's loop would exit right away.
+func (m *Manager) Install(s Spec) ([]File, error) {
+	files, err := m.Files(s)
+	if err != nil {
+		return nil, err
+	}
+	if pid, alive := daemon.ReadPID(s.DataRoot); alive && pid != m.MainPID() {
+		return nil, fmt.Errorf("%w; stop it (jevons daemon stop, or quit jevons web/app) before installing the service",
+			&daemon.RunningError{PID: pid})
+	}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(f.Path, []byte(f.Content), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.GOOS == "linux" {
+		if _, err := m.Run("systemctl", "--user", "daemon-reload"); err != nil {
+			return files, err
+		}
+		units := []string{SystemdSyncUnit}
+		switch {
+		case s.Socket:
+			units = append(units, SystemdWebSocket)
+		case s.Web:
+			units = append(units, SystemdWebUnit)
+		}
+		if _, err := m.Run("systemctl", append([]string{"--user", "enable"}, units...)...); err != nil {
+			return files, err
+		}
+		// restart rather than start, so a reinstall picks up changed units.
+		_, err := m.Run("systemctl", append([]string{"--user", "restart"}, units...)...)
+		return files, err
+	}
+
+	for _, f := range files {
+		// Reinstalling replaces a loaded agent, so unload it first.
+		m.Run("launchctl", "bootout", m.domain(), f.Path)
+		if _, err := m.Run("launchctl", "bootstrap", m.domain(), f.Path); err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// Uninstall stops and disables every jevons unit or agent and removes its
+// file. It returns the files removed.
+func (m *Manager) Uninstall() ([]string, error) {
+	installed, err := m.Installed()
+	if err != nil {
+		return nil, err
+	}
+	if len(installed) == 0 {
+		return nil, nil
+	}
+
+	if m.GOOS == "linux" {
+		var units []string
+		for _, path := range installed {
+			units = append(units, filepath.Base(path))
+		}
+		m.Run("systemctl", append([]string{"--user", "disable", "--now"}, units...)...)
+	} else {
+		for _, path := range installed {
+			m.Run("launchctl", "bootout", m.domain(), path)
+		}
+	}
+
+	for _, path := range installed {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if m.GOOS == "linux" {
+		m.Run("systemctl", "--user", "daemon-reload")
+	}
+	return installed, nil
+}
+
+// Installed lists the jevons unit or agent files present.
+func (m *Manager) Installed() ([]string, error) {
+	dir, err := m.Dir()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{SystemdSyncUnit, SystemdWebUnit, SystemdWebSocket}
+	if m.GOOS == "darwin" {
+		names = []string{LaunchdSyncLabel + ".plist", LaunchdWebLabel + ".plist"}
+	}
+	var found []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		}
+	}
+	return found, nil
+}
+
+// Active reports whether the service manager has the unit or agent at path
+// running (for a socket unit: listening).
+func (m *Manager) Active(path string) bool {
+	if m.GOOS == "linux" {
+		_, err := m.Run("systemctl", "--user", "is-active", "--quiet", filepath.Base(path))
+		return err == nil
+	}
+	label := strings.TrimSuffix(filepath.Base(path), ".plist")
+	_, err := m.Run("launchctl", "print", m.domain()+"/"+label)
+	return err == nil
+}
+
+func (m *Manager) domain() string {
+	return "gui/" + strconv.Itoa(m.UID)
+}
+
+// MainPID returns the PID the service manager reports for the sync service,
+// or 0 when it isn't running.
+func (m *Manager) MainPID() int {
+	if m.GOOS == "linux" {
+		out, err := m.Run("systemctl", "--user", "show", "-p", "MainPID", "--value", SystemdSyncUnit)
+		if err != nil {
+			return 0
+		}
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+		return pid
+	}
+	out, err := m.Run("launchctl", "print", m.domain()+"/"+LaunchdSyncLabel)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "pid = "); ok {
+			pid, _ := strconv.Atoi(v)
+			return pid
+		}
+	}
+	return 0
+}
+
+// WaitHealthy polls the sync heartbeat until the sync service has completed
+// a sync that started after since, or timeout passes. Only heartbeats written
+// by the service's own process count, so another process syncing the same
+// data root can't pass for it.
+func (m *Manager) WaitHealthy(dataRoot string, since time.Time, timeout time.Duration) (*daemon.HeartbeatState, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		hb := daemon.ReadHeartbeatState(dataRoot)
+		if hb != nil && hb.Mode == "running" && hb.Epoch >= since.Unix() && hb.PID == strconv.Itoa(m.MainPID()) {
+			switch hb.Status {
+			case "ok":
+				return hb, nil
+			case "error", "paused":
+				if hb.LastError != "" {
+					return hb, fmt.Errorf("service is up but its last sync failed: %s", hb.LastError)
+				}
+				return hb, fmt.Errorf("service is up but its last sync failed; see %s", daemon.LogPath(dataRoot))
+			}
+		}
+		if time.Now().After(deadline) {
+			if hb != nil && hb.Mode == "running" && hb.Epoch >= since.Unix() {
+				return hb, fmt.Errorf("no heartbeat from the service within %s: pid %s is syncing this data root instead; see %s",
+					timeout, hb.PID, daemon.LogPath(dataRoot))
+			}
+			return hb, fmt.Errorf("no healthy heartbeat within %s; see %s", timeout, daemon.LogPath(dataRoot))
+		}
+		time.Sleep(healthPoll)
+	}
+}
+
+type unitData struct {
+	Spec
+	Args     []string
+	Log      string
+	Name     string // Description (systemd) or Label (launchd)
+	Requires string
+}
+
+func (u unitData) with(name, requires string) unitData {
+	u.Name, u.Requires = name, requires
+	return u
+}
+
+var funcs = template.FuncMap{
+	// systemdArgs quotes each argument for ExecStart.
+	"systemdArgs": func(args []string) string {
+		quoted := make([]string, len(args))
+		for i, a := range args {
+			quoted[i] = systemdQuote(a)
+		}
+		return strings.Join(quoted, " ")
+	},
+	"systemdEnv": func(k, v string) string {
+		return systemdQuote(k + "=" + v)
+	},
+	"xml": func(s string) string {
+		var b strings.Builder
+		for _, r := range s {
+			switch r {
+			case '&':
+				b.WriteString("&amp;")
+			case '<':
+				b.WriteString("&lt;")
+			case '>':
+				b.WriteString("&gt;")
+			default:
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	},
+}
+
+// systemdQuote double-quotes s, escaping systemd's % specifiers and $
+// variable expansion.
+func systemdQuote(s string) string {
+	s = strings.NewReplacer("%", "%%", "$", "$$").Replace(s)
+	return strconv.Quote(s)
+}
+
+var systemdService = template.Must(template.New("service").Funcs(funcs).Parse(`# Generated by jevons service install; reinstall instead of editing.
+[Unit]
+Description={{.Name}}
+{{- if .Requires}}
+Requires={{.Requires}}
+After={{.Requires}}
+{{- end}}
+
+[Service]
+Type=simple
+ExecStart={{systemdArgs .Args}}
+Environment={{systemdEnv "CLAUDE_USAGE_DATA_DIR" .DataRoot}}
+{{- if .SourceDir}}
+Environment={{systemdEnv "CLAUDE_USAGE_SOURCE_DIR" .SourceDir}}
+{{- end}}
+Restart=on-failure
+RestartSec=10
+TimeoutStopSec=15
+
+[Install]
+WantedBy=default.target
+`))
+
+var systemdSocket = template.Must(template.New("socket").Funcs(funcs).Parse(`# Generated by jevons service install; reinstall instead of editing.
+[Unit]
+Description=Jevons dashboard socket
+
+[Socket]
+ListenStream=127.0.0.1:{{.Port}}
+
+[Install]
+WantedBy=sockets.target
+`))
+
+var launchdPlist = template.Must(template.New("plist").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<!-- Generated by jevons service install; reinstall instead of editing. -->
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Name}}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>CLAUDE_USAGE_DATA_DIR</key>
+		<string>{{xml .DataRoot}}</string>
+{{- if .SourceDir}}
+		<key>CLAUDE_USAGE_SOURCE_DIR</key>
+		<string>{{xml .SourceDir}}</string>
+{{- end}}
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>10</integer>
+	<key>StandardOutPath</key>
+	<string>{{xml .Log}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .Log}}</string>
+</dict>
+</plist>
+`))
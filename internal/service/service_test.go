@@ -0,0 +1,245 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeManager records service manager commands instead of running them.
+func fakeManager(t *testing.T, goos string) (*Manager, *[]string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	var calls []string
+	m := &Manager{
+		GOOS: goos,
+		Home: t.TempDir(),
+		UID:  501,
+		Run: func(name string, args ...string) ([]byte, error) {
+			calls = append(calls, name+" "+strings.Join(args, " "))
+			return nil, nil
+		},
+	}
+	return m, &calls
+}
+
+func testSpec() Spec {
+	return Spec{Exe: "/opt/jevons/bin/jevons", DataRoot: "/home/me/usage data", Interval: 30, Port: 9000}
+}
+
+func TestSystemdFiles(t *testing.T) {
+	m, _ := fakeManager(t, "linux")
+	dir := filepath.Join(m.Home, ".config", "systemd", "user")
+
+	spec := testSpec()
+	spec.Web, spec.Socket = true, true
+	files, err := m.Files(spec)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, filepath.Join(dir, SystemdSyncUnit), files[0].Path)
+	assert.Contains(t, files[0].Content, `ExecStart="/opt/jevons/bin/jevons" "daemon" "run" "--interval" "30"`)
+	assert.Contains(t, files[0].Content, `Environment="CLAUDE_USAGE_DATA_DIR=/home/me/usage data"`)
+	assert.NotContains(t, files[0].Content, "CLAUDE_USAGE_SOURCE_DIR")
+
+	assert.Equal(t, filepath.Join(dir, SystemdWebUnit), files[1].Path)
+	assert.Contains(t, files[1].Content, `"web" "--no-sync" "--port" "9000"`)
+	assert.Contains(t, files[1].Content, "Requires="+SystemdWebSocket)
+
+	assert.Equal(t, filepath.Join(dir, SystemdWebSocket), files[2].Path)
+	assert.Contains(t, files[2].Content, "ListenStream=127.0.0.1:9000")
+
+	// systemd specifiers and variables in paths are escaped.
+	spec = testSpec()
+	spec.DataRoot = "/data/100%/$HOME"
+	files, err = m.Files(spec)
+	require.NoError(t, err)
+	assert.Contains(t, files[0].Content, `Environment="CLAUDE_USAGE_DATA_DIR=/data/100%%/$$HOME"`)
+}
+
+func TestLaunchdFiles(t *testing.T) {
+	m, _ := fakeManager(t, "darwin")
+
+	spec := testSpec()
+	spec.SourceDir = "/src & logs"
+	spec.Web = true
+	files, err := m.Files(spec)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	sync := files[0]
+	assert.Equal(t, filepath.Join(m.Home, "Library", "LaunchAgents", LaunchdSyncLabel+".plist"), sync.Path)
+	assert.Contains(t, sync.Content, "<string>"+LaunchdSyncLabel+"</string>")
+	assert.Contains(t, sync.Content, "<string>daemon</string>\n\t\t<string>run</string>\n\t\t<string>--interval</string>\n\t\t<string>30</string>")
+	assert.Contains(t, sync.Content, "<key>CLAUDE_USAGE_SOURCE_DIR</key>\n\t\t<string>/src &amp; logs</string>")
+	assert.Contains(t, sync.Content, "<string>/home/me/usage data/logs/sync.log</string>")
+	assert.Contains(t, files[1].Content, "<string>--no-sync</string>")
+
+	spec.Socket = true
+	_, err = m.Files(spec)
+	assert.ErrorContains(t, err, "only supported with systemd")
+}
+
+func TestInstallAndUninstall(t *testing.T) {
+	tests := []struct {
+		goos          string
+		spec          func(s *Spec)
+		wantInstall   []string
+		wantUninstall []string
+	}{
+		{
+			goos: "linux",
+			spec: func(s *Spec) { s.Web = true },
+			wantInstall: []string{
+				"systemctl --user daemon-reload",
+				"systemctl --user enable jevons-sync.service jevons-web.service",
+				"systemctl --user restart jevons-sync.service jevons-web.service",
+			},
+			wantUninstall: []string{
+				"systemctl --user disable --now jevons-sync.service jevons-web.service",
+				"systemctl --user daemon-reload",
+			},
+		},
+		{
+			goos: "darwin",
+			spec: func(s *Spec) {},
+			wantInstall: []string{
+				"launchctl bootout gui/501 {dir}/com.giannimassi.jevons.sync.plist",
+				"launchctl bootstrap gui/501 {dir}/com.giannimassi.jevons.sync.plist",
+			},
+			wantUninstall: []string{
+				"launchctl bootout gui/501 {dir}/com.giannimassi.jevons.sync.plist",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			m, calls := fakeManager(t, tt.goos)
+			dir, err := m.Dir()
+			require.NoError(t, err)
+			expand := func(lines []string) []string {
+				out := make([]string, len(lines))
+				for i, l := range lines {
+					out[i] = strings.ReplaceAll(l, "{dir}", dir)
+				}
+				return out
+			}
+
+			spec := testSpec()
+			tt.spec(&spec)
+			files, err := m.Install(spec)
+			require.NoError(t, err)
+			assert.Equal(t, expand(tt.wantInstall), *calls)
+			for _, f := range files {
+				data, err := os.ReadFile(f.Path)
+				require.NoError(t, err)
+				assert.Equal(t, f.Content, string(data))
+			}
+
+			installed, err := m.Installed()
+			require.NoError(t, err)
+			assert.Len(t, installed, len(files))
+
+			*calls = nil
+			removed, err := m.Uninstall()
+			require.NoError(t, err)
+			assert.Equal(t, installed, removed)
+			assert.Equal(t, expand(tt.wantUninstall), *calls)
+			for _, path := range removed {
+				assert.NoFileExists(t, path)
+			}
+		})
+	}
+}
+
+func TestUnsupportedPlatform(t *testing.T) {
+	m, _ := fakeManager(t, "plan9")
+	_, err := m.Install(testSpec())
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestWaitHealthy(t *testing.T) {
+	dataRoot := t.TempDir()
+	d := &daemon.Daemon{DataRoot: dataRoot, Interval: 15}
+	mainPID := os.Getpid()
+	m, _ := fakeManager(t, "linux")
+	m.Run = func(name string, args ...string) ([]byte, error) {
+		return []byte(fmt.Sprintf("%d\n", mainPID)), nil
+	}
+
+	_, err := m.WaitHealthy(dataRoot, time.Now(), 300*time.Millisecond)
+	assert.ErrorContains(t, err, "no healthy heartbeat")
+
+	started := time.Now()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		d.WriteHeartbeat("working")
+		time.Sleep(100 * time.Millisecond)
+		d.WriteHeartbeat("ok")
+	}()
+	hb, err := m.WaitHealthy(dataRoot, started, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", hb.Status)
+	assert.Equal(t, fmt.Sprint(os.Getpid()), hb.PID)
+
+	require.NoError(t, d.WriteHeartbeat("error"))
+	_, err = m.WaitHealthy(dataRoot, started, 5*time.Second)
+	assert.ErrorContains(t, err, "last sync failed")
+
+	// A healthy heartbeat from a process other than the service doesn't count.
+	require.NoError(t, d.WriteHeartbeat("ok"))
+	mainPID = 1 << 22
+	_, err = m.WaitHealthy(dataRoot, started, 300*time.Millisecond)
+	assert.ErrorContains(t, err, fmt.Sprintf("pid %d is syncing this data root instead", os.Getpid()))
+}
+
+func TestMainPID(t *testing.T) {
+	tests := []struct {
+		goos string
+		out  string
+		err  error
+		want int
+	}{
+		{"linux", "4242\n", nil, 4242},
+		{"linux", "0\n", nil, 0},
+		{"linux", "", fmt.Errorf("exit status 1"), 0},
+		{"darwin", "gui/501/com.giannimassi.jevons.sync = {\n\tstate = running\n\tpid = 777\n}\n", nil, 777},
+		{"darwin", "gui/501/com.giannimassi.jevons.sync = {\n\tstate = not running\n}\n", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos+" "+strings.TrimSpace(tt.out), func(t *testing.T) {
+			m, _ := fakeManager(t, tt.goos)
+			m.Run = func(name string, args ...string) ([]byte, error) { return []byte(tt.out), tt.err }
+			assert.Equal(t, tt.want, m.MainPID())
+		})
+	}
+}
+
+func TestInstallRefusesWhileAnotherLoopRuns(t *testing.T) {
+	m, calls := fakeManager(t, "linux")
+	spec := testSpec()
+	spec.DataRoot = t.TempDir()
+
+	// This test process runs the sync loop for the data root.
+	d := &daemon.Daemon{DataRoot: spec.DataRoot}
+	require.NoError(t, d.Acquire())
+
+	_, err := m.Install(spec)
+	require.ErrorIs(t, err, daemon.ErrRunning)
+	assert.ErrorContains(t, err, "before installing the service")
+	assert.Equal(t, []string{"systemctl --user show -p MainPID --value jevons-sync.service"}, *calls)
+
+	// Reinstalling over the service's own loop is fine.
+	m.Run = func(name string, args ...string) ([]byte, error) {
+		return []byte(fmt.Sprint(os.Getpid())), nil
+	}
+	_, err = m.Install(spec)
+	require.NoError(t, err)
+}
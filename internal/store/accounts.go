@@ -0,0 +1,38 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// AccountsFile is the account snapshot history in DataRoot. account.json
+// only describes the current account; this file remembers every account
+// sync has seen so past usage stays attributed to the right one.
+const AccountsFile = "accounts.json"
+
+// ReadAccounts reads the account snapshot history.
+func ReadAccounts(path string, v *vault.Vault) (model.AccountHistory, error) {
+	data, err := ReadFile(path, v)
+	if err != nil {
+		return nil, err
+	}
+	var h model.AccountHistory
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// WriteAccounts atomically writes the account snapshot history ("[]" when empty).
+func WriteAccounts(path string, h model.AccountHistory, v *vault.Vault) error {
+	if len(h) == 0 {
+		return WriteFile(path, []byte("[]\n"), v)
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, append(data, '\n'), v)
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// ExtColumns lists the extension columns of events-ext.tsv, in write order.
+// Line N of events-ext.tsv describes line N of events.tsv (header included),
+// which keeps events.tsv itself byte-compatible with the shell script. The
+// row_key column names the row a line was written for, so a sidecar out of
+// step with events.tsv (read mid-rewrite, or left behind when something
+// else rewrote events.tsv) is never applied to the wrong rows.
+var ExtColumns = []string{"row_key", "model", "event_id", "seq", "account", "org"}
+
+// ExtPath returns the extension sidecar for an events file: events.tsv → events-ext.tsv.
+func ExtPath(eventsPath string) string {
+	return strings.TrimSuffix(eventsPath, ".tsv") + "-ext.tsv"
+}
+
+// RowKey identifies the events.tsv row of e: its epoch and signature.
+func RowKey(e model.TokenEvent) string {
+	return strconv.FormatInt(e.TSEpoch, 10) + ":" + e.Signature
+}
+
+// MarshalEventExt serializes the extension columns of e ("-" for empty values).
+func MarshalEventExt(e model.TokenEvent) string {
+	return RowKey(e) + "\t" + orDash(e.Model) + "\t" + orDash(e.ID) + "\t" + strconv.FormatInt(e.Seq, 10) + "\t" + orDash(e.Account) + "\t" + orDash(e.Org)
+}
+
+// ExtDecoder applies events-ext.tsv lines to events using the file's own
+// header, so sidecars written by older versions with fewer columns still load.
+type ExtDecoder struct {
+	cols []string
+	key  int // Index of row_key; -1 in sidecars written before it
+}
+
+// NewExtDecoder creates a decoder for an events-ext.tsv header line.
+func NewExtDecoder(header string) ExtDecoder {
+	d := ExtDecoder{cols: strings.Split(strings.TrimRight(header, "\r\n"), "\t"), key: -1}
+	for i, col := range d.cols {
+		if col == "row_key" {
+			d.key = i
+		}
+	}
+	return d
+}
+
+// Keyed reports whether the sidecar names the row of each line, so Apply
+// can tell lines that belong to other rows.
+func (d ExtDecoder) Keyed() bool {
+	return d.key >= 0
+}
+
+// Apply sets the extension fields of e from an events-ext.tsv line. It
+// leaves e alone and returns false when the line was written for another
+// row.
+func (d ExtDecoder) Apply(line string, e *model.TokenEvent) bool {
+	fields := strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+	if d.key >= 0 && (d.key >= len(fields) || fields[d.key] != RowKey(*e)) {
+		return false
+	}
+	for i, col := range d.cols {
+		if i >= len(fields) {
+			return true
+		}
+		val := fields[i]
+		if val == "-" {
+			val = ""
+		}
+		switch col {
+		case "model":
+			e.Model = val
+		case "event_id":
+			e.ID = val
+		case "seq":
+			e.Seq, _ = strconv.ParseInt(val, 10, 64)
+		case "account":
+			e.Account = val
+		case "org":
+			e.Org = val
+		}
+	}
+	return true
+}
+
+// ReadEvents reads events.tsv and its extension sidecar (if present),
+// skipping malformed rows.
+func ReadEvents(path string, v *vault.Vault) ([]model.TokenEvent, error) {
+	data, err := ReadFile(path, v)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := ReadFile(ExtPath(path), v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return ParseEvents(data, ext), nil
+}
+
+// ParseEvents parses events.tsv content, attaching row-aligned extension
+// columns from ext (which may be nil). Malformed rows are skipped, and so
+// are ext lines written for other rows; an older sidecar without row keys
+// is only used when its line count matches.
+func ParseEvents(data, ext []byte) []model.TokenEvent {
+	var extLines []string
+	var dec ExtDecoder
+	if len(ext) > 0 {
+		extLines = strings.Split(string(ext), "\n")
+		dec = NewExtDecoder(extLines[0])
+		if !dec.Keyed() && bytes.Count(ext, []byte("\n")) != bytes.Count(data, []byte("\n")) {
+			extLines = nil
+		}
+	}
+
+	var events []model.TokenEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for i := 0; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i == 0 || line == "" {
+			continue // header or blank
+		}
+		e, err := UnmarshalTokenEvent(line)
+		if err != nil {
+			continue
+		}
+		if i < len(extLines) {
+			dec.Apply(extLines[i], &e)
+		}
+		FillID(&e)
+		events = append(events, e)
+	}
+	return events
+}
+
+// FillID derives the ID of an event read without one (a row written by the
+// shell script or by a version before event IDs).
+func FillID(e *model.TokenEvent) {
+	if e.ID == "" {
+		e.ID = model.EventID(model.ProviderClaude, e.SessionID, model.MessageKey("", e.TSISO, e.Signature))
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/giannimassi/jevons/internal/vault"
+)
+
+// Permissions for everything jevons writes under DataRoot.
+const (
+	DirMode  os.FileMode = 0700
+	FileMode os.FileMode = 0600
+)
+
+// ReadFile reads a data-root file, decrypting it if it was written sealed.
+func ReadFile(path string, v *vault.Vault) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return v.Open(data)
+}
+
+// WriteFile seals data with v (if non-nil) and writes it to a uniquely
+// named temp file that is fsynced and then renamed over path atomically, so
+// concurrent writers never share a temp file and a crash never leaves a
+// truncated store behind.
+func WriteFile(path string, data []byte, v *vault.Vault) error {
+	sealed, err := v.Seal(data)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(sealed); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Chmod(FileMode); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Persist the rename itself; best effort, not every filesystem supports it.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileReadFile(t *testing.T) {
+	key := bytes.Repeat([]byte{9}, 32)
+	v, err := vault.New(key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		vault      *vault.Vault
+		wantSealed bool
+	}{
+		{name: "plaintext", vault: nil, wantSealed: false},
+		{name: "encrypted", vault: v, wantSealed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.tsv")
+			require.NoError(t, WriteFile(path, []byte(EventsTSVHeader+"\n"), tt.vault))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, FileMode, info.Mode().Perm())
+
+			leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+			require.NoError(t, err)
+			assert.Empty(t, leftovers, "temp file should be renamed away")
+
+			raw, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSealed, vault.IsSealed(raw))
+
+			got, err := ReadFile(path, tt.vault)
+			require.NoError(t, err)
+			assert.Equal(t, EventsTSVHeader+"\n", string(got))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/giannimassi/jevons/internal/vault"
+)
+
+// IndexStride is the number of rows between sparse index entries.
+const IndexStride = 1024
+
+// IndexVersion is bumped when the index layout changes.
+const IndexVersion = 1
+
+// Index is a sparse offset index over a sorted events.tsv, written at sync
+// time so readers can seek straight to the start of a time range.
+type Index struct {
+	Version    int          `json:"version"`
+	EventsSize int64        `json:"events_size"` // Plaintext size of events.tsv when indexed
+	ExtSize    int64        `json:"ext_size"`    // Plaintext size of events-ext.tsv when indexed
+	Stride     int          `json:"stride"`
+	Entries    []IndexEntry `json:"entries"`
+}
+
+// IndexEntry points at the start of a data row in events.tsv and the
+// matching line in events-ext.tsv.
+type IndexEntry struct {
+	Epoch     int64 `json:"epoch"`
+	Offset    int64 `json:"offset"`
+	ExtOffset int64 `json:"ext_offset"`
+}
+
+// IndexPath returns the index file for an events file: events.tsv → events.idx.
+func IndexPath(eventsPath string) string {
+	return strings.TrimSuffix(eventsPath, ".tsv") + ".idx"
+}
+
+// ReadIndex loads an index written by WriteEventsTSV.
+func ReadIndex(path string, v *vault.Vault) (*Index, error) {
+	data, err := ReadFile(path, v)
+	if err != nil {
+		return nil, err
+	}
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil {
+		return nil, err
+	}
+	return &ix, nil
+}
+
+// Fresh reports whether the index still describes files of the given sizes.
+func (ix *Index) Fresh(eventsSize, extSize int64) bool {
+	return ix.Version == IndexVersion && ix.EventsSize == eventsSize && ix.ExtSize == extSize
+}
+
+// Lookup returns the last entry whose epoch is strictly below since, which is
+// a safe place to start scanning for rows with epoch >= since. It reports
+// false when scanning must start at the first row.
+func (ix *Index) Lookup(since int64) (IndexEntry, bool) {
+	i := sort.Search(len(ix.Entries), func(i int) bool {
+		return ix.Entries[i].Epoch >= since
+	})
+	if i == 0 {
+		return IndexEntry{}, false
+	}
+	return ix.Entries[i-1], true
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEventsTSVSidecars(t *testing.T) {
+	events := make([]model.TokenEvent, IndexStride*2+5)
+	for i := range events {
+		events[i] = model.TokenEvent{TSEpoch: int64(1000 + i), TSISO: "-", ProjectSlug: "p", SessionID: "s", ContentType: "text", Signature: "sig"}
+	}
+	events[1].Model = "claude-opus-4-1"
+
+	path := filepath.Join(t.TempDir(), "events.tsv")
+	require.NoError(t, WriteEventsTSV(path, events, nil))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	ext, err := os.ReadFile(ExtPath(path))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Count(string(data), "\n"), strings.Count(string(ext), "\n"), "sidecar is row-aligned")
+
+	got, err := ReadEvents(path, nil)
+	require.NoError(t, err)
+	require.Len(t, got, len(events))
+	assert.Equal(t, "", got[0].Model)
+	assert.Equal(t, "claude-opus-4-1", got[1].Model)
+
+	ix, err := ReadIndex(IndexPath(path), nil)
+	require.NoError(t, err)
+	require.Len(t, ix.Entries, 3)
+	assert.True(t, ix.Fresh(int64(len(data)), int64(len(ext))))
+	assert.False(t, ix.Fresh(int64(len(data))+1, int64(len(ext))))
+
+	for _, entry := range ix.Entries {
+		line := string(data[entry.Offset:])
+		line = line[:strings.IndexByte(line, '\n')]
+		e, err := UnmarshalTokenEvent(line)
+		require.NoError(t, err)
+		assert.Equal(t, entry.Epoch, e.TSEpoch, "offset points at the indexed row")
+	}
+
+	tests := []struct {
+		name   string
+		since  int64
+		wantOK bool
+		want   int64
+	}{
+		{name: "before first row", since: 500, wantOK: false},
+		{name: "at first row", since: 1000, wantOK: false},
+		{name: "inside first stride", since: 1500, wantOK: true, want: 1000},
+		{name: "exactly at second entry", since: 1000 + IndexStride, wantOK: true, want: 1000},
+		{name: "past the end", since: 99999, wantOK: true, want: 1000 + 2*IndexStride},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := ix.Lookup(tt.since)
+			assert.Equal(t, tt.wantOK, ok)
+			if ok {
+				assert.Equal(t, tt.want, entry.Epoch)
+			}
+		})
+	}
+}
+
+func TestParseEventsOlderExtHeader(t *testing.T) {
+	data := EventsTSVHeader + "\n" + "1000\t-\tp\ts\t1\t1\t0\t0\t2\t2\ttext\tsig\n"
+
+	tests := []struct {
+		name      string
+		ext       string
+		wantModel string
+	}{
+		{name: "no sidecar", ext: "", wantModel: ""},
+		{name: "dash means empty", ext: "model\n-\n", wantModel: ""},
+		{name: "unknown columns ignored", ext: "future\tmodel\nx\tclaude-haiku\n", wantModel: "claude-haiku"},
+		{name: "line count differs without row keys", ext: "model\nclaude-haiku\nclaude-opus\n", wantModel: ""},
+		{name: "row key matches", ext: "row_key\tmodel\n1000:sig\tclaude-haiku\n", wantModel: "claude-haiku"},
+		{name: "row key of another row", ext: "row_key\tmodel\n999:sig\tclaude-haiku\n", wantModel: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseEvents([]byte(data), []byte(tt.ext))
+			require.Len(t, got, 1)
+			assert.Equal(t, tt.wantModel, got[0].Model)
+		})
+	}
+}
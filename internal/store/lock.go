@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockFile is the advisory lock taken by every process that rewrites the
+// stores under DataRoot (sync, verify --repair, restore).
+const LockFile = "sync.lock"
+
+// ErrLocked is returned by Lock when another process holds the lock.
+var ErrLocked = errors.New("sync already in progress")
+
+// LockedError reports which process holds the DataRoot lock.
+type LockedError struct {
+	PID int // 0 if unknown
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("%v (pid %d)", ErrLocked, e.PID)
+	}
+	return ErrLocked.Error()
+}
+
+// Unwrap lets errors.Is(err, ErrLocked) match.
+func (e *LockedError) Unwrap() error { return ErrLocked }
+
+// DataRootLock is a held DataRoot lock.
+type DataRootLock struct {
+	f *os.File
+}
+
+// Lock takes the DataRoot write lock. With wait it blocks until the lock is
+// free; otherwise it fails fast with a *LockedError. The lock is released by
+// Unlock or when the process exits.
+func Lock(dataRoot string, wait bool) (*DataRootLock, error) {
+	if err := os.MkdirAll(dataRoot, DirMode); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dataRoot, LockFile)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, FileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, wait); err != nil {
+		f.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, &LockedError{PID: lockHolder(path)}
+		}
+		return nil, fmt.Errorf("lock %s: %w", LockFile, err)
+	}
+
+	// Record the holder for the "already in progress" message.
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return &DataRootLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *DataRootLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := funlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+func lockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// Advisory locking is only implemented on Unix; elsewhere writers are not serialized.
+func flock(f *os.File, wait bool) error { return nil }
+
+func funlock(f *os.File) error { return nil }
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+
+	held, err := Lock(dir, false)
+	require.NoError(t, err)
+
+	_, err = Lock(dir, false)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrLocked))
+	var locked *LockedError
+	require.True(t, errors.As(err, &locked))
+	assert.Equal(t, os.Getpid(), locked.PID)
+	assert.Contains(t, err.Error(), "sync already in progress")
+
+	acquired := make(chan *DataRootLock)
+	go func() {
+		l, err := Lock(dir, true)
+		assert.NoError(t, err)
+		acquired <- l
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("waiting lock acquired while held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, held.Unlock())
+	select {
+	case l := <-acquired:
+		require.NoError(t, l.Unlock())
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiting lock not acquired after unlock")
+	}
+
+	again, err := Lock(dir, false)
+	require.NoError(t, err)
+	require.NoError(t, again.Unlock())
+}
+
+func TestWriteFileConcurrentWriters(t *testing.T) {
+	path := t.TempDir() + "/events.tsv"
+
+	// Writers racing on the same path must each produce a complete file.
+	payloads := []string{"a\n", "bb\n", "ccc\n", "dddd\n"}
+	done := make(chan error, len(payloads)*10)
+	for i := 0; i < 10; i++ {
+		for _, p := range payloads {
+			go func(p string) { done <- WriteFile(path, []byte(p), nil) }(p)
+		}
+	}
+	for i := 0; i < cap(done); i++ {
+		require.NoError(t, <-done)
+	}
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, payloads, string(got))
+}
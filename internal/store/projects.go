@@ -0,0 +1,33 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// ReadProjects reads the projects.json manifest.
+func ReadProjects(path string, v *vault.Vault) ([]model.Project, error) {
+	data, err := ReadFile(path, v)
+	if err != nil {
+		return nil, err
+	}
+	var projects []model.Project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// WriteProjects atomically writes the projects.json manifest ("[]" when empty).
+func WriteProjects(path string, projects []model.Project, v *vault.Vault) error {
+	if len(projects) == 0 {
+		return WriteFile(path, []byte("[]\n"), v)
+	}
+	data, err := json.MarshalIndent(projects, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, append(data, '\n'), v)
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// SyncStatusFile records the last sync, including the ingest high-water mark.
+const SyncStatusFile = "sync-status.json"
+
+// Seqs maps event IDs to their ingest sequence numbers.
+type Seqs struct {
+	ByID map[string]int64
+	Last int64 // Highest sequence number ever handed out
+}
+
+// LoadSeqs collects the sequence numbers already assigned in a data root:
+// those stored with events.tsv, and the high-water mark in sync-status.json,
+// which keeps numbers from being reused after events disappear.
+func LoadSeqs(dataRoot string, events []model.TokenEvent) Seqs {
+	s := Seqs{ByID: make(map[string]int64, len(events)), Last: ReadLastSeq(dataRoot)}
+	for _, e := range events {
+		if e.Seq <= 0 {
+			continue
+		}
+		s.ByID[e.ID] = e.Seq
+		if e.Seq > s.Last {
+			s.Last = e.Seq
+		}
+	}
+	return s
+}
+
+// Assign gives events their previous sequence number (matched by ID) and
+// numbers new events after s.Last in slice order, advancing s.Last.
+func (s *Seqs) Assign(events []model.TokenEvent) {
+	for i := range events {
+		if seq, ok := s.ByID[events[i].ID]; ok {
+			events[i].Seq = seq
+			continue
+		}
+		s.Last++
+		events[i].Seq = s.Last
+		s.ByID[events[i].ID] = s.Last
+	}
+}
+
+// ReadLastSeq returns the last_seq recorded in sync-status.json, or 0.
+func ReadLastSeq(dataRoot string) int64 {
+	data, err := ReadFile(filepath.Join(dataRoot, SyncStatusFile), nil)
+	if err != nil {
+		return 0
+	}
+	var status struct {
+		LastSeq int64 `json:"last_seq"`
+	}
+	if json.Unmarshal(data, &status) != nil {
+		return 0
+	}
+	return status.LastSeq
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeqsAssign(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		previous []model.TokenEvent
+		events   []string
+		wantSeqs []int64
+		wantLast int64
+	}{
+		{
+			name:     "fresh store numbers from one",
+			events:   []string{"a", "b"},
+			wantSeqs: []int64{1, 2},
+			wantLast: 2,
+		},
+		{
+			name:     "known events keep their seq",
+			previous: []model.TokenEvent{{ID: "a", Seq: 1}, {ID: "b", Seq: 2}},
+			events:   []string{"new", "b", "a"},
+			wantSeqs: []int64{3, 2, 1},
+			wantLast: 3,
+		},
+		{
+			name:     "high-water mark survives removed events",
+			status:   `{"last_seq": 10}`,
+			previous: []model.TokenEvent{{ID: "a", Seq: 1}},
+			events:   []string{"a", "c"},
+			wantSeqs: []int64{1, 11},
+			wantLast: 11,
+		},
+		{
+			name:     "unnumbered previous rows are ignored",
+			previous: []model.TokenEvent{{ID: "a"}},
+			events:   []string{"a"},
+			wantSeqs: []int64{1},
+			wantLast: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.status != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, SyncStatusFile), []byte(tt.status), 0600))
+			}
+
+			events := make([]model.TokenEvent, len(tt.events))
+			for i, id := range tt.events {
+				events[i] = model.TokenEvent{ID: id}
+			}
+
+			seqs := LoadSeqs(dir, tt.previous)
+			seqs.Assign(events)
+
+			got := make([]int64, len(events))
+			for i, e := range events {
+				got[i] = e.Seq
+			}
+			assert.Equal(t, tt.wantSeqs, got)
+			assert.Equal(t, tt.wantLast, seqs.Last)
+		})
+	}
+}
+
+func TestFillID(t *testing.T) {
+	e := model.TokenEvent{SessionID: "s1", TSISO: "2025-01-15T10:30:00Z", Signature: "1|2|0|0"}
+	FillID(&e)
+	assert.Equal(t, model.EventID(model.ProviderClaude, "s1", "2025-01-15T10:30:00Z|1|2|0|0"), e.ID)
+
+	e.ID = "kept"
+	FillID(&e)
+	assert.Equal(t, "kept", e.ID)
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// LessEvent orders events by epoch, then ISO timestamp, project, session and signature.
+// This is the canonical order of rows in events.tsv and live-events.tsv.
+func LessEvent(a, b model.TokenEvent) bool {
+	if a.TSEpoch != b.TSEpoch {
+		return a.TSEpoch < b.TSEpoch
+	}
+	if a.TSISO != b.TSISO {
+		return a.TSISO < b.TSISO
+	}
+	if a.ProjectSlug != b.ProjectSlug {
+		return a.ProjectSlug < b.ProjectSlug
+	}
+	if a.SessionID != b.SessionID {
+		return a.SessionID < b.SessionID
+	}
+	return a.Signature < b.Signature
+}
+
+// SortEvents sorts events in canonical order (stable for deterministic output with equal keys).
+func SortEvents(events []model.TokenEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return LessEvent(events[i], events[j])
+	})
+}
+
+// SortLiveEvents sorts live events in canonical order.
+func SortLiveEvents(events []model.LiveEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return LessEvent(events[i].TokenEvent, events[j].TokenEvent)
+	})
+}
+
+// DedupEvents drops rows whose serialized form was already seen, keeping the first.
+func DedupEvents(events []model.TokenEvent) []model.TokenEvent {
+	if len(events) == 0 {
+		return events
+	}
+	seen := make(map[string]bool)
+	result := make([]model.TokenEvent, 0, len(events))
+	for _, e := range events {
+		line := MarshalTokenEvent(e)
+		if !seen[line] {
+			seen[line] = true
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// DedupLiveEvents drops rows whose serialized form was already seen, keeping the first.
+func DedupLiveEvents(events []model.LiveEvent) []model.LiveEvent {
+	if len(events) == 0 {
+		return events
+	}
+	seen := make(map[string]bool)
+	result := make([]model.LiveEvent, 0, len(events))
+	for _, e := range events {
+		line := MarshalLiveEvent(e)
+		if !seen[line] {
+			seen[line] = true
+			result = append(result, e)
+		}
+	}
+	return result
+}
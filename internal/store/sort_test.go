@@ -0,0 +1,25 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortAndDedupEvents(t *testing.T) {
+	a := model.TokenEvent{TSEpoch: 1, TSISO: "a", ProjectSlug: "p", SessionID: "s1", Signature: "x"}
+	b := model.TokenEvent{TSEpoch: 1, TSISO: "a", ProjectSlug: "p", SessionID: "s2", Signature: "x"}
+	c := model.TokenEvent{TSEpoch: 2, TSISO: "b", ProjectSlug: "p", SessionID: "s1", Signature: "x"}
+
+	events := []model.TokenEvent{c, b, a, c}
+	SortEvents(events)
+	assert.Equal(t, []model.TokenEvent{a, b, c, c}, events)
+	assert.Equal(t, []model.TokenEvent{a, b, c}, DedupEvents(events))
+
+	live := []model.LiveEvent{{TokenEvent: c, PromptPreview: "p"}, {TokenEvent: a, PromptPreview: "p"}, {TokenEvent: a, PromptPreview: "p"}}
+	SortLiveEvents(live)
+	live = DedupLiveEvents(live)
+	assert.Len(t, live, 2)
+	assert.Equal(t, a, live[0].TokenEvent)
+}
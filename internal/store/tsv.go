@@ -1,10 +1,12 @@
 package store
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/giannimassi/jevons/internal/vault"
 	"github.com/giannimassi/jevons/pkg/model"
 )
 
@@ -89,3 +91,67 @@ func MarshalLiveEvent(e model.LiveEvent) string {
 		e.Billable, e.TotalWithCache, e.ContentType, e.Signature,
 	)
 }
+
+// UnmarshalLiveEvent parses a live-events.tsv line into a LiveEvent.
+func UnmarshalLiveEvent(line string) (model.LiveEvent, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 13 {
+		return model.LiveEvent{}, fmt.Errorf("expected 13 fields, got %d", len(fields))
+	}
+	preview := fields[4]
+	rest := append(append([]string{}, fields[:4]...), fields[5:]...)
+	e, err := UnmarshalTokenEvent(strings.Join(rest, "\t"))
+	if err != nil {
+		return model.LiveEvent{}, err
+	}
+	return model.LiveEvent{TokenEvent: e, PromptPreview: preview}, nil
+}
+
+// WriteEventsTSV atomically writes events.tsv (header + one row per event)
+// together with its events-ext.tsv sidecar and sparse events.idx index.
+func WriteEventsTSV(path string, events []model.TokenEvent, v *vault.Vault) error {
+	var b, ext strings.Builder
+	b.WriteString(EventsTSVHeader)
+	b.WriteByte('\n')
+	ext.WriteString(strings.Join(ExtColumns, "\t"))
+	ext.WriteByte('\n')
+
+	ix := Index{Version: IndexVersion, Stride: IndexStride}
+	for i, e := range events {
+		if i%IndexStride == 0 {
+			ix.Entries = append(ix.Entries, IndexEntry{Epoch: e.TSEpoch, Offset: int64(b.Len()), ExtOffset: int64(ext.Len())})
+		}
+		b.WriteString(MarshalTokenEvent(e))
+		b.WriteByte('\n')
+		ext.WriteString(MarshalEventExt(e))
+		ext.WriteByte('\n')
+	}
+	ix.EventsSize = int64(b.Len())
+	ix.ExtSize = int64(ext.Len())
+	ixData, err := json.Marshal(ix)
+	if err != nil {
+		return err
+	}
+
+	// The index goes last and records both sizes, so a reader racing this
+	// rewrite falls back to a full scan instead of seeking to stale offsets.
+	if err := WriteFile(ExtPath(path), []byte(ext.String()), v); err != nil {
+		return err
+	}
+	if err := WriteFile(path, []byte(b.String()), v); err != nil {
+		return err
+	}
+	return WriteFile(IndexPath(path), append(ixData, '\n'), v)
+}
+
+// WriteLiveEventsTSV atomically writes live-events.tsv (header + one row per event).
+func WriteLiveEventsTSV(path string, events []model.LiveEvent, v *vault.Vault) error {
+	var b strings.Builder
+	b.WriteString(LiveEventsTSVHeader)
+	b.WriteByte('\n')
+	for _, e := range events {
+		b.WriteString(MarshalLiveEvent(e))
+		b.WriteByte('\n')
+	}
+	return WriteFile(path, []byte(b.String()), v)
+}
@@ -175,3 +175,30 @@ func TestBillableCalculation(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalLiveEvent(t *testing.T) {
+	e := model.LiveEvent{
+		TokenEvent: model.TokenEvent{
+			TSEpoch:        1736937000,
+			TSISO:          "2025-01-15T10:30:00Z",
+			ProjectSlug:    "test",
+			SessionID:      "s1",
+			Input:          100,
+			Output:         50,
+			CacheRead:      20,
+			CacheCreate:    10,
+			Billable:       150,
+			TotalWithCache: 180,
+			ContentType:    "text",
+			Signature:      "100|50|20|10",
+		},
+		PromptPreview: "Hello world",
+	}
+
+	got, err := UnmarshalLiveEvent(MarshalLiveEvent(e))
+	require.NoError(t, err)
+	assert.Equal(t, e, got)
+
+	_, err = UnmarshalLiveEvent(MarshalTokenEvent(e.TokenEvent))
+	assert.Error(t, err, "a 12-column events.tsv row is not a live row")
+}
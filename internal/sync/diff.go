@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/giannimassi/jevons/internal/projectpath"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Diff operations reported in DiffRow.Op.
+const (
+	OpAdded   = "added"
+	OpRemoved = "removed"
+	OpChanged = "changed"
+)
+
+// Diff compares the event set a sync would write with events.tsv on disk.
+type Diff struct {
+	Added     int         `json:"added"`
+	Removed   int         `json:"removed"`
+	Changed   int         `json:"changed"`
+	Unchanged int         `json:"unchanged"`
+	Tokens    TokenDelta  `json:"tokens"`
+	Groups    []DiffGroup `json:"groups"`
+	Rows      []DiffRow   `json:"rows,omitempty"`
+}
+
+// Empty reports whether a sync would leave events.tsv unchanged.
+func (d *Diff) Empty() bool {
+	return d.Added == 0 && d.Removed == 0 && d.Changed == 0
+}
+
+// DiffGroup summarizes the differences for one project session.
+type DiffGroup struct {
+	Project string     `json:"project"`
+	Session string     `json:"session"`
+	Added   int        `json:"added"`
+	Removed int        `json:"removed"`
+	Changed int        `json:"changed"`
+	Tokens  TokenDelta `json:"tokens"`
+}
+
+// TokenDelta is new minus current token counts.
+type TokenDelta struct {
+	Input          int64 `json:"input"`
+	Output         int64 `json:"output"`
+	CacheRead      int64 `json:"cache_read"`
+	CacheCreate    int64 `json:"cache_create"`
+	Billable       int64 `json:"billable"`
+	TotalWithCache int64 `json:"total_with_cache"`
+}
+
+func (t *TokenDelta) add(e model.TokenEvent, sign int64) {
+	t.Input += sign * e.Input
+	t.Output += sign * e.Output
+	t.CacheRead += sign * e.CacheRead
+	t.CacheCreate += sign * e.CacheCreate
+	t.Billable += sign * e.Billable
+	t.TotalWithCache += sign * e.TotalWithCache
+}
+
+// DiffRow is one differing event. Old is nil for added rows and New is nil
+// for removed rows.
+type DiffRow struct {
+	Op  string            `json:"op"`
+	Old *model.TokenEvent `json:"old,omitempty"`
+	New *model.TokenEvent `json:"new,omitempty"`
+}
+
+// DryRun computes the event set a sync would write and diffs it against the
+// current events.tsv by event ID. It takes no lock and writes nothing, so it
+// can run against fixture directories or alongside a daemon. With rows set,
+// the differing events are returned in Diff.Rows.
+func DryRun(cfg model.Config, opts Options, rows bool) (*Diff, error) {
+	result := &Result{SourceRoot: cfg.SourceDir, Workers: workerCount(cfg.Workers)}
+
+	v, err := vault.Load(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("load encryption key: %w", err)
+	}
+
+	aliases, err := projectpath.LoadAliases(filepath.Join(cfg.DataRoot, projectpath.AliasesFile))
+	if err != nil {
+		return nil, fmt.Errorf("load project aliases: %w", err)
+	}
+
+	c, err := collect(cfg, opts.Cache, aliases, result)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := store.ReadEvents(filepath.Join(cfg.DataRoot, "events.tsv"), v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read events.tsv: %w", err)
+	}
+	projects, err := store.ReadProjects(filepath.Join(cfg.DataRoot, "projects.json"), v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read projects.json: %w", err)
+	}
+	c.keepArchived(current, projects, cfg.Projects)
+
+	return diffEvents(current, c.events, rows), nil
+}
+
+// diffEvents matches rows by event ID. Repeated IDs are paired in order.
+func diffEvents(current, next []model.TokenEvent, rows bool) *Diff {
+	d := &Diff{}
+	groups := make(map[[2]string]*DiffGroup)
+	group := func(e model.TokenEvent) *DiffGroup {
+		k := [2]string{e.ProjectSlug, e.SessionID}
+		g, ok := groups[k]
+		if !ok {
+			g = &DiffGroup{Project: e.ProjectSlug, Session: e.SessionID}
+			groups[k] = g
+		}
+		return g
+	}
+
+	byID := make(map[string][]model.TokenEvent, len(current))
+	for _, e := range current {
+		byID[e.ID] = append(byID[e.ID], e)
+	}
+	matched := make(map[string]int, len(current))
+
+	for i := range next {
+		n := next[i]
+		olds := byID[n.ID]
+		if len(olds) == 0 {
+			d.Added++
+			g := group(n)
+			g.Added++
+			g.Tokens.add(n, 1)
+			d.Tokens.add(n, 1)
+			if rows {
+				d.Rows = append(d.Rows, DiffRow{Op: OpAdded, New: &next[i]})
+			}
+			continue
+		}
+		o := olds[0]
+		byID[n.ID] = olds[1:]
+		matched[n.ID]++
+		if sameEvent(o, n) {
+			d.Unchanged++
+			continue
+		}
+		d.Changed++
+		group(n).Changed++
+		group(o).Tokens.add(o, -1)
+		group(n).Tokens.add(n, 1)
+		d.Tokens.add(o, -1)
+		d.Tokens.add(n, 1)
+		if rows {
+			old := o
+			d.Rows = append(d.Rows, DiffRow{Op: OpChanged, Old: &old, New: &next[i]})
+		}
+	}
+
+	// Current rows left unmatched are no longer produced by the sources.
+	// Walk current again so removed rows keep their on-disk order.
+	for i := range current {
+		o := current[i]
+		if matched[o.ID] > 0 {
+			matched[o.ID]--
+			continue
+		}
+		d.Removed++
+		g := group(o)
+		g.Removed++
+		g.Tokens.add(o, -1)
+		d.Tokens.add(o, -1)
+		if rows {
+			d.Rows = append(d.Rows, DiffRow{Op: OpRemoved, Old: &current[i]})
+		}
+	}
+
+	d.Groups = make([]DiffGroup, 0, len(groups))
+	for _, g := range groups {
+		if g.Added == 0 && g.Removed == 0 && g.Changed == 0 && g.Tokens == (TokenDelta{}) {
+			continue
+		}
+		d.Groups = append(d.Groups, *g)
+	}
+	sort.Slice(d.Groups, func(i, j int) bool {
+		if d.Groups[i].Project != d.Groups[j].Project {
+			return d.Groups[i].Project < d.Groups[j].Project
+		}
+		return d.Groups[i].Session < d.Groups[j].Session
+	})
+	return d
+}
+
+// sameEvent compares the stored columns of two events. Seq is ignored: it is
+// assigned at write time and is not part of the event's content.
+func sameEvent(a, b model.TokenEvent) bool {
+	return store.MarshalTokenEvent(a) == store.MarshalTokenEvent(b) && a.Model == b.Model
+}
@@ -0,0 +1,263 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/store"
+)
+
+// HistoryFile is the append-only log in DataRoot with one HistoryRecord per
+// sync run. It holds run statistics only, never event data, so it is written
+// in plaintext like sync-status.json.
+const HistoryFile = "sync-history.jsonl"
+
+// History rotation: once HistoryFile reaches HistoryMaxBytes it is renamed to
+// HistoryFile.1, shifting older generations up to HistoryKeep.
+const (
+	HistoryMaxBytes = 1 << 20
+	HistoryKeep     = 3
+)
+
+// HistoryRecord is one line of HistoryFile.
+type HistoryRecord struct {
+	Time         string `json:"time"`
+	Epoch        int64  `json:"epoch"`
+	OK           bool   `json:"ok"`
+	Error        string `json:"error,omitempty"`
+	DurationMS   int64  `json:"duration_ms"`
+	FilesScanned int    `json:"files_scanned"`
+	FilesParsed  int    `json:"files_parsed"`
+	FilesChanged int    `json:"files_changed"`
+	EventsAdded  int    `json:"events_added"`
+	EventRows    int    `json:"event_rows"`
+	LastSeq      int64  `json:"last_seq"`
+	HookFailures int    `json:"hook_failures,omitempty"`
+	PID          int    `json:"pid"`
+	Daemon       bool   `json:"daemon,omitempty"`
+}
+
+func newHistoryRecord(result *Result, err error, daemon bool) HistoryRecord {
+	r := HistoryRecord{
+		Time:         result.Started.UTC().Format(time.RFC3339),
+		Epoch:        result.Started.Unix(),
+		OK:           err == nil,
+		DurationMS:   result.Duration.Milliseconds(),
+		FilesScanned: result.SessionFiles,
+		FilesParsed:  result.FilesParsed,
+		FilesChanged: result.FilesChanged,
+		EventsAdded:  result.NewEvents,
+		EventRows:    result.EventRows,
+		LastSeq:      result.LastSeq,
+		PID:          os.Getpid(),
+		Daemon:       daemon,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	for _, h := range result.Hooks {
+		if !h.OK {
+			r.HookFailures++
+		}
+	}
+	return r
+}
+
+func historyPath(dataRoot string, generation int) string {
+	path := filepath.Join(dataRoot, HistoryFile)
+	if generation > 0 {
+		path += fmt.Sprintf(".%d", generation)
+	}
+	return path
+}
+
+// appendHistory appends r to HistoryFile, rotating it first when full.
+// Callers hold the DataRoot lock.
+func appendHistory(dataRoot string, r HistoryRecord) error {
+	path := historyPath(dataRoot, 0)
+	if info, err := os.Stat(path); err == nil && info.Size() >= HistoryMaxBytes {
+		os.Remove(historyPath(dataRoot, HistoryKeep))
+		for g := HistoryKeep - 1; g >= 0; g-- {
+			os.Rename(historyPath(dataRoot, g), historyPath(dataRoot, g+1))
+		}
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, store.FileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory returns the recorded runs, oldest first, across rotated
+// generations. Malformed lines are skipped.
+func ReadHistory(dataRoot string) ([]HistoryRecord, error) {
+	var records []HistoryRecord
+	for g := HistoryKeep; g >= 0; g-- {
+		f, err := os.Open(historyPath(dataRoot, g))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var r HistoryRecord
+			if json.Unmarshal(scanner.Bytes(), &r) == nil {
+				records = append(records, r)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// readLastSyncStart returns when the previous successful sync started, or
+// the zero time when there is none.
+func readLastSyncStart(dataRoot string) time.Time {
+	data, err := os.ReadFile(filepath.Join(dataRoot, store.SyncStatusFile))
+	if err != nil {
+		return time.Time{}
+	}
+	var status struct {
+		StartedEpoch int64 `json:"started_epoch"`
+	}
+	if json.Unmarshal(data, &status) != nil || status.StartedEpoch == 0 {
+		return time.Time{}
+	}
+	return time.Unix(status.StartedEpoch, 0)
+}
+
+// countChanged counts files modified at or after since (whole seconds, so a
+// write in the same second as the previous sync still counts).
+func countChanged(files []string, since time.Time) int {
+	if since.IsZero() {
+		return len(files)
+	}
+	n := 0
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil && info.ModTime().Unix() >= since.Unix() {
+			n++
+		}
+	}
+	return n
+}
+
+// Anomaly kinds flagged by AnalyzeHistory.
+const (
+	AnomalyError       = "error"        // The run failed
+	AnomalyHookFailure = "hook_failure" // A post-sync hook failed
+	AnomalySlow        = "slow"         // Took over 3x the median duration, and at least a second more
+	AnomalyBurst       = "burst"        // Added over 10x the median events, and at least minBurst
+	AnomalyShrink      = "shrink"       // The event store lost more than a tenth of its rows
+	AnomalyRestart     = "restart"      // The daemon PID changed since its previous run
+)
+
+// minBurst keeps ordinary catch-up syncs (e.g. after a laptop wakes up) from
+// being flagged when the median run adds nothing.
+const minBurst = 1000
+
+// minRunsForTrends is how many runs the slow and burst checks need before a
+// median means anything.
+const minRunsForTrends = 5
+
+// Anomaly is a run that stands out from the rest of the history.
+type Anomaly struct {
+	Time   string `json:"time"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// HistorySummary describes a window of sync runs.
+type HistorySummary struct {
+	Runs              int       `json:"runs"`
+	Failures          int       `json:"failures"`
+	FailureStreak     int       `json:"failure_streak"` // Consecutive failures ending with the latest run
+	MedianDurationMS  int64     `json:"median_duration_ms"`
+	P95DurationMS     int64     `json:"p95_duration_ms"`
+	MaxDurationMS     int64     `json:"max_duration_ms"`
+	EventsAdded       int       `json:"events_added"`
+	MedianEventsAdded int       `json:"median_events_added"`
+	Anomalies         []Anomaly `json:"anomalies"`
+}
+
+// AnalyzeHistory summarizes records (oldest first) and flags anomalies.
+func AnalyzeHistory(records []HistoryRecord) HistorySummary {
+	s := HistorySummary{Runs: len(records), Anomalies: []Anomaly{}}
+	if len(records) == 0 {
+		return s
+	}
+
+	var durations []int64
+	var added []int
+	for _, r := range records {
+		s.EventsAdded += r.EventsAdded
+		if !r.OK {
+			s.Failures++
+			s.FailureStreak++
+			continue
+		}
+		s.FailureStreak = 0
+		durations = append(durations, r.DurationMS)
+		added = append(added, r.EventsAdded)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	sort.Ints(added)
+	if len(durations) > 0 {
+		s.MedianDurationMS = durations[len(durations)/2]
+		s.P95DurationMS = durations[(len(durations)*95-1)/100]
+		s.MaxDurationMS = durations[len(durations)-1]
+		s.MedianEventsAdded = added[len(added)/2]
+	}
+	trends := len(durations) >= minRunsForTrends
+
+	var prevRows int
+	var prevDaemonPID int
+	for _, r := range records {
+		flag := func(kind, format string, args ...any) {
+			s.Anomalies = append(s.Anomalies, Anomaly{Time: r.Time, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+		}
+		if r.Daemon {
+			if prevDaemonPID != 0 && r.PID != prevDaemonPID {
+				flag(AnomalyRestart, "daemon pid %d, was %d", r.PID, prevDaemonPID)
+			}
+			prevDaemonPID = r.PID
+		}
+		if !r.OK {
+			flag(AnomalyError, "%s", r.Error)
+			continue
+		}
+		if r.HookFailures > 0 {
+			flag(AnomalyHookFailure, "%d hooks failed", r.HookFailures)
+		}
+		if trends && r.DurationMS > 3*s.MedianDurationMS && r.DurationMS-s.MedianDurationMS >= 1000 {
+			flag(AnomalySlow, "%dms, median %dms", r.DurationMS, s.MedianDurationMS)
+		}
+		if trends && r.EventsAdded > 10*s.MedianEventsAdded && r.EventsAdded >= minBurst {
+			flag(AnomalyBurst, "%d events added, median %d", r.EventsAdded, s.MedianEventsAdded)
+		}
+		if prevRows > 0 && r.EventRows < prevRows-prevRows/10 {
+			flag(AnomalyShrink, "%d event rows, was %d", r.EventRows, prevRows)
+		}
+		prevRows = r.EventRows
+	}
+	return s
+}
@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giannimassi/jevons/internal/projectpath"
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncAppendsHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	setupTestFixtures(t, sourceDir)
+	cfg := model.Config{DataRoot: dataDir, SourceDir: sourceDir}
+
+	_, err := RunWith(cfg, Options{Daemon: true})
+	require.NoError(t, err)
+
+	// Backdate the sources so the second run sees only one changed file.
+	old := time.Now().Add(-time.Hour)
+	files, _ := filepath.Glob(filepath.Join(sourceDir, "*", "*.jsonl"))
+	for _, f := range files {
+		require.NoError(t, os.Chtimes(f, old, old))
+	}
+	session := filepath.Join(sourceDir, "-Users-test-my-project", "session-003.jsonl")
+	require.NoError(t, os.WriteFile(session, []byte(`{"type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":1,"output_tokens":1,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-16T09:00:00.000Z"}`+"\n"), 0644))
+	_, err = Run(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, projectpath.AliasesFile), []byte(`{`), 0600))
+	_, err = Run(cfg)
+	require.Error(t, err)
+
+	records, err := ReadHistory(dataDir)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	first := records[0]
+	assert.True(t, first.OK)
+	assert.True(t, first.Daemon)
+	assert.Equal(t, os.Getpid(), first.PID)
+	assert.Equal(t, 2, first.FilesScanned)
+	assert.Equal(t, 2, first.FilesChanged, "everything is new on the first run")
+	assert.Equal(t, 3, first.EventsAdded)
+	assert.NotEmpty(t, first.Time)
+
+	second := records[1]
+	assert.True(t, second.OK)
+	assert.False(t, second.Daemon)
+	assert.Equal(t, 3, second.FilesScanned)
+	assert.Equal(t, 1, second.FilesChanged)
+	assert.Equal(t, 1, second.EventsAdded)
+	assert.Equal(t, 4, second.EventRows)
+
+	assert.False(t, records[2].OK)
+	assert.Contains(t, records[2].Error, "load project aliases")
+}
+
+func TestAppendHistoryRotates(t *testing.T) {
+	dataDir := t.TempDir()
+	path := filepath.Join(dataDir, HistoryFile)
+
+	for g := 0; g <= HistoryKeep; g++ {
+		require.NoError(t, os.WriteFile(historyPath(dataDir, g), []byte(strings.Repeat(" ", HistoryMaxBytes)), 0600))
+	}
+	require.NoError(t, appendHistory(dataDir, HistoryRecord{Time: "t", OK: true}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"time\":\"t\",", string(data[:12]), "new generation starts fresh")
+	for g := 1; g <= HistoryKeep; g++ {
+		info, err := os.Stat(historyPath(dataDir, g))
+		require.NoError(t, err)
+		assert.Equal(t, int64(HistoryMaxBytes), info.Size())
+	}
+	_, err = os.Stat(historyPath(dataDir, HistoryKeep+1))
+	assert.True(t, os.IsNotExist(err), "only HistoryKeep generations are kept")
+}
+
+func TestReadHistoryAcrossGenerations(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.WriteFile(historyPath(dataDir, 2), []byte(`{"time":"a"}`+"\n"), 0600))
+	require.NoError(t, os.WriteFile(historyPath(dataDir, 1), []byte(`{"time":"b"}`+"\nnot json\n"), 0600))
+	require.NoError(t, os.WriteFile(historyPath(dataDir, 0), []byte(`{"time":"c"}`+"\n"), 0600))
+
+	records, err := ReadHistory(dataDir)
+	require.NoError(t, err)
+	var times []string
+	for _, r := range records {
+		times = append(times, r.Time)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, times)
+}
+
+func TestAnalyzeHistory(t *testing.T) {
+	ok := func(ms int64, added, rows int) HistoryRecord {
+		return HistoryRecord{Time: "t", OK: true, DurationMS: ms, EventsAdded: added, EventRows: rows, PID: 1, Daemon: true}
+	}
+	steady := []HistoryRecord{ok(100, 2, 100), ok(120, 0, 100), ok(110, 1, 101), ok(90, 3, 104), ok(100, 0, 104)}
+
+	kinds := func(s HistorySummary) []string {
+		var out []string
+		for _, a := range s.Anomalies {
+			out = append(out, a.Kind)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		records []HistoryRecord
+		want    []string
+	}{
+		{"steady", steady, nil},
+		{"slow run", append(append([]HistoryRecord{}, steady...), ok(5000, 1, 105)), []string{AnomalySlow}},
+		{"burst", append(append([]HistoryRecord{}, steady...), ok(100, 5000, 5104)), []string{AnomalyBurst}},
+		{"catch-up below minimum", append(append([]HistoryRecord{}, steady...), ok(100, 500, 604)), nil},
+		{"shrink", append(append([]HistoryRecord{}, steady...), ok(100, 0, 50)), []string{AnomalyShrink}},
+		{"failure", append(append([]HistoryRecord{}, steady...), HistoryRecord{Time: "t", Error: "boom", PID: 1, Daemon: true}), []string{AnomalyError}},
+		{"hook failure", append(append([]HistoryRecord{}, steady...), HistoryRecord{Time: "t", OK: true, HookFailures: 1, EventRows: 104, PID: 1, Daemon: true}), []string{AnomalyHookFailure}},
+		{"daemon restart", append(append([]HistoryRecord{}, steady...), HistoryRecord{Time: "t", OK: true, EventRows: 104, DurationMS: 100, PID: 2, Daemon: true}), []string{AnomalyRestart}},
+		{"too few runs for trends", []HistoryRecord{ok(100, 0, 10), ok(9000, 9000, 9010)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, kinds(AnalyzeHistory(tt.records)))
+		})
+	}
+
+	s := AnalyzeHistory(append(append([]HistoryRecord{}, steady...), HistoryRecord{Error: "a"}, HistoryRecord{Error: "b"}))
+	assert.Equal(t, 7, s.Runs)
+	assert.Equal(t, 2, s.Failures)
+	assert.Equal(t, 2, s.FailureStreak)
+	assert.Equal(t, int64(100), s.MedianDurationMS)
+	assert.Equal(t, int64(120), s.MaxDurationMS)
+	assert.Equal(t, 6, s.EventsAdded)
+	assert.Equal(t, 1, s.MedianEventsAdded)
+}
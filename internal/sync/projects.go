@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/giannimassi/jevons/internal/parser"
+	"github.com/giannimassi/jevons/internal/projectpath"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// ProjectDecision records whether sync tracks a project under the configured
+// include/exclude rules.
+type ProjectDecision struct {
+	Slug     string `json:"slug"`
+	Path     string `json:"path"`
+	Included bool   `json:"included"`
+	Reason   string `json:"reason,omitempty"` // Why an excluded project is excluded
+}
+
+// ProjectDecisions applies cfg.Projects to every project in the source
+// directory, in slug order. It reads only the source directory and the
+// alias file.
+func ProjectDecisions(cfg model.Config) ([]ProjectDecision, error) {
+	aliases, err := projectpath.LoadAliases(filepath.Join(cfg.DataRoot, projectpath.AliasesFile))
+	if err != nil {
+		return nil, fmt.Errorf("load project aliases: %w", err)
+	}
+	files, err := discoverSessionFiles(cfg.SourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("discover sessions: %w", err)
+	}
+	_, decisions := filterProjects(files, cfg.Projects, aliases)
+	return decisions, nil
+}
+
+// filterProjects drops the session files of projects rules exclude. Files
+// are grouped by their slug directory; discovery order keeps each group
+// contiguous. Projects are matched on slug and on the path projects.json
+// would record for them, which needs a cwd, so this reads the start of one
+// session file per project but parses none.
+func filterProjects(files []string, rules model.ProjectRules, aliases projectpath.Aliases) ([]string, []ProjectDecision) {
+	var kept []string
+	var decisions []ProjectDecision
+	for start := 0; start < len(files); {
+		dir := filepath.Dir(files[start])
+		end := start
+		for end < len(files) && filepath.Dir(files[end]) == dir {
+			end++
+		}
+		group := files[start:end]
+		start = end
+
+		slug := filepath.Base(dir)
+		path := projectPathFromFiles(slug, group, aliases)
+		included, reason := rules.Match(slug, path)
+		decisions = append(decisions, ProjectDecision{Slug: slug, Path: path, Included: included, Reason: reason})
+		if included {
+			kept = append(kept, group...)
+		}
+	}
+	return kept, decisions
+}
+
+func projectPathFromFiles(slug string, files []string, aliases projectpath.Aliases) string {
+	var paths []string
+	for _, f := range files {
+		if p := parser.ExtractProjectPath(f); p != "" {
+			paths = append(paths, p)
+			break
+		}
+	}
+	return resolveProjectPath(slug, paths, aliases)
+}
+
+// resolveProjectPath picks the path recorded for slug: the first known path
+// among paths, else a directory decoded from the slug, else an /unknown/
+// placeholder. Aliases then move it to where the project lives now.
+func resolveProjectPath(slug string, paths []string, aliases projectpath.Aliases) string {
+	chosen := projectpath.UnknownPrefix + slug
+	for _, p := range paths {
+		if !strings.HasPrefix(p, projectpath.UnknownPrefix) {
+			chosen = p
+			break
+		}
+	}
+	if strings.HasPrefix(chosen, projectpath.UnknownPrefix) {
+		if found := (projectpath.Resolver{}).FromSlug(slug); found != "" {
+			chosen = found
+		}
+	}
+	return aliases.Apply(slug, chosen)
+}
@@ -1,132 +1,510 @@
 package sync
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	gosync "sync"
 	"time"
 
+	"github.com/giannimassi/jevons/internal/gitinfo"
+	"github.com/giannimassi/jevons/internal/hooks"
 	"github.com/giannimassi/jevons/internal/parser"
+	"github.com/giannimassi/jevons/internal/projectpath"
 	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
 	"github.com/giannimassi/jevons/pkg/model"
 )
 
 // Result contains the outcome of a sync operation.
 type Result struct {
-	SessionFiles  int
-	EventRows     int
-	LiveEventRows int
-	SourceRoot    string
+	SessionFiles     int
+	EventRows        int
+	LiveEventRows    int
+	NewEvents        int   // Events that received a sequence number in this run
+	LastSeq          int64 // Ingest high-water mark after this run
+	SourceRoot       string
+	FilesParsed      int // Session files parsed this run; the rest came from the Cache
+	FilesChanged     int // Session files modified since the previous sync started
+	ParseErrors      int // Session files that could not be read or parsed
+	ProjectsExcluded int // Projects skipped by the include/exclude rules
+	Workers          int
+	Phases           Phases
+	Started          time.Time
+	Duration         time.Duration
+	Hooks            []hooks.Result // One per configured hook; empty when nothing new was ingested
+}
+
+// Phases records how long each stage of a sync took.
+type Phases struct {
+	Discover time.Duration
+	Parse    time.Duration
+	Sort     time.Duration
+	Dedup    time.Duration
+	Write    time.Duration
+}
+
+// parsed holds everything extracted from one session file.
+type parsed struct {
+	project projectEntry
+	events  []model.TokenEvent
+	live    []model.LiveEvent
+	failed  bool // The file could not be read
+}
+
+// Cache keeps parse results between runs of a long-lived process, so a sync
+// only re-parses session files whose size or mtime changed.
+type Cache struct {
+	mu    gosync.Mutex
+	files map[string]cachedFile
+}
+
+type cachedFile struct {
+	size    int64
+	modTime time.Time
+	parsed  parsed
+}
+
+// NewCache creates an empty Cache.
+func NewCache() *Cache {
+	return &Cache{files: make(map[string]cachedFile)}
+}
+
+// Options tune a sync run.
+type Options struct {
+	Cache  *Cache // Reuse parse results of unchanged files; nil parses every file
+	Wait   bool   // Wait for a concurrent sync instead of failing with store.ErrLocked
+	Daemon bool   // Run by the background daemon; recorded in the sync history
 }
 
 // Run executes the full sync pipeline.
 func Run(cfg model.Config) (*Result, error) {
+	return RunWith(cfg, Options{})
+}
+
+// RunWith executes the sync pipeline with opts. Only one process writes the
+// stores at a time: the DataRoot lock is held while they are written. Hooks
+// run after it is released, so a slow webhook never holds up another sync or
+// a restore; the lock is taken again to record their results.
+//
+// Every run that gets the lock, failed or not, is appended to the sync
+// history (see HistoryFile).
+func RunWith(cfg model.Config, opts Options) (*Result, error) {
+	result := &Result{Started: time.Now(), SourceRoot: cfg.SourceDir, Workers: workerCount(cfg.Workers)}
+
 	if err := ensureDataDirs(cfg.DataRoot); err != nil {
 		return nil, fmt.Errorf("create data dirs: %w", err)
 	}
 
-	sessionFiles, err := discoverSessionFiles(cfg.SourceDir)
+	lock, err := store.Lock(cfg.DataRoot, opts.Wait)
 	if err != nil {
-		return nil, fmt.Errorf("discover sessions: %w", err)
+		return nil, err
 	}
+	payload, err := run(cfg, opts, result)
+	result.Duration = time.Since(result.Started)
+	if payload != nil {
+		lock.Unlock()
+		result.Hooks = runHooks(cfg.Hooks, *payload)
+		relock, lockErr := store.Lock(cfg.DataRoot, true)
+		if lockErr != nil {
+			slog.Warn("hook results not recorded", "err", lockErr)
+			logResult(result)
+			return result, nil
+		}
+		lock = relock
+		if err := recordHooks(filepath.Join(cfg.DataRoot, store.SyncStatusFile), result); err != nil {
+			slog.Warn("hook results not recorded", "err", err)
+		}
+	}
+	defer lock.Unlock()
 
-	var allEvents []model.TokenEvent
-	var allLiveEvents []model.LiveEvent
+	// The history is diagnostics only; failing to append never fails a sync.
+	_ = appendHistory(cfg.DataRoot, newHistoryRecord(result, err, opts.Daemon))
+	if err != nil {
+		return nil, err
+	}
+	logResult(result)
+	return result, nil
+}
 
-	var projects []projectEntry
+// run is the body of RunWith, called with the DataRoot lock held. It returns
+// the payload for cfg.Hooks when they have new events to deliver.
+func run(cfg model.Config, opts Options, result *Result) (*hooks.Payload, error) {
+	prevStart := readLastSyncStart(cfg.DataRoot)
 
-	for _, sf := range sessionFiles {
-		slug := filepath.Base(filepath.Dir(sf))
-		sessionID := strings.TrimSuffix(filepath.Base(sf), ".jsonl")
+	v, err := vault.Load(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("load encryption key: %w", err)
+	}
 
-		projectPath := parser.ExtractProjectPath(sf)
-		if projectPath == "" {
-			projectPath = fmt.Sprintf("/unknown/%s", slug)
-		}
-		projects = append(projects, projectEntry{Slug: slug, Path: projectPath})
+	aliases, err := projectpath.LoadAliases(filepath.Join(cfg.DataRoot, projectpath.AliasesFile))
+	if err != nil {
+		return nil, fmt.Errorf("load project aliases: %w", err)
+	}
 
-		events, err := parser.ParseSessionFile(sf, slug, sessionID)
-		if err != nil {
-			continue
+	c, err := collect(cfg, opts.Cache, aliases, result)
+	if err != nil {
+		return nil, err
+	}
+	result.FilesChanged = countChanged(c.files, prevStart)
+
+	t := time.Now()
+	// Events keep the sequence number they were first ingested with, so
+	// consumers can tail the store with a cursor across rewrites.
+	eventsPath := filepath.Join(cfg.DataRoot, "events.tsv")
+	previous, err := store.ReadEvents(eventsPath, v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read events.tsv: %w", err)
+	}
+	projectsPath := filepath.Join(cfg.DataRoot, "projects.json")
+	prevProjects, err := store.ReadProjects(projectsPath, v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read projects.json: %w", err)
+	}
+	c.keepArchived(previous, prevProjects, cfg.Projects)
+	allEvents, allLiveEvents, projects := c.events, c.live, c.projects
+	seqs := store.LoadSeqs(cfg.DataRoot, previous)
+	lastSeq := seqs.Last
+	seqs.Assign(allEvents)
+
+	accountsPath := filepath.Join(cfg.DataRoot, store.AccountsFile)
+	accounts, err := store.ReadAccounts(accountsPath, v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", store.AccountsFile, err)
+	}
+	current := writeAccountJSON(filepath.Join(cfg.DataRoot, "account.json"), v)
+	accounts = accounts.Observe(current, time.Now().Unix())
+	attributeAccounts(allEvents, previous, accounts)
+
+	if err := store.WriteEventsTSV(eventsPath, allEvents, v); err != nil {
+		return nil, fmt.Errorf("write events.tsv: %w", err)
+	}
+	if err := store.WriteLiveEventsTSV(filepath.Join(cfg.DataRoot, "live-events.tsv"), allLiveEvents, v); err != nil {
+		return nil, fmt.Errorf("write live-events.tsv: %w", err)
+	}
+	if err := writeProjectsJSON(projectsPath, projects, aliases, v); err != nil {
+		return nil, fmt.Errorf("write projects.json: %w", err)
+	}
+	if err := store.WriteAccounts(accountsPath, accounts, v); err != nil {
+		return nil, fmt.Errorf("write %s: %w", store.AccountsFile, err)
+	}
+	result.Phases.Write = time.Since(t)
+
+	result.SessionFiles = len(c.files)
+	result.EventRows = len(allEvents)
+	result.LiveEventRows = len(allLiveEvents)
+	result.NewEvents = int(seqs.Last - lastSeq)
+	result.LastSeq = seqs.Last
+
+	result.Duration = time.Since(result.Started)
+
+	if err := writeSyncStatus(filepath.Join(cfg.DataRoot, store.SyncStatusFile), time.Now(), result); err != nil {
+		return nil, fmt.Errorf("write sync-status.json: %w", err)
+	}
+
+	// The first sync of a data root has no high-water mark yet: everything
+	// it ingests is history rather than news, so hooks start with the next.
+	if len(cfg.Hooks) == 0 || result.NewEvents == 0 || lastSeq == 0 {
+		return nil, nil
+	}
+	payload := newPayload(allEvents, lastSeq, seqs.Last)
+	return &payload, nil
+}
+
+// runHooks delivers payload to every hook, logging failures.
+func runHooks(cfgs []model.HookConfig, payload hooks.Payload) []hooks.Result {
+	results := hooks.Run(cfgs, payload)
+	for _, h := range results {
+		if !h.OK {
+			slog.Warn("hook failed", "hook", h.Name, "err", h.Error)
 		}
-		allEvents = append(allEvents, events...)
+	}
+	return results
+}
 
-		liveEvents, err := parser.ParseSessionFileLive(sf, slug, sessionID)
-		if err != nil {
-			continue
+// recordHooks adds result.Hooks to the sync-status.json written for result,
+// unless a later sync has replaced it in the meantime.
+func recordHooks(path string, result *Result) error {
+	data, err := store.ReadFile(path, nil)
+	if err != nil {
+		return err
+	}
+	var status map[string]any
+	if err := json.Unmarshal(data, &status); err != nil {
+		return err
+	}
+	seq, _ := status["last_seq"].(float64)
+	started, _ := status["started_epoch"].(float64)
+	if int64(seq) != result.LastSeq || int64(started) != result.Started.Unix() {
+		return nil
+	}
+	status["hooks"] = result.Hooks
+	data, err = json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(path, append(data, '\n'), nil)
+}
+
+// logResult logs a finished sync: at info level when it ingested new
+// events, at debug level otherwise so idle daemon ticks stay quiet.
+func logResult(r *Result) {
+	level := slog.LevelDebug
+	if r.NewEvents > 0 {
+		level = slog.LevelInfo
+	}
+	slog.Log(context.Background(), level, "sync finished",
+		"new_events", r.NewEvents,
+		"event_rows", r.EventRows,
+		"session_files", r.SessionFiles,
+		"files_parsed", r.FilesParsed,
+		"duration", r.Duration)
+}
+
+// newPayload collects the events ingested after lastSeq, in ingest order.
+func newPayload(events []model.TokenEvent, lastSeq, newLast int64) hooks.Payload {
+	var fresh []model.TokenEvent
+	for _, e := range events {
+		if e.Seq > lastSeq {
+			fresh = append(fresh, e)
 		}
-		allLiveEvents = append(allLiveEvents, liveEvents...)
 	}
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].Seq < fresh[j].Seq })
+	return hooks.Payload{
+		SyncedAt: time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Count:    len(fresh),
+		LastSeq:  newLast,
+		Events:   fresh,
+	}
+}
+
+// collected is the event set a sync would write.
+type collected struct {
+	events   []model.TokenEvent
+	live     []model.LiveEvent
+	projects []projectEntry
+	files    []string
+	parsed   map[sessionKey]bool // Sessions whose file was read this run
+}
+
+// sessionKey identifies a session by project slug and session ID.
+type sessionKey struct{ slug, session string }
+
+func sessionKeyOf(sf string) sessionKey {
+	return sessionKey{filepath.Base(filepath.Dir(sf)), strings.TrimSuffix(filepath.Base(sf), ".jsonl")}
+}
 
-	// Sort events (stable for deterministic output with equal keys)
-	sort.SliceStable(allEvents, func(i, j int) bool {
-		a, b := allEvents[i], allEvents[j]
-		if a.TSEpoch != b.TSEpoch {
-			return a.TSEpoch < b.TSEpoch
+// keepArchived adds the stored events of sessions this run did not read:
+// their session file was deleted or could not be read, or they were
+// restored from a backup. The store is the only copy of them, so they are
+// kept unless rules now exclude their project. Their slugs keep the
+// projects.json path recorded in prevProjects.
+func (c *collected) keepArchived(previous []model.TokenEvent, prevProjects []model.Project, rules model.ProjectRules) {
+	prevPaths := make(map[string]string, len(prevProjects))
+	for _, p := range prevProjects {
+		prevPaths[p.Slug] = p.Path
+	}
+	known := make(map[string]bool, len(c.projects))
+	for _, p := range c.projects {
+		known[p.Slug] = true
+	}
+
+	var kept int
+	tracked := make(map[string]bool)
+	for _, e := range previous {
+		if c.parsed[sessionKey{e.ProjectSlug, e.SessionID}] {
+			continue
 		}
-		if a.TSISO != b.TSISO {
-			return a.TSISO < b.TSISO
+		path, ok := prevPaths[e.ProjectSlug]
+		if !ok {
+			path = projectpath.UnknownPrefix + e.ProjectSlug
 		}
-		if a.ProjectSlug != b.ProjectSlug {
-			return a.ProjectSlug < b.ProjectSlug
+		include, seen := tracked[e.ProjectSlug]
+		if !seen {
+			include, _ = rules.Match(e.ProjectSlug, path)
+			tracked[e.ProjectSlug] = include
 		}
-		if a.SessionID != b.SessionID {
-			return a.SessionID < b.SessionID
+		if !include {
+			continue
 		}
-		return a.Signature < b.Signature
-	})
-	allEvents = dedupEvents(allEvents)
+		c.events = append(c.events, e)
+		kept++
+		if !known[e.ProjectSlug] {
+			known[e.ProjectSlug] = true
+			c.projects = append(c.projects, projectEntry{Slug: e.ProjectSlug, Path: path})
+		}
+	}
+	if kept > 0 {
+		store.SortEvents(c.events)
+	}
+}
 
-	sort.SliceStable(allLiveEvents, func(i, j int) bool {
-		a, b := allLiveEvents[i], allLiveEvents[j]
-		if a.TSEpoch != b.TSEpoch {
-			return a.TSEpoch < b.TSEpoch
+// collect discovers, parses, sorts and deduplicates session files, recording
+// phase timings in result. Projects excluded by cfg.Projects are dropped
+// before parsing. It reads only the source directory.
+func collect(cfg model.Config, cache *Cache, aliases projectpath.Aliases, result *Result) (*collected, error) {
+	t := time.Now()
+	sessionFiles, err := discoverSessionFiles(cfg.SourceDir)
+	if err == nil && !cfg.Projects.Empty() {
+		var decisions []ProjectDecision
+		sessionFiles, decisions = filterProjects(sessionFiles, cfg.Projects, aliases)
+		for _, d := range decisions {
+			if !d.Included {
+				result.ProjectsExcluded++
+			}
 		}
-		if a.TSISO != b.TSISO {
-			return a.TSISO < b.TSISO
+	}
+	result.Phases.Discover = time.Since(t)
+	if err != nil {
+		return nil, fmt.Errorf("discover sessions: %w", err)
+	}
+
+	t = time.Now()
+	files, parsedCount := parseAll(sessionFiles, result.Workers, cache)
+	result.FilesParsed = parsedCount
+	result.Phases.Parse = time.Since(t)
+
+	// Concatenate in discovery order so the output matches a sequential run.
+	c := &collected{files: sessionFiles, parsed: make(map[sessionKey]bool, len(sessionFiles))}
+	for i, f := range files {
+		if f.failed {
+			result.ParseErrors++
+		} else {
+			c.parsed[sessionKeyOf(sessionFiles[i])] = true
 		}
-		if a.ProjectSlug != b.ProjectSlug {
-			return a.ProjectSlug < b.ProjectSlug
+		c.projects = append(c.projects, f.project)
+		c.events = append(c.events, f.events...)
+		c.live = append(c.live, f.live...)
+	}
+
+	t = time.Now()
+	store.SortEvents(c.events)
+	store.SortLiveEvents(c.live)
+	result.Phases.Sort = time.Since(t)
+
+	t = time.Now()
+	c.events = store.DedupEvents(c.events)
+	c.live = store.DedupLiveEvents(c.live)
+	result.Phases.Dedup = time.Since(t)
+
+	return c, nil
+}
+
+// workerCount resolves the configured worker count (0 = one per CPU).
+func workerCount(n int) int {
+	if n <= 0 {
+		return runtime.NumCPU()
+	}
+	return n
+}
+
+// parseAll parses session files with a bounded pool of workers. Results are
+// indexed by file, so their order does not depend on scheduling. It returns
+// the results and how many files were actually parsed.
+func parseAll(sessionFiles []string, workers int, cache *Cache) ([]parsed, int) {
+	results := make([]parsed, len(sessionFiles))
+	stats := make([]os.FileInfo, len(sessionFiles))
+	var todo []int
+	for i, sf := range sessionFiles {
+		if cache == nil {
+			todo = append(todo, i)
+			continue
 		}
-		if a.SessionID != b.SessionID {
-			return a.SessionID < b.SessionID
+		info, err := os.Stat(sf)
+		if err == nil {
+			stats[i] = info
+			if p, ok := cache.get(sf, info); ok {
+				results[i] = p
+				continue
+			}
 		}
-		return a.Signature < b.Signature
-	})
-	allLiveEvents = dedupLiveEvents(allLiveEvents)
+		todo = append(todo, i)
+	}
 
-	if err := writeEventsTSV(filepath.Join(cfg.DataRoot, "events.tsv"), allEvents); err != nil {
-		return nil, fmt.Errorf("write events.tsv: %w", err)
+	jobs := make(chan int)
+	var wg gosync.WaitGroup
+	for w := 0; w < min(workers, len(todo)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = parseSessionFile(sessionFiles[i])
+			}
+		}()
 	}
-	if err := writeLiveEventsTSV(filepath.Join(cfg.DataRoot, "live-events.tsv"), allLiveEvents); err != nil {
-		return nil, fmt.Errorf("write live-events.tsv: %w", err)
+	for _, i := range todo {
+		jobs <- i
 	}
-	if err := writeProjectsJSON(filepath.Join(cfg.DataRoot, "projects.json"), projects); err != nil {
-		return nil, fmt.Errorf("write projects.json: %w", err)
+	close(jobs)
+	wg.Wait()
+
+	if cache != nil {
+		cache.update(sessionFiles, stats, results)
 	}
+	return results, len(todo)
+}
 
-	writeAccountJSON(filepath.Join(cfg.DataRoot, "account.json"))
+func (c *Cache) get(path string, info os.FileInfo) (parsed, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.files[path]
+	if !ok || f.size != info.Size() || !f.modTime.Equal(info.ModTime()) {
+		return parsed{}, false
+	}
+	return f.parsed, true
+}
 
-	now := time.Now()
-	result := &Result{
-		SessionFiles:  len(sessionFiles),
-		EventRows:     len(allEvents),
-		LiveEventRows: len(allLiveEvents),
-		SourceRoot:    cfg.SourceDir,
+// update replaces the cache contents with this run's files, dropping
+// files that no longer exist. Files that could not be stat'ed are not cached.
+func (c *Cache) update(paths []string, stats []os.FileInfo, results []parsed) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = make(map[string]cachedFile, len(paths))
+	for i, path := range paths {
+		if stats[i] == nil {
+			continue
+		}
+		c.files[path] = cachedFile{size: stats[i].Size(), modTime: stats[i].ModTime(), parsed: results[i]}
 	}
-	if err := writeSyncStatus(filepath.Join(cfg.DataRoot, "sync-status.json"), now, result); err != nil {
-		return nil, fmt.Errorf("write sync-status.json: %w", err)
+}
+
+func parseSessionFile(sf string) parsed {
+	key := sessionKeyOf(sf)
+	slug, sessionID := key.slug, key.session
+
+	projectPath := parser.ExtractProjectPath(sf)
+	if projectPath == "" {
+		projectPath = projectpath.UnknownPrefix + slug
 	}
+	p := parsed{project: projectEntry{Slug: slug, Path: projectPath}}
 
-	return result, nil
+	events, err := parser.ParseSessionFile(sf, slug, sessionID)
+	if err != nil {
+		slog.Warn("skipping unreadable session file", "path", sf, "err", err)
+		p.failed = true
+		return p
+	}
+	p.events = events
+
+	liveEvents, err := parser.ParseSessionFileLive(sf, slug, sessionID)
+	if err != nil {
+		slog.Warn("skipping unreadable session file", "path", sf, "err", err)
+		p.failed = true
+		return p
+	}
+	p.live = liveEvents
+	return p
 }
 
 func ensureDataDirs(dataRoot string) error {
 	for _, sub := range []string{"", "pids", "logs", "heartbeat", "web", "dashboard"} {
-		if err := os.MkdirAll(filepath.Join(dataRoot, sub), 0755); err != nil {
+		if err := os.MkdirAll(filepath.Join(dataRoot, sub), store.DirMode); err != nil {
 			return err
 		}
 	}
@@ -147,72 +525,11 @@ func discoverSessionFiles(sourceDir string) ([]string, error) {
 	return matches, nil
 }
 
-func dedupEvents(events []model.TokenEvent) []model.TokenEvent {
-	if len(events) == 0 {
-		return events
-	}
-	seen := make(map[string]bool)
-	result := make([]model.TokenEvent, 0, len(events))
-	for _, e := range events {
-		line := store.MarshalTokenEvent(e)
-		if !seen[line] {
-			seen[line] = true
-			result = append(result, e)
-		}
-	}
-	return result
-}
-
-func dedupLiveEvents(events []model.LiveEvent) []model.LiveEvent {
-	if len(events) == 0 {
-		return events
-	}
-	seen := make(map[string]bool)
-	result := make([]model.LiveEvent, 0, len(events))
-	for _, e := range events {
-		line := store.MarshalLiveEvent(e)
-		if !seen[line] {
-			seen[line] = true
-			result = append(result, e)
-		}
-	}
-	return result
-}
-
-// atomicWriteFile writes data to a temp file then renames atomically (matching shell behavior).
-func atomicWriteFile(path string, data []byte) error {
-	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, data, 0644); err != nil {
-		return err
-	}
-	return os.Rename(tmp, path)
-}
-
-func writeEventsTSV(path string, events []model.TokenEvent) error {
-	var b strings.Builder
-	b.WriteString(store.EventsTSVHeader)
-	b.WriteByte('\n')
-	for _, e := range events {
-		b.WriteString(store.MarshalTokenEvent(e))
-		b.WriteByte('\n')
-	}
-	return atomicWriteFile(path, []byte(b.String()))
-}
-
-func writeLiveEventsTSV(path string, events []model.LiveEvent) error {
-	var b strings.Builder
-	b.WriteString(store.LiveEventsTSVHeader)
-	b.WriteByte('\n')
-	for _, e := range events {
-		b.WriteString(store.MarshalLiveEvent(e))
-		b.WriteByte('\n')
-	}
-	return atomicWriteFile(path, []byte(b.String()))
-}
-
-func writeProjectsJSON(path string, entries []projectEntry) error {
+// writeProjectsJSON writes one manifest entry per slug, with the path chosen
+// by resolveProjectPath from the cwds its sessions recorded.
+func writeProjectsJSON(path string, entries []projectEntry, aliases projectpath.Aliases, v *vault.Vault) error {
 	if len(entries) == 0 {
-		return os.WriteFile(path, []byte("[]\n"), 0644)
+		return store.WriteProjects(path, nil, v)
 	}
 
 	// Sort entries for deterministic grouping (matches shell's LC_ALL=C sort -u)
@@ -223,62 +540,76 @@ func writeProjectsJSON(path string, entries []projectEntry) error {
 		return entries[i].Path < entries[j].Path
 	})
 
-	// Group by slug, prefer non-/unknown/ paths
+	// Group by slug, prefer non-/unknown/ paths from any session
 	grouped := make(map[string][]string)
 	for _, e := range entries {
 		grouped[e.Slug] = append(grouped[e.Slug], e.Path)
 	}
 
-	type projectOut struct {
-		Slug string `json:"slug"`
-		Path string `json:"path"`
-	}
-
-	var result []projectOut
+	var result []model.Project
 	for slug, paths := range grouped {
-		chosen := paths[0]
-		for _, p := range paths {
-			if !strings.HasPrefix(p, "/unknown/") {
-				chosen = p
-				break
-			}
+		chosen := resolveProjectPath(slug, paths, aliases)
+
+		project := model.Project{Slug: slug, Path: chosen}
+		if !strings.HasPrefix(chosen, projectpath.UnknownPrefix) {
+			project.Git = gitinfo.Lookup(chosen)
 		}
-		result = append(result, projectOut{Slug: slug, Path: chosen})
+		result = append(result, project)
 	}
 
 	sort.Slice(result, func(i, j int) bool {
 		return result[i].Path < result[j].Path
 	})
 
-	data, err := json.MarshalIndent(result, "", "  ")
-	if err != nil {
-		return err
+	return store.WriteProjects(path, result, v)
+}
+
+// attributeAccounts sets Account and Org on events. Events already in the
+// store keep the account they were first attributed to; new ones get the
+// account that was active at their timestamp.
+func attributeAccounts(events, previous []model.TokenEvent, accounts model.AccountHistory) {
+	type owner struct{ account, org string }
+	known := make(map[string]owner, len(previous))
+	for _, e := range previous {
+		if e.Account != "" {
+			known[e.ID] = owner{e.Account, e.Org}
+		}
+	}
+	for i := range events {
+		if o, ok := known[events[i].ID]; ok {
+			events[i].Account, events[i].Org = o.account, o.org
+			continue
+		}
+		if s := accounts.At(events[i].TSEpoch); s != nil {
+			events[i].Account, events[i].Org = s.AccountUUID, s.OrganizationUUID
+		}
 	}
-	return os.WriteFile(path, append(data, '\n'), 0644)
 }
 
-func writeAccountJSON(path string) {
+// writeAccountJSON writes account.json from ~/.claude.json and returns the
+// current account.
+func writeAccountJSON(path string, v *vault.Vault) model.AccountSnapshot {
 	home, _ := os.UserHomeDir()
-	writeAccountJSONFrom(path, filepath.Join(home, ".claude.json"))
+	return writeAccountJSONFrom(path, filepath.Join(home, ".claude.json"), v)
 }
 
-func writeAccountJSONFrom(outPath string, claudeJSONPath string) {
+func writeAccountJSONFrom(outPath string, claudeJSONPath string, v *vault.Vault) model.AccountSnapshot {
 	data, err := os.ReadFile(claudeJSONPath)
 	if err != nil {
-		os.WriteFile(outPath, []byte("{}\n"), 0644)
-		return
+		store.WriteFile(outPath, []byte("{}\n"), v)
+		return model.AccountSnapshot{}
 	}
 
 	var raw map[string]any
 	if err := json.Unmarshal(data, &raw); err != nil {
-		os.WriteFile(outPath, []byte("{}\n"), 0644)
-		return
+		store.WriteFile(outPath, []byte("{}\n"), v)
+		return model.AccountSnapshot{}
 	}
 
 	oauth, _ := raw["oauthAccount"].(map[string]any)
 	if oauth == nil {
-		os.WriteFile(outPath, []byte("{}\n"), 0644)
-		return
+		store.WriteFile(outPath, []byte("{}\n"), v)
+		return model.AccountSnapshot{}
 	}
 
 	account := map[string]any{
@@ -297,23 +628,51 @@ func writeAccountJSONFrom(outPath string, claudeJSONPath string) {
 	}
 
 	out, _ := json.MarshalIndent(account, "", "  ")
-	os.WriteFile(outPath, append(out, '\n'), 0644)
+	store.WriteFile(outPath, append(out, '\n'), v)
+
+	str := func(k string) string { s, _ := oauth[k].(string); return s }
+	return model.AccountSnapshot{
+		AccountUUID:      str("accountUuid"),
+		OrganizationUUID: str("organizationUuid"),
+		Email:            str("emailAddress"),
+		DisplayName:      str("displayName"),
+		BillingType:      str("billingType"),
+	}
 }
 
 func writeSyncStatus(path string, now time.Time, result *Result) error {
 	status := map[string]any{
-		"last_sync_epoch": now.Unix(),
-		"last_sync_iso":   now.UTC().Format("2006-01-02T15:04:05Z"),
-		"source_root":     result.SourceRoot,
-		"session_files":   result.SessionFiles,
-		"event_rows":      result.EventRows,
-		"live_event_rows": result.LiveEventRows,
+		"last_sync_epoch":   now.Unix(),
+		"last_sync_iso":     now.UTC().Format("2006-01-02T15:04:05Z"),
+		"source_root":       result.SourceRoot,
+		"session_files":     result.SessionFiles,
+		"event_rows":        result.EventRows,
+		"live_event_rows":   result.LiveEventRows,
+		"new_events":        result.NewEvents,
+		"last_seq":          result.LastSeq,
+		"workers":           result.Workers,
+		"files_parsed":      result.FilesParsed,
+		"files_changed":     result.FilesChanged,
+		"parse_errors":      result.ParseErrors,
+		"projects_excluded": result.ProjectsExcluded,
+		"started_epoch":     result.Started.Unix(),
+		"duration_ms":       result.Duration.Milliseconds(),
+		"phases_ms": map[string]int64{
+			"discover": result.Phases.Discover.Milliseconds(),
+			"parse":    result.Phases.Parse.Milliseconds(),
+			"sort":     result.Phases.Sort.Milliseconds(),
+			"dedup":    result.Phases.Dedup.Milliseconds(),
+			"write":    result.Phases.Write.Milliseconds(),
+		},
+	}
+	if len(result.Hooks) > 0 {
+		status["hooks"] = result.Hooks
 	}
 	data, err := json.MarshalIndent(status, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, append(data, '\n'), 0644)
+	return store.WriteFile(path, append(data, '\n'), nil)
 }
 
 // projectEntry holds a slug→path mapping (package-level for reuse).
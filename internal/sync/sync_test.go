@@ -2,11 +2,16 @@ package sync
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/giannimassi/jevons/internal/projectpath"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
 	"github.com/giannimassi/jevons/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -68,6 +73,13 @@ func TestSyncRun(t *testing.T) {
 	assert.Contains(t, lines[2], "session-001")
 	assert.Contains(t, lines[3], "session-002")
 
+	// Verify the extension sidecar and sparse index written alongside it
+	extData, err := os.ReadFile(filepath.Join(dataDir, "events-ext.tsv"))
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(extData)), "\n"), len(lines), "sidecar is row-aligned")
+	_, err = os.Stat(filepath.Join(dataDir, "events.idx"))
+	assert.NoError(t, err)
+
 	// Verify live-events.tsv
 	liveData, err := os.ReadFile(filepath.Join(dataDir, "live-events.tsv"))
 	require.NoError(t, err)
@@ -96,6 +108,107 @@ func TestSyncRun(t *testing.T) {
 	assert.Equal(t, float64(3), status["event_rows"])
 }
 
+func TestSyncProjectsGitMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	// A checkout, a subdirectory of it and a linked worktree.
+	repo := filepath.Join(tmpDir, "code", "repo")
+	gitDir := filepath.Join(repo, ".git")
+	require.NoError(t, os.MkdirAll(filepath.Join(gitDir, "worktrees", "wt"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "web"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "config"), []byte("[remote \"origin\"]\n\turl = https://example.com/repo.git\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "worktrees", "wt", "commondir"), []byte("../..\n"), 0644))
+	wt := filepath.Join(tmpDir, "code", "wt")
+	require.NoError(t, os.MkdirAll(wt, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(wt, ".git"), []byte("gitdir: "+filepath.Join(gitDir, "worktrees", "wt")+"\n"), 0644))
+
+	for slug, cwd := range map[string]string{"-repo": repo, "-repo-web": filepath.Join(repo, "web"), "-wt": wt, "-elsewhere": "/nonexistent/elsewhere"} {
+		dir := filepath.Join(sourceDir, slug)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		line := fmt.Sprintf(`{"cwd":%q,"type":"user","message":{"role":"user","content":"hi"},"timestamp":"2025-01-15T10:00:00.000Z"}`, cwd)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "s.jsonl"), []byte(line+"\n"), 0644))
+	}
+
+	_, err := Run(model.Config{DataRoot: dataDir, SourceDir: sourceDir})
+	require.NoError(t, err)
+
+	projects, err := store.ReadProjects(filepath.Join(dataDir, "projects.json"), nil)
+	require.NoError(t, err)
+	bySlug := make(map[string]model.Project)
+	for _, p := range projects {
+		bySlug[p.Slug] = p
+	}
+	require.Len(t, bySlug, 4)
+
+	assert.Nil(t, bySlug["-elsewhere"].Git)
+	assert.Equal(t, "/nonexistent/elsewhere", bySlug["-elsewhere"].RepoKey())
+	for _, slug := range []string{"-repo", "-repo-web", "-wt"} {
+		p := bySlug[slug]
+		require.NotNil(t, p.Git, slug)
+		assert.Equal(t, repo, p.RepoKey(), slug)
+		assert.Equal(t, "https://example.com/repo.git", p.Git.RemoteURL, slug)
+	}
+	assert.Equal(t, repo, bySlug["-repo-web"].Git.Root)
+	assert.True(t, bySlug["-wt"].Git.Worktree)
+	assert.Equal(t, wt, bySlug["-wt"].Git.Root)
+}
+
+func TestSyncResolvesProjectPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	require.NoError(t, os.MkdirAll(dataDir, 0700))
+
+	decodable := filepath.Join(tmpDir, "code", "my-app")
+	moved := filepath.Join(tmpDir, "code", "renamed")
+	require.NoError(t, os.MkdirAll(decodable, 0755))
+	require.NoError(t, os.MkdirAll(moved, 0755))
+
+	noCWD := `{"type":"user","message":{"role":"user","content":"hi"},"timestamp":"2025-01-15T10:00:00.000Z"}` + "\n"
+	withCWD := func(cwd string) string {
+		return fmt.Sprintf(`{"cwd":%q,"type":"user","message":{"role":"user","content":"hi"},"timestamp":"2025-01-15T10:00:00.000Z"}`, cwd) + "\n"
+	}
+	sessions := map[string]string{
+		// No session has a cwd, but the slug decodes to an existing directory.
+		projectpath.Encode(decodable) + "/a.jsonl": noCWD,
+		// One session lacks a cwd; another session of the slug has it.
+		"-somewhere-else/a.jsonl": noCWD,
+		"-somewhere-else/b.jsonl": withCWD("/somewhere/else"),
+		// Moved away: the alias file says where it lives now.
+		"-old-place/a.jsonl": withCWD("/old/place/sub"),
+		"-gone/a.jsonl":      noCWD,
+	}
+	for name, content := range sessions {
+		path := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, projectpath.AliasesFile),
+		[]byte(fmt.Sprintf(`{"/old/place": %q}`, moved)), 0600))
+
+	_, err := Run(model.Config{DataRoot: dataDir, SourceDir: sourceDir})
+	require.NoError(t, err)
+
+	projects, err := store.ReadProjects(filepath.Join(dataDir, "projects.json"), nil)
+	require.NoError(t, err)
+	paths := make(map[string]string)
+	for _, p := range projects {
+		paths[p.Slug] = p.Path
+	}
+	assert.Equal(t, map[string]string{
+		projectpath.Encode(decodable): decodable,
+		"-somewhere-else":             "/somewhere/else",
+		"-old-place":                  filepath.Join(moved, "sub"),
+		"-gone":                       "/unknown/-gone",
+	}, paths)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, projectpath.AliasesFile), []byte(`{`), 0600))
+	_, err = Run(model.Config{DataRoot: dataDir, SourceDir: sourceDir})
+	assert.ErrorContains(t, err, "load project aliases")
+}
+
 func TestSyncIdempotent(t *testing.T) {
 	tmpDir := t.TempDir()
 	sourceDir := filepath.Join(tmpDir, "source")
@@ -126,6 +239,526 @@ func TestSyncIdempotent(t *testing.T) {
 	assert.Equal(t, 4, len(lines), "still 1 header + 3 data lines after re-sync")
 }
 
+func TestSyncStableSequence(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	setupTestFixtures(t, sourceDir)
+
+	cfg := model.Config{
+		DataRoot:  dataDir,
+		SourceDir: sourceDir,
+	}
+	eventsPath := filepath.Join(dataDir, "events.tsv")
+
+	result, err := Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.NewEvents)
+	assert.Equal(t, int64(3), result.LastSeq)
+
+	first, err := store.ReadEvents(eventsPath, nil)
+	require.NoError(t, err)
+	ids := make(map[string]int64)
+	for _, e := range first {
+		require.NotEmpty(t, e.ID)
+		ids[e.ID] = e.Seq
+	}
+	assert.Len(t, ids, 3, "IDs are unique")
+
+	// An older session appearing later sorts first by epoch but is still
+	// ingested after everything already seen.
+	late := `{"type":"assistant","message":{"id":"msg_late","role":"assistant","content":"ok","usage":{"input_tokens":1,"output_tokens":1,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-14T09:00:00.000Z"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "-Users-test-my-project", "session-000.jsonl"), []byte(late), 0644))
+
+	result, err = Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.NewEvents)
+	assert.Equal(t, int64(4), result.LastSeq)
+
+	second, err := store.ReadEvents(eventsPath, nil)
+	require.NoError(t, err)
+	require.Len(t, second, 4)
+	assert.Equal(t, "session-000", second[0].SessionID)
+	assert.Equal(t, int64(4), second[0].Seq)
+	for _, e := range second[1:] {
+		assert.Equal(t, ids[e.ID], e.Seq, "existing events keep their seq")
+	}
+
+	// Dropping a session's events never lets its sequence numbers be handed
+	// out again.
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "-Users-test-my-project", "session-000.jsonl"), nil, 0644))
+	_, err = Run(cfg)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "-Users-test-my-project", "session-000.jsonl"), []byte(late), 0644))
+	result, err = Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.LastSeq)
+}
+
+func TestSyncProjectRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	setupTestFixtures(t, sourceDir)
+	scratch := filepath.Join(sourceDir, "-tmp-scratch")
+	require.NoError(t, os.MkdirAll(scratch, 0755))
+	session := `{"cwd":"/tmp/scratch","type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":5,"output_tokens":5,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-15T12:00:00.000Z"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(scratch, "session-x.jsonl"), []byte(session), 0644))
+
+	cfg := model.Config{
+		DataRoot:  dataDir,
+		SourceDir: sourceDir,
+		Projects:  model.ProjectRules{Exclude: []string{"/tmp/**"}},
+	}
+	result, err := Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.SessionFiles, "excluded sessions are not parsed")
+	assert.Equal(t, 3, result.EventRows)
+	assert.Equal(t, 1, result.ProjectsExcluded)
+
+	projects, err := store.ReadProjects(filepath.Join(dataDir, "projects.json"), nil)
+	require.NoError(t, err)
+	require.Len(t, projects, 1)
+	assert.Equal(t, "-Users-test-my-project", projects[0].Slug)
+
+	decisions, err := ProjectDecisions(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []ProjectDecision{
+		{Slug: "-Users-test-my-project", Path: "/Users/test/my-project", Included: true},
+		{Slug: "-tmp-scratch", Path: "/tmp/scratch", Reason: `matches exclude "/tmp/**"`},
+	}, decisions)
+
+	// Only tracking the scratch project drops the other one from the store.
+	cfg.Projects = model.ProjectRules{Include: []string{"-tmp-*"}}
+	result, err = Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.EventRows)
+}
+
+func TestSyncAttributesAccounts(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	home := filepath.Join(tmpDir, "home")
+	require.NoError(t, os.MkdirAll(home, 0755))
+	t.Setenv("HOME", home)
+
+	setupTestFixtures(t, sourceDir)
+	cfg := model.Config{DataRoot: dataDir, SourceDir: sourceDir}
+	eventsPath := filepath.Join(dataDir, "events.tsv")
+	login := func(uuid, org, email string) {
+		t.Helper()
+		doc := fmt.Sprintf(`{"oauthAccount":{"accountUuid":%q,"organizationUuid":%q,"emailAddress":%q}}`, uuid, org, email)
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".claude.json"), []byte(doc), 0644))
+	}
+
+	login("acct-a", "org-1", "a@example.com")
+	_, err := Run(cfg)
+	require.NoError(t, err)
+
+	events, err := store.ReadEvents(eventsPath, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	for _, e := range events {
+		assert.Equal(t, "acct-a", e.Account, "usage before the history starts goes to the first account")
+		assert.Equal(t, "org-1", e.Org)
+	}
+
+	// After switching accounts, existing events keep their owner and new
+	// usage goes to the account active when it happened.
+	login("acct-b", "org-2", "b@example.com")
+	ts := time.Now().Add(time.Hour).UTC().Format("2006-01-02T15:04:05.000Z")
+	fresh := `{"type":"assistant","message":{"id":"msg_fresh","role":"assistant","content":"ok","usage":{"input_tokens":1,"output_tokens":1,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"` + ts + `"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "-Users-test-my-project", "session-003.jsonl"), []byte(fresh), 0644))
+	_, err = Run(cfg)
+	require.NoError(t, err)
+
+	events, err = store.ReadEvents(eventsPath, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	owners := map[string]string{}
+	for _, e := range events {
+		owners[e.SessionID] = e.Account + "/" + e.Org
+	}
+	assert.Equal(t, map[string]string{
+		"session-001": "acct-a/org-1",
+		"session-002": "acct-a/org-1",
+		"session-003": "acct-b/org-2",
+	}, owners)
+
+	history, err := store.ReadAccounts(filepath.Join(dataDir, store.AccountsFile), nil)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "a@example.com", history[0].Email)
+	assert.Equal(t, "acct-b", history[1].AccountUUID)
+}
+
+func TestDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	projectDir := filepath.Join(sourceDir, "-Users-test-my-project")
+
+	setupTestFixtures(t, sourceDir)
+	cfg := model.Config{DataRoot: dataDir, SourceDir: sourceDir}
+
+	// Nothing synced yet: every row is new and the data root is left alone.
+	d, err := DryRun(cfg, Options{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, d.Added)
+	assert.Equal(t, int64(900), d.Tokens.Billable)
+	require.Len(t, d.Groups, 2)
+	assert.Equal(t, "session-001", d.Groups[0].Session)
+	assert.Equal(t, 2, d.Groups[0].Added)
+	assert.Len(t, d.Rows, 3)
+	_, err = os.Stat(dataDir)
+	assert.True(t, os.IsNotExist(err), "dry run must not create the data root")
+
+	_, err = Run(cfg)
+	require.NoError(t, err)
+	before, err := os.ReadFile(filepath.Join(dataDir, "events.tsv"))
+	require.NoError(t, err)
+
+	d, err = DryRun(cfg, Options{}, true)
+	require.NoError(t, err)
+	assert.True(t, d.Empty())
+	assert.Equal(t, 3, d.Unchanged)
+	assert.Empty(t, d.Groups)
+	assert.Empty(t, d.Rows)
+
+	// One session added, one emptied and one row whose content type changed.
+	late := `{"type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":1,"output_tokens":2,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-14T09:00:00.000Z"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "session-000.jsonl"), []byte(late), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "session-002.jsonl"), nil, 0644))
+	s1, err := os.ReadFile(filepath.Join(projectDir, "session-001.jsonl"))
+	require.NoError(t, err)
+	s1 = []byte(strings.Replace(string(s1), `[{"type":"text","text":"Hi!"}]`, `[{"type":"tool_use","text":"Hi!"}]`, 1))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "session-001.jsonl"), s1, 0644))
+
+	d, err = DryRun(cfg, Options{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, d.Added)
+	assert.Equal(t, 1, d.Removed)
+	assert.Equal(t, 1, d.Changed)
+	assert.Equal(t, 1, d.Unchanged)
+	assert.Equal(t, int64(3-400), d.Tokens.Billable)
+
+	byOp := make(map[string]DiffRow)
+	for _, r := range d.Rows {
+		byOp[r.Op] = r
+	}
+	require.NotNil(t, byOp[OpAdded].New)
+	assert.Equal(t, "session-000", byOp[OpAdded].New.SessionID)
+	require.NotNil(t, byOp[OpRemoved].Old)
+	assert.Equal(t, "session-002", byOp[OpRemoved].Old.SessionID)
+	require.NotNil(t, byOp[OpChanged].Old)
+	assert.Equal(t, "text", byOp[OpChanged].Old.ContentType)
+	assert.Equal(t, "tool_use", byOp[OpChanged].New.ContentType)
+
+	groups := make(map[string]DiffGroup)
+	for _, g := range d.Groups {
+		groups[g.Session] = g
+	}
+	assert.Equal(t, DiffGroup{Project: "-Users-test-my-project", Session: "session-000", Added: 1,
+		Tokens: TokenDelta{Input: 1, Output: 2, Billable: 3, TotalWithCache: 3}}, groups["session-000"])
+	assert.Equal(t, 1, groups["session-001"].Changed)
+	assert.Equal(t, TokenDelta{}, groups["session-001"].Tokens)
+	assert.Equal(t, int64(-300), groups["session-002"].Tokens.Input)
+
+	after, err := os.ReadFile(filepath.Join(dataDir, "events.tsv"))
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "dry run must not rewrite events.tsv")
+}
+
+func TestSyncParallelMatchesSequential(t *testing.T) {
+	sourceDir := filepath.Join(t.TempDir(), "source")
+	setupTestFixtures(t, sourceDir)
+
+	// Spread many small sessions over several projects, with timestamps that
+	// collide across files so merge order matters.
+	for p := 0; p < 4; p++ {
+		projectDir := filepath.Join(sourceDir, fmt.Sprintf("-Users-test-proj-%d", p))
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		for s := 0; s < 25; s++ {
+			var b strings.Builder
+			fmt.Fprintf(&b, `{"cwd":"/Users/test/proj-%d","type":"user","message":{"role":"user","content":"go"},"timestamp":"2025-01-15T10:00:00.000Z"}`+"\n", p)
+			for i := 0; i < 5; i++ {
+				fmt.Fprintf(&b, `{"type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":%d,"output_tokens":%d,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-15T10:0%d:00.000Z"}`+"\n", 10+i, s, i)
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(projectDir, fmt.Sprintf("s-%02d.jsonl", s)), []byte(b.String()), 0644))
+		}
+	}
+
+	read := func(workers int) (map[string]string, *Result) {
+		dataDir := t.TempDir()
+		result, err := Run(model.Config{DataRoot: dataDir, SourceDir: sourceDir, Workers: workers})
+		require.NoError(t, err)
+		files := make(map[string]string)
+		for _, name := range []string{"events.tsv", "events-ext.tsv", "live-events.tsv", "projects.json"} {
+			data, err := os.ReadFile(filepath.Join(dataDir, name))
+			require.NoError(t, err)
+			files[name] = string(data)
+		}
+		return files, result
+	}
+
+	sequential, seqResult := read(1)
+	parallel, parResult := read(8)
+	assert.Equal(t, sequential, parallel, "parallel sync output must match sequential")
+	assert.Equal(t, 1, seqResult.Workers)
+	assert.Equal(t, 8, parResult.Workers)
+	assert.Equal(t, 102, parResult.SessionFiles)
+	assert.Equal(t, seqResult.EventRows, parResult.EventRows)
+}
+
+func TestSyncCachedReparsesOnlyChangedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	setupTestFixtures(t, sourceDir)
+
+	cfg := model.Config{DataRoot: dataDir, SourceDir: sourceDir}
+	cache := NewCache()
+
+	result, err := RunWith(cfg, Options{Cache: cache})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.FilesParsed)
+	assert.Equal(t, 3, result.EventRows)
+
+	result, err = RunWith(cfg, Options{Cache: cache})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.FilesParsed, "nothing changed")
+	assert.Equal(t, 3, result.EventRows)
+
+	session := filepath.Join(sourceDir, "-Users-test-my-project", "session-002.jsonl")
+	f, err := os.OpenFile(session, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"type":"assistant","message":{"role":"assistant","content":"more","usage":{"input_tokens":5,"output_tokens":5,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-15T11:05:00.000Z"}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	result, err = RunWith(cfg, Options{Cache: cache})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.FilesParsed, "only the appended file is re-parsed")
+	assert.Equal(t, 4, result.EventRows)
+
+	require.NoError(t, os.Remove(session))
+	result, err = RunWith(cfg, Options{Cache: cache})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.FilesParsed)
+	assert.Len(t, cache.files, 1, "removed files drop out of the cache")
+	assert.Equal(t, 4, result.EventRows, "the removed session's events stay in the store")
+}
+
+func TestSyncKeepsEventsOfRemovedSessions(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	setupTestFixtures(t, sourceDir)
+	cfg := model.Config{DataRoot: dataDir, SourceDir: sourceDir}
+	eventsPath := filepath.Join(dataDir, "events.tsv")
+
+	_, err := Run(cfg)
+	require.NoError(t, err)
+	before, err := store.ReadEvents(eventsPath, nil)
+	require.NoError(t, err)
+
+	// Claude Code prunes old transcripts; the store is then the only copy.
+	require.NoError(t, os.RemoveAll(filepath.Join(sourceDir, "-Users-test-my-project")))
+	result, err := Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.SessionFiles)
+	assert.Equal(t, 3, result.EventRows)
+	assert.Equal(t, 0, result.NewEvents)
+
+	after, err := store.ReadEvents(eventsPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "events keep their seq and account")
+
+	projects, err := store.ReadProjects(filepath.Join(dataDir, "projects.json"), nil)
+	require.NoError(t, err)
+	require.Len(t, projects, 1)
+	assert.Equal(t, "/Users/test/my-project", projects[0].Path)
+
+	d, err := DryRun(cfg, Options{}, false)
+	require.NoError(t, err)
+	assert.True(t, d.Empty(), "dry run agrees that nothing would be removed")
+
+	// Excluding the project is how its events leave the store.
+	cfg.Projects = model.ProjectRules{Exclude: []string{"/Users/test/**"}}
+	result, err = Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.EventRows)
+}
+
+func TestSyncLocking(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	setupTestFixtures(t, sourceDir)
+	cfg := model.Config{DataRoot: dataDir, SourceDir: sourceDir}
+
+	held, err := store.Lock(dataDir, false)
+	require.NoError(t, err)
+
+	_, err = Run(cfg)
+	require.ErrorIs(t, err, store.ErrLocked)
+	_, statErr := os.Stat(filepath.Join(dataDir, "events.tsv"))
+	assert.True(t, os.IsNotExist(statErr), "nothing is written without the lock")
+
+	done := make(chan error)
+	go func() {
+		_, err := RunWith(cfg, Options{Wait: true})
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, held.Unlock())
+	require.NoError(t, <-done)
+
+	// Concurrent runs serialize instead of interleaving their writes.
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := RunWith(cfg, Options{Wait: true})
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		require.NoError(t, <-errs)
+	}
+	eventsData, err := os.ReadFile(filepath.Join(dataDir, "events.tsv"))
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(eventsData)), "\n"), 4)
+	leftovers, _ := filepath.Glob(filepath.Join(dataDir, "*.tmp"))
+	assert.Empty(t, leftovers)
+}
+
+func TestSyncRunsHooksForNewEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	out := filepath.Join(tmpDir, "hook.json")
+	setupTestFixtures(t, sourceDir)
+
+	cfg := model.Config{
+		DataRoot:  dataDir,
+		SourceDir: sourceDir,
+		Hooks: []model.HookConfig{
+			{Name: "capture", Command: "cat > " + out},
+			{Name: "broken", Command: "exit 2"},
+		},
+	}
+
+	// The first sync ingests existing history, which is not sent to hooks.
+	result, err := Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.NewEvents)
+	assert.Empty(t, result.Hooks)
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+
+	later := `{"type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":1,"output_tokens":1,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-16T09:00:00.000Z"}
+{"type":"assistant","message":{"role":"assistant","content":"ok","usage":{"input_tokens":2,"output_tokens":2,"cache_read_input_tokens":0,"cache_creation_input_tokens":0}},"timestamp":"2025-01-16T09:01:00.000Z"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "-Users-test-my-project", "session-003.jsonl"), []byte(later), 0644))
+
+	// Hooks run without the DataRoot lock: while this one waits, the lock
+	// can be taken by someone else.
+	started, release := filepath.Join(tmpDir, "started"), filepath.Join(tmpDir, "release")
+	cfg.Hooks = append(cfg.Hooks, model.HookConfig{Name: "slow", Timeout: 10,
+		Command: fmt.Sprintf("touch %s; while [ ! -e %s ]; do sleep 0.01; done", started, release)})
+	lockErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := os.Stat(started); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		l, err := store.Lock(dataDir, false)
+		if err == nil {
+			err = l.Unlock()
+		}
+		lockErr <- err
+		os.WriteFile(release, nil, 0644)
+	}()
+	result, err = Run(cfg)
+	require.NoError(t, err, "a failing hook does not fail the sync")
+	require.NoError(t, <-lockErr, "the lock is free while hooks run")
+	require.Len(t, result.Hooks, 3)
+	assert.True(t, result.Hooks[0].OK)
+	assert.False(t, result.Hooks[1].OK)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var payload struct {
+		Count   int                `json:"count"`
+		LastSeq int64              `json:"last_seq"`
+		Events  []model.TokenEvent `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(data, &payload))
+	assert.Equal(t, 2, payload.Count)
+	assert.Equal(t, int64(5), payload.LastSeq)
+	for i, e := range payload.Events {
+		assert.Equal(t, int64(i+4), e.Seq, "events are in ingest order")
+	}
+
+	statusData, err := os.ReadFile(filepath.Join(dataDir, store.SyncStatusFile))
+	require.NoError(t, err)
+	var status struct {
+		Hooks []struct {
+			Name  string `json:"name"`
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		} `json:"hooks"`
+	}
+	require.NoError(t, json.Unmarshal(statusData, &status))
+	require.Len(t, status.Hooks, 3, "results are recorded once the hooks finish")
+	assert.Equal(t, "broken", status.Hooks[1].Name)
+	assert.NotEmpty(t, status.Hooks[1].Error)
+
+	// Nothing new: hooks are not called again.
+	require.NoError(t, os.Remove(out))
+	result, err = Run(cfg)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hooks)
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSyncStatusPhases(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	setupTestFixtures(t, sourceDir)
+
+	result, err := Run(model.Config{DataRoot: dataDir, SourceDir: sourceDir})
+	require.NoError(t, err)
+	assert.Positive(t, result.Workers, "0 resolves to one worker per CPU")
+	assert.Positive(t, result.Phases.Parse)
+	assert.GreaterOrEqual(t, result.Duration, result.Phases.Parse+result.Phases.Write)
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "sync-status.json"))
+	require.NoError(t, err)
+	var status struct {
+		Workers    int              `json:"workers"`
+		DurationMS *int64           `json:"duration_ms"`
+		PhasesMS   map[string]int64 `json:"phases_ms"`
+	}
+	require.NoError(t, json.Unmarshal(data, &status))
+	assert.Equal(t, result.Workers, status.Workers)
+	assert.NotNil(t, status.DurationMS)
+	for _, phase := range []string{"discover", "parse", "sort", "dedup", "write"} {
+		assert.Contains(t, status.PhasesMS, phase)
+	}
+}
+
 // C13: Account JSON generation tests
 func TestWriteAccountJSON(t *testing.T) {
 	tests := []struct {
@@ -179,7 +812,7 @@ func TestWriteAccountJSON(t *testing.T) {
 				require.NoError(t, os.WriteFile(claudePath, []byte(tt.claudeJSON), 0644))
 			}
 
-			writeAccountJSONFrom(outPath, claudePath)
+			writeAccountJSONFrom(outPath, claudePath, nil)
 
 			data, err := os.ReadFile(outPath)
 			require.NoError(t, err)
@@ -222,6 +855,28 @@ func TestSyncEmptySource(t *testing.T) {
 	assert.Equal(t, 0, result.EventRows)
 }
 
+func TestSyncCountsParseErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	setupTestFixtures(t, sourceDir)
+	// A dangling symlink is discovered but can't be read.
+	projectDir := filepath.Join(sourceDir, "-Users-test-my-project")
+	require.NoError(t, os.Symlink(filepath.Join(tmpDir, "gone.jsonl"), filepath.Join(projectDir, "session-003.jsonl")))
+
+	result, err := Run(model.Config{DataRoot: dataDir, SourceDir: sourceDir})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.SessionFiles)
+	assert.Equal(t, 1, result.ParseErrors)
+	assert.Equal(t, 3, result.EventRows, "readable sessions are still synced")
+
+	data, err := os.ReadFile(filepath.Join(dataDir, store.SyncStatusFile))
+	require.NoError(t, err)
+	var status map[string]any
+	require.NoError(t, json.Unmarshal(data, &status))
+	assert.Equal(t, float64(1), status["parse_errors"])
+}
+
 // Issue 12: Non-.jsonl files are ignored by sync discovery
 func TestSyncIgnoresNonJSONL(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -239,3 +894,44 @@ func TestSyncIgnoresNonJSONL(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, result.SessionFiles, "non-.jsonl files should be ignored")
 }
+
+func TestSyncEncryptedDataRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	dataDir := filepath.Join(tmpDir, "data")
+	passFile := filepath.Join(tmpDir, "passphrase")
+
+	setupTestFixtures(t, sourceDir)
+	require.NoError(t, os.WriteFile(passFile, []byte("correct horse battery staple\n"), 0600))
+
+	cfg := model.Config{
+		DataRoot:  dataDir,
+		SourceDir: sourceDir,
+		Encryption: model.EncryptionConfig{
+			Mode:           model.EncryptionPassphraseFile,
+			PassphraseFile: passFile,
+		},
+	}
+
+	_, err := Run(cfg)
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(filepath.Join(dataDir, "events.tsv"))
+	require.NoError(t, err)
+	assert.True(t, vault.IsSealed(raw), "events.tsv should be written as ciphertext")
+	assert.NotContains(t, string(raw), "session-001")
+
+	v, err := vault.Load(cfg)
+	require.NoError(t, err)
+	plain, err := store.ReadFile(filepath.Join(dataDir, "events.tsv"), v)
+	require.NoError(t, err)
+	assert.Contains(t, string(plain), "session-001")
+
+	info, err := os.Stat(filepath.Join(dataDir, "events.tsv"))
+	require.NoError(t, err)
+	assert.Equal(t, store.FileMode, info.Mode().Perm())
+
+	info, err = os.Stat(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, store.DirMode, info.Mode().Perm())
+}
@@ -0,0 +1,42 @@
+//go:build darwin
+
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of security(1) when the keychain has
+// no matching item.
+const errSecItemNotFound = 44
+
+// osKeyringGet reads a generic password from the macOS login keychain. It
+// returns errKeyNotFound only when the keychain answered that there is no
+// such item; a locked keychain or a failing tool is a different error.
+func osKeyringGet(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.ExitCode() == errSecItemNotFound {
+				return "", errKeyNotFound
+			}
+			err = withOutput(err, exitErr.Stderr)
+		}
+		return "", fmt.Errorf("security find-generic-password: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// osKeyringSet adds a generic password to the macOS login keychain. It
+// never replaces an existing item.
+func osKeyringSet(service, account, secret string) error {
+	out, err := exec.Command("security", "add-generic-password", "-s", service, "-a", account, "-w", secret).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("security add-generic-password: %w", withOutput(err, out))
+	}
+	return nil
+}
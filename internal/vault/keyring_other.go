@@ -0,0 +1,40 @@
+//go:build !darwin
+
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// osKeyringGet reads a secret from the Secret Service via secret-tool. It
+// returns errKeyNotFound only when the lookup ran and found nothing (exit
+// status 1 without an error message); a missing secret-tool, a locked
+// collection or a D-Bus failure is a different error.
+func osKeyringGet(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.ExitCode() == 1 && len(strings.TrimSpace(string(exitErr.Stderr))) == 0 {
+				return "", errKeyNotFound
+			}
+			err = withOutput(err, exitErr.Stderr)
+		}
+		return "", fmt.Errorf("secret-tool lookup: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// osKeyringSet stores a secret in the Secret Service via secret-tool.
+func osKeyringSet(service, account, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=Jevons data key", "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("secret-tool store: %w", withOutput(err, out))
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// magic prefixes every sealed file so readers can detect ciphertext.
+var magic = []byte("JVNSENC1")
+
+// KeyInfoFile holds the KDF salt for passphrase-derived keys, stored in DataRoot.
+const KeyInfoFile = "vault.json"
+
+const (
+	keySize        = 32
+	kdfIterations  = 600000
+	keyringService = "jevons"
+)
+
+// ErrLocked is returned when sealed data is read without a key.
+var ErrLocked = errors.New("data is encrypted: configure encryption in config.json to read it")
+
+// Vault seals and opens data-root files with AES-256-GCM.
+// A nil *Vault is valid and passes plaintext through unchanged.
+type Vault struct {
+	aead cipher.AEAD
+}
+
+// New creates a Vault from a 32-byte key.
+func New(key []byte) (*Vault, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{aead: aead}, nil
+}
+
+// IsSealed reports whether data was produced by Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts plain. A nil Vault returns plain unchanged.
+func (v *Vault) Seal(plain []byte) ([]byte, error) {
+	if v == nil {
+		return plain, nil
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plain)+v.aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return v.aead.Seal(out, nonce, plain, magic), nil
+}
+
+// Open decrypts data produced by Seal. Plaintext input is returned unchanged,
+// so stores written before encryption was enabled stay readable.
+func (v *Vault) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if v == nil {
+		return nil, ErrLocked
+	}
+	rest := data[len(magic):]
+	if len(rest) < v.aead.NonceSize() {
+		return nil, errors.New("sealed data truncated")
+	}
+	nonce, ciphertext := rest[:v.aead.NonceSize()], rest[v.aead.NonceSize():]
+	plain, err := v.aead.Open(nil, nonce, ciphertext, magic)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*Vault)
+)
+
+// Load returns the Vault configured for cfg, or nil when encryption is off.
+// Keys are resolved once per process and cached.
+func Load(cfg model.Config) (*Vault, error) {
+	if cfg.Encryption.Mode == model.EncryptionOff {
+		return nil, nil
+	}
+
+	cacheKey := strings.Join([]string{cfg.Encryption.Mode, cfg.DataRoot, cfg.Encryption.PassphraseFile}, "\x00")
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if v, ok := cache[cacheKey]; ok {
+		return v, nil
+	}
+
+	var key []byte
+	var err error
+	switch cfg.Encryption.Mode {
+	case model.EncryptionKeyring:
+		key, err = keyringKey(cfg.DataRoot)
+	case model.EncryptionPassphraseFile:
+		key, err = passphraseKey(cfg.DataRoot, cfg.Encryption.PassphraseFile)
+	default:
+		err = fmt.Errorf("unknown encryption mode: %s", cfg.Encryption.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	cache[cacheKey] = v
+	return v, nil
+}
+
+// errKeyNotFound means the OS keyring definitely has no key for a data root.
+var errKeyNotFound = errors.New("no key in keyring")
+
+// The keyring is reached through these so tests can replace it.
+var (
+	keyringGet = osKeyringGet
+	keyringSet = osKeyringSet
+)
+
+// keyringKey fetches the data key for dataRoot from the OS keyring,
+// generating and storing a fresh one on first use. A fresh key is only made
+// when the keyring says there is none: any other failure (a locked keychain,
+// a missing tool, a D-Bus timeout) is returned, since replacing the key
+// would leave the existing ciphertext unreadable.
+func keyringKey(dataRoot string) ([]byte, error) {
+	account, err := filepath.Abs(dataRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := keyringGet(keyringService, account)
+	switch {
+	case err == nil && secret == "":
+		return nil, fmt.Errorf("keyring entry for %s is empty", account)
+	case err == nil:
+		key, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("decode keyring secret: %w", err)
+		}
+		return key, nil
+	case !errors.Is(err, errKeyNotFound):
+		return nil, fmt.Errorf("read key from keyring: %w", err)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := keyringSet(keyringService, account, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("store key in keyring: %w", err)
+	}
+	return key, nil
+}
+
+// withOutput adds a failed command's trimmed output to its error.
+func withOutput(err error, out []byte) error {
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+type keyInfo struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+}
+
+// passphraseKey derives the data key from the passphrase file using
+// PBKDF2-SHA256 and the salt in DataRoot/vault.json (created on first use).
+func passphraseKey(dataRoot, passphraseFile string) ([]byte, error) {
+	raw, err := os.ReadFile(passphraseFile)
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase file: %w", err)
+	}
+	passphrase := strings.TrimSpace(string(raw))
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase file %s is empty", passphraseFile)
+	}
+
+	info, err := loadKeyInfo(dataRoot)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := base64.StdEncoding.DecodeString(info.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("decode salt: %w", err)
+	}
+	return pbkdf2.Key(sha256.New, passphrase, salt, info.Iterations, keySize)
+}
+
+func loadKeyInfo(dataRoot string) (keyInfo, error) {
+	path := filepath.Join(dataRoot, KeyInfoFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var info keyInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			return keyInfo{}, fmt.Errorf("parse %s: %w", KeyInfoFile, err)
+		}
+		return info, nil
+	}
+	if !os.IsNotExist(err) {
+		return keyInfo{}, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return keyInfo{}, err
+	}
+	info := keyInfo{
+		KDF:        "pbkdf2-sha256",
+		Iterations: kdfIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+	}
+	if err := os.MkdirAll(dataRoot, 0700); err != nil {
+		return keyInfo{}, err
+	}
+	out, _ := json.MarshalIndent(info, "", "  ")
+	if err := os.WriteFile(path, append(out, '\n'), 0600); err != nil {
+		return keyInfo{}, err
+	}
+	return info, nil
+}
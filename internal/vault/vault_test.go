@@ -0,0 +1,192 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/giannimassi/jevons/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	v, err := New(bytes.Repeat([]byte{7}, keySize))
+	require.NoError(t, err)
+
+	plain := []byte("ts_epoch\tts_iso\n1\t2025-01-01T00:00:00Z\n")
+	sealed, err := v.Seal(plain)
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "ts_epoch")
+
+	got, err := v.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plain, got)
+}
+
+func TestOpenCases(t *testing.T) {
+	v, err := New(bytes.Repeat([]byte{1}, keySize))
+	require.NoError(t, err)
+	other, err := New(bytes.Repeat([]byte{2}, keySize))
+	require.NoError(t, err)
+
+	sealed, err := v.Seal([]byte("secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		vault   *Vault
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{name: "plaintext passes through", vault: v, data: []byte("plain"), want: "plain"},
+		{name: "nil vault passes plaintext through", vault: nil, data: []byte("plain"), want: "plain"},
+		{name: "nil vault cannot read sealed data", vault: nil, data: sealed, wantErr: ErrLocked},
+		{name: "wrong key fails", vault: other, data: sealed, wantErr: assert.AnError},
+		{name: "truncated data fails", vault: v, data: sealed[:len(magic)+2], wantErr: assert.AnError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.vault.Open(tt.data)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				if tt.wantErr != assert.AnError {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestNilVaultSealIsPassthrough(t *testing.T) {
+	var v *Vault
+	out, err := v.Seal([]byte("plain"))
+	require.NoError(t, err)
+	assert.Equal(t, "plain", string(out))
+}
+
+func TestLoadOff(t *testing.T) {
+	v, err := Load(model.Config{DataRoot: t.TempDir()})
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestLoadPassphraseFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataRoot := filepath.Join(tmpDir, "data")
+	passFile := filepath.Join(tmpDir, "pass")
+	require.NoError(t, os.WriteFile(passFile, []byte("hunter2\n"), 0600))
+
+	cfg := model.Config{
+		DataRoot: dataRoot,
+		Encryption: model.EncryptionConfig{
+			Mode:           model.EncryptionPassphraseFile,
+			PassphraseFile: passFile,
+		},
+	}
+
+	v, err := Load(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, v)
+
+	info, err := os.Stat(filepath.Join(dataRoot, KeyInfoFile))
+	require.NoError(t, err, "salt file should be created on first use")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A fresh derivation with the same salt must open the same data.
+	sealed, err := v.Seal([]byte("payload"))
+	require.NoError(t, err)
+	key, err := passphraseKey(dataRoot, passFile)
+	require.NoError(t, err)
+	again, err := New(key)
+	require.NoError(t, err)
+	got, err := again.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(got))
+}
+
+func TestLoadPassphraseFileErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	empty := filepath.Join(tmpDir, "empty")
+	require.NoError(t, os.WriteFile(empty, []byte("  \n"), 0600))
+
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "missing file", file: filepath.Join(tmpDir, "missing")},
+		{name: "empty passphrase", file: empty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(model.Config{
+				DataRoot: filepath.Join(tmpDir, tt.name),
+				Encryption: model.EncryptionConfig{
+					Mode:           model.EncryptionPassphraseFile,
+					PassphraseFile: tt.file,
+				},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeyringKey(t *testing.T) {
+	stored := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, keySize))
+
+	tests := []struct {
+		name      string
+		secret    string
+		getErr    error
+		wantErr   string
+		wantKey   []byte
+		wantStore bool
+	}{
+		{name: "existing key", secret: stored, wantKey: bytes.Repeat([]byte{7}, keySize)},
+		{name: "no key yet creates one", getErr: errKeyNotFound, wantStore: true},
+		{name: "locked keychain is an error", getErr: errors.New("exit status 51"), wantErr: "read key from keyring"},
+		{name: "missing tool is an error", getErr: exec.ErrNotFound, wantErr: "read key from keyring"},
+		{name: "empty entry is an error", secret: "", wantErr: "is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var storedSecret string
+			origGet, origSet := keyringGet, keyringSet
+			t.Cleanup(func() { keyringGet, keyringSet = origGet, origSet })
+			keyringGet = func(service, account string) (string, error) { return tt.secret, tt.getErr }
+			keyringSet = func(service, account, secret string) error {
+				storedSecret = secret
+				return nil
+			}
+
+			key, err := keyringKey(t.TempDir())
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, storedSecret, "an existing key must never be replaced")
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, key, keySize)
+			if tt.wantKey != nil {
+				assert.Equal(t, tt.wantKey, key)
+			}
+			if tt.wantStore {
+				assert.Equal(t, base64.StdEncoding.EncodeToString(key), storedSecret)
+			} else {
+				assert.Empty(t, storedSecret)
+			}
+		})
+	}
+}
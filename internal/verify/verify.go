@@ -0,0 +1,356 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/giannimassi/jevons/internal/parser"
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/giannimassi/jevons/internal/vault"
+	"github.com/giannimassi/jevons/pkg/model"
+)
+
+// Check names used in Issue.Check and Report.Counts.
+const (
+	CheckMalformed      = "malformed"
+	CheckUnsorted       = "unsorted"
+	CheckDuplicate      = "duplicate"
+	CheckBillable       = "billable"
+	CheckTotalWithCache = "total_with_cache"
+	CheckZeroEpoch      = "zero_epoch"
+	CheckLiveOrphan     = "live_orphan"
+	CheckLiveMissing    = "live_missing"
+	CheckUnknownProject = "unknown_project"
+	CheckTempFile       = "temp_file"
+	CheckMissingFile    = "missing_file"
+	CheckExtMisaligned  = "ext_misaligned"
+)
+
+// maxIssuesPerCheck caps the detailed issues listed per check; Counts stays exact.
+const maxIssuesPerCheck = 50
+
+// Issue is a single integrity problem found in the data root.
+type Issue struct {
+	Check  string `json:"check"`
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Report is the machine-readable result of a verification run.
+type Report struct {
+	OK        bool           `json:"ok"`
+	DataRoot  string         `json:"data_root"`
+	EventRows int            `json:"event_rows"`
+	LiveRows  int            `json:"live_event_rows"`
+	Counts    map[string]int `json:"counts"`
+	Issues    []Issue        `json:"issues"`
+	Repaired  []string       `json:"repaired,omitempty"`
+}
+
+func (r *Report) add(check, file string, line int, format string, args ...any) {
+	r.Counts[check]++
+	if r.Counts[check] <= maxIssuesPerCheck {
+		r.Issues = append(r.Issues, Issue{Check: check, File: file, Line: line, Detail: fmt.Sprintf(format, args...)})
+	}
+}
+
+// row is a parsed data line together with its 1-based line number.
+type row struct {
+	line  int
+	raw   string
+	event model.LiveEvent
+}
+
+// Run checks the event stores in dataRoot without modifying anything.
+func Run(dataRoot string, v *vault.Vault) (*Report, error) {
+	report := &Report{DataRoot: dataRoot, Counts: make(map[string]int), Issues: []Issue{}}
+
+	events, err := readRows(filepath.Join(dataRoot, "events.tsv"), v, false, report)
+	if err != nil {
+		return nil, err
+	}
+	live, err := readRows(filepath.Join(dataRoot, "live-events.tsv"), v, true, report)
+	if err != nil {
+		return nil, err
+	}
+	report.EventRows = len(events)
+	report.LiveRows = len(live)
+
+	checkRows("events.tsv", events, report)
+	checkRows("live-events.tsv", live, report)
+	checkLiveConsistency(events, live, report)
+
+	projects, err := store.ReadProjects(filepath.Join(dataRoot, "projects.json"), v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read projects.json: %w", err)
+	}
+	if os.IsNotExist(err) && len(events) > 0 {
+		report.add(CheckMissingFile, "projects.json", 0, "projects.json not found")
+	}
+	known := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		known[p.Slug] = true
+	}
+	reported := make(map[string]bool)
+	for _, r := range events {
+		slug := r.event.ProjectSlug
+		if !known[slug] && !reported[slug] {
+			reported[slug] = true
+			report.add(CheckUnknownProject, "events.tsv", r.line, "project slug %q not in projects.json", slug)
+		}
+	}
+
+	temps, _ := filepath.Glob(filepath.Join(dataRoot, "*.tmp"))
+	for _, tmp := range temps {
+		report.add(CheckTempFile, filepath.Base(tmp), 0, "leftover temp file from an interrupted write")
+	}
+
+	report.OK = len(report.Counts) == 0
+	return report, nil
+}
+
+// Repair rewrites the stores so they pass verification, then re-verifies.
+// Malformed rows are dropped, token sums recomputed, zero epochs re-derived
+// from ts_iso (or dropped), rows sorted and deduplicated, live rows aligned
+// with events.tsv, unknown slugs added to projects.json, and temp files removed.
+// It fails with store.ErrLocked while a sync is writing the stores.
+func Repair(dataRoot string, v *vault.Vault) (*Report, error) {
+	lock, err := store.Lock(dataRoot, false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	var repaired []string
+	discard := &Report{Counts: make(map[string]int)}
+
+	temps, _ := filepath.Glob(filepath.Join(dataRoot, "*.tmp"))
+	for _, tmp := range temps {
+		if err := os.Remove(tmp); err == nil {
+			repaired = append(repaired, filepath.Base(tmp))
+		}
+	}
+
+	eventRows, err := readRows(filepath.Join(dataRoot, "events.tsv"), v, false, discard)
+	if err != nil {
+		return nil, err
+	}
+	liveRows, err := readRows(filepath.Join(dataRoot, "live-events.tsv"), v, true, discard)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]model.TokenEvent, 0, len(eventRows))
+	for _, r := range eventRows {
+		if e, ok := fixEvent(r.event.TokenEvent); ok {
+			events = append(events, e)
+		}
+	}
+	store.SortEvents(events)
+	events = store.DedupEvents(events)
+
+	// Keep live rows that match an event, and give events without one a "-" preview.
+	eventKeys := make(map[string]bool, len(events))
+	for _, e := range events {
+		eventKeys[store.MarshalTokenEvent(e)] = true
+	}
+	liveKeys := make(map[string]bool, len(liveRows))
+	live := make([]model.LiveEvent, 0, len(liveRows))
+	for _, r := range liveRows {
+		e, ok := fixEvent(r.event.TokenEvent)
+		if !ok {
+			continue
+		}
+		key := store.MarshalTokenEvent(e)
+		if !eventKeys[key] {
+			continue
+		}
+		liveKeys[key] = true
+		live = append(live, model.LiveEvent{TokenEvent: e, PromptPreview: r.event.PromptPreview})
+	}
+	for _, e := range events {
+		if !liveKeys[store.MarshalTokenEvent(e)] {
+			live = append(live, model.LiveEvent{TokenEvent: e, PromptPreview: "-"})
+		}
+	}
+	store.SortLiveEvents(live)
+	live = store.DedupLiveEvents(live)
+
+	if err := store.WriteEventsTSV(filepath.Join(dataRoot, "events.tsv"), events, v); err != nil {
+		return nil, fmt.Errorf("write events.tsv: %w", err)
+	}
+	repaired = append(repaired, "events.tsv")
+	if err := store.WriteLiveEventsTSV(filepath.Join(dataRoot, "live-events.tsv"), live, v); err != nil {
+		return nil, fmt.Errorf("write live-events.tsv: %w", err)
+	}
+	repaired = append(repaired, "live-events.tsv")
+
+	projectsPath := filepath.Join(dataRoot, "projects.json")
+	projects, err := store.ReadProjects(projectsPath, v)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read projects.json: %w", err)
+	}
+	known := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		known[p.Slug] = true
+	}
+	added := false
+	for _, e := range events {
+		if !known[e.ProjectSlug] {
+			known[e.ProjectSlug] = true
+			projects = append(projects, model.Project{Slug: e.ProjectSlug, Path: "/unknown/" + e.ProjectSlug})
+			added = true
+		}
+	}
+	if added || os.IsNotExist(err) {
+		sort.Slice(projects, func(i, j int) bool { return projects[i].Path < projects[j].Path })
+		if err := store.WriteProjects(projectsPath, projects, v); err != nil {
+			return nil, fmt.Errorf("write projects.json: %w", err)
+		}
+		repaired = append(repaired, "projects.json")
+	}
+
+	report, err := Run(dataRoot, v)
+	if err != nil {
+		return nil, err
+	}
+	report.Repaired = repaired
+	return report, nil
+}
+
+// fixEvent recomputes derived columns and re-derives a zero epoch from ts_iso.
+// It reports false when the epoch cannot be recovered.
+func fixEvent(e model.TokenEvent) (model.TokenEvent, bool) {
+	e.Billable = e.Input + e.Output
+	e.TotalWithCache = e.Billable + e.CacheRead + e.CacheCreate
+	if e.TSEpoch == 0 {
+		e.TSEpoch = parser.ParseEpoch(e.TSISO)
+	}
+	return e, e.TSEpoch != 0
+}
+
+func readRows(path string, v *vault.Vault, live bool, report *Report) ([]row, error) {
+	name := filepath.Base(path)
+	data, err := store.ReadFile(path, v)
+	if os.IsNotExist(err) {
+		report.add(CheckMissingFile, name, 0, "%s not found", name)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+
+	// events.tsv carries row-aligned extension columns in events-ext.tsv.
+	var extLines []string
+	var dec store.ExtDecoder
+	if !live {
+		ext, err := store.ReadFile(store.ExtPath(path), v)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read %s: %w", filepath.Base(store.ExtPath(path)), err)
+		}
+		if err == nil {
+			if bytes.Count(ext, []byte("\n")) != bytes.Count(data, []byte("\n")) {
+				report.add(CheckExtMisaligned, filepath.Base(store.ExtPath(path)), 0, "line count differs from %s", name)
+			}
+			extLines = strings.Split(string(ext), "\n")
+			dec = store.NewExtDecoder(extLines[0])
+		}
+	}
+
+	var rows []row
+	var mismatched, firstMismatch int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if lineNo == 1 {
+			continue // header
+		}
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var e model.LiveEvent
+		if live {
+			e, err = store.UnmarshalLiveEvent(line)
+		} else {
+			e.TokenEvent, err = store.UnmarshalTokenEvent(line)
+		}
+		if err != nil {
+			report.add(CheckMalformed, name, lineNo, "%v", err)
+			continue
+		}
+		if lineNo <= len(extLines) && !dec.Apply(extLines[lineNo-1], &e.TokenEvent) {
+			if mismatched++; firstMismatch == 0 {
+				firstMismatch = lineNo
+			}
+		}
+		if !live {
+			store.FillID(&e.TokenEvent)
+		}
+		rows = append(rows, row{line: lineNo, raw: line, event: e})
+	}
+	if mismatched > 0 {
+		report.add(CheckExtMisaligned, filepath.Base(store.ExtPath(path)), firstMismatch,
+			"%d lines were written for other rows of %s", mismatched, name)
+	}
+	return rows, scanner.Err()
+}
+
+func checkRows(name string, rows []row, report *Report) {
+	seen := make(map[string]int, len(rows))
+	var prevEpoch int64
+	for i, r := range rows {
+		e := r.event
+		if i > 0 && e.TSEpoch < prevEpoch {
+			report.add(CheckUnsorted, name, r.line, "epoch %d after %d", e.TSEpoch, prevEpoch)
+		}
+		prevEpoch = e.TSEpoch
+
+		if first, ok := seen[r.raw]; ok {
+			report.add(CheckDuplicate, name, r.line, "duplicate of line %d", first)
+		} else {
+			seen[r.raw] = r.line
+		}
+
+		if e.Billable != e.Input+e.Output {
+			report.add(CheckBillable, name, r.line, "billable %d != input+output %d", e.Billable, e.Input+e.Output)
+		}
+		if want := e.Input + e.Output + e.CacheRead + e.CacheCreate; e.TotalWithCache != want {
+			report.add(CheckTotalWithCache, name, r.line, "total_with_cache %d != sum of components %d", e.TotalWithCache, want)
+		}
+		if e.TSEpoch == 0 {
+			report.add(CheckZeroEpoch, name, r.line, "zero epoch (ts_iso %q)", e.TSISO)
+		}
+	}
+}
+
+// checkLiveConsistency requires live-events.tsv to hold exactly the events of
+// events.tsv, each with a prompt preview.
+func checkLiveConsistency(events, live []row, report *Report) {
+	eventKeys := make(map[string]bool, len(events))
+	for _, r := range events {
+		eventKeys[store.MarshalTokenEvent(r.event.TokenEvent)] = true
+	}
+	liveKeys := make(map[string]bool, len(live))
+	for _, r := range live {
+		key := store.MarshalTokenEvent(r.event.TokenEvent)
+		liveKeys[key] = true
+		if !eventKeys[key] {
+			report.add(CheckLiveOrphan, "live-events.tsv", r.line, "row has no matching event in events.tsv")
+		}
+	}
+	for _, r := range events {
+		if !liveKeys[store.MarshalTokenEvent(r.event.TokenEvent)] {
+			report.add(CheckLiveMissing, "events.tsv", r.line, "event has no matching row in live-events.tsv")
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package verify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giannimassi/jevons/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rowA = "1736935210\t2025-01-15T10:00:10Z\tproj\ts1\t100\t50\t20\t10\t150\t180\ttext\t100|50|20|10"
+	rowB = "1736935270\t2025-01-15T10:01:10Z\tproj\ts1\t200\t150\t40\t15\t350\t405\ttext\t200|150|40|15"
+
+	liveA = "1736935210\t2025-01-15T10:00:10Z\tproj\ts1\tHello\t100\t50\t20\t10\t150\t180\ttext\t100|50|20|10"
+	liveB = "1736935270\t2025-01-15T10:01:10Z\tproj\ts1\tWrite code\t200\t150\t40\t15\t350\t405\ttext\t200|150|40|15"
+
+	projectsJSON = `[{"slug":"proj","path":"/Users/test/proj"}]`
+)
+
+func writeStores(t *testing.T, events, live []string, projects string) string {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name, header string, rows []string) {
+		content := header + "\n"
+		for _, r := range rows {
+			content += r + "\n"
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	write("events.tsv", store.EventsTSVHeader, events)
+	write("live-events.tsv", store.LiveEventsTSVHeader, live)
+	if projects != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "projects.json"), []byte(projects), 0600))
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		events     []string
+		live       []string
+		projects   string
+		tmpFile    bool
+		ext        string
+		wantChecks []string
+	}{
+		{
+			name:     "clean stores",
+			events:   []string{rowA, rowB},
+			live:     []string{liveA, liveB},
+			projects: projectsJSON,
+		},
+		{
+			name:       "unsorted rows",
+			events:     []string{rowB, rowA},
+			live:       []string{liveB, liveA},
+			projects:   projectsJSON,
+			wantChecks: []string{CheckUnsorted},
+		},
+		{
+			name:       "duplicate rows",
+			events:     []string{rowA, rowA, rowB},
+			live:       []string{liveA, liveB},
+			projects:   projectsJSON,
+			wantChecks: []string{CheckDuplicate},
+		},
+		{
+			name:       "bad billable and total",
+			events:     []string{strings.Replace(rowA, "\t150\t180\t", "\t151\t181\t", 1), rowB},
+			live:       []string{liveA, liveB},
+			projects:   projectsJSON,
+			wantChecks: []string{CheckBillable, CheckTotalWithCache, CheckLiveOrphan, CheckLiveMissing},
+		},
+		{
+			name:       "zero epoch",
+			events:     []string{"0\t2025-01-15T10:00:10Z\tproj\ts1\t1\t1\t0\t0\t2\t2\ttext\t1|1|0|0", rowA, rowB},
+			live:       []string{"0\t2025-01-15T10:00:10Z\tproj\ts1\t-\t1\t1\t0\t0\t2\t2\ttext\t1|1|0|0", liveA, liveB},
+			projects:   projectsJSON,
+			wantChecks: []string{CheckZeroEpoch},
+		},
+		{
+			name:       "malformed row",
+			events:     []string{rowA, "garbage", rowB},
+			live:       []string{liveA, liveB},
+			projects:   projectsJSON,
+			wantChecks: []string{CheckMalformed},
+		},
+		{
+			name:       "live row without event",
+			events:     []string{rowA},
+			live:       []string{liveA, liveB},
+			projects:   projectsJSON,
+			wantChecks: []string{CheckLiveOrphan},
+		},
+		{
+			name:       "unknown project slug",
+			events:     []string{rowA, rowB},
+			live:       []string{liveA, liveB},
+			projects:   `[{"slug":"other","path":"/other"}]`,
+			wantChecks: []string{CheckUnknownProject},
+		},
+		{
+			name:       "leftover temp file",
+			events:     []string{rowA, rowB},
+			live:       []string{liveA, liveB},
+			projects:   projectsJSON,
+			tmpFile:    true,
+			wantChecks: []string{CheckTempFile},
+		},
+		{
+			name:     "aligned ext sidecar",
+			events:   []string{rowA, rowB},
+			live:     []string{liveA, liveB},
+			projects: projectsJSON,
+			ext:      "model\n-\nclaude-opus-4-1\n",
+		},
+		{
+			name:       "misaligned ext sidecar",
+			events:     []string{rowA, rowB},
+			live:       []string{liveA, liveB},
+			projects:   projectsJSON,
+			ext:        "model\nclaude-opus-4-1\n",
+			wantChecks: []string{CheckExtMisaligned},
+		},
+		{
+			name:       "ext sidecar of other rows",
+			events:     []string{rowA, rowB},
+			live:       []string{liveA, liveB},
+			projects:   projectsJSON,
+			ext:        "row_key\tmodel\n1736935210:100|50|20|10\t-\n1736935210:100|50|20|10\tclaude-opus-4-1\n",
+			wantChecks: []string{CheckExtMisaligned},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeStores(t, tt.events, tt.live, tt.projects)
+			if tt.tmpFile {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "events.tsv.tmp"), []byte("partial"), 0600))
+			}
+			if tt.ext != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "events-ext.tsv"), []byte(tt.ext), 0600))
+			}
+
+			report, err := Run(dir, nil)
+			require.NoError(t, err)
+
+			var got []string
+			for check := range report.Counts {
+				got = append(got, check)
+			}
+			assert.ElementsMatch(t, tt.wantChecks, got)
+			assert.Equal(t, len(tt.wantChecks) == 0, report.OK)
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	badSum := strings.Replace(rowB, "\t350\t405\t", "\t1\t2\t", 1)
+	dir := writeStores(t,
+		[]string{badSum, rowA, rowA, "garbage", "0\tnot-a-time\tproj\ts1\t1\t1\t0\t0\t2\t2\ttext\t1|1|0|0", "1736935300\t2025-01-15T10:01:40Z\tnew\ts2\t1\t1\t0\t0\t2\t2\ttext\t1|1|0|0"},
+		[]string{liveA, "1736935999\t2025-01-15T10:13:19Z\tproj\ts9\torphan\t1\t1\t0\t0\t2\t2\ttext\t1|1|0|0"},
+		projectsJSON,
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "live-events.tsv.tmp"), []byte("partial"), 0600))
+
+	before, err := Run(dir, nil)
+	require.NoError(t, err)
+	assert.False(t, before.OK)
+
+	report, err := Repair(dir, nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK, "issues after repair: %+v", report.Issues)
+	assert.Equal(t, 3, report.EventRows, "rowA, repaired rowB and the new-project row survive")
+	assert.Equal(t, 3, report.LiveRows)
+	assert.Contains(t, report.Repaired, "projects.json")
+	assert.Contains(t, report.Repaired, "live-events.tsv.tmp")
+
+	data, err := os.ReadFile(filepath.Join(dir, "events.tsv"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, rowA, lines[1])
+	assert.Equal(t, rowB, lines[2], "token sums should be recomputed")
+
+	projects, err := store.ReadProjects(filepath.Join(dir, "projects.json"), nil)
+	require.NoError(t, err)
+	assert.Len(t, projects, 2)
+}
+
+func TestRunMissingStores(t *testing.T) {
+	report, err := Run(t.TempDir(), nil)
+	require.NoError(t, err)
+	assert.False(t, report.OK)
+	assert.Equal(t, 2, report.Counts[CheckMissingFile])
+}
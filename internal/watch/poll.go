@@ -0,0 +1,78 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// poller detects changes by comparing the size and mtime of every session
+// file on each tick. It is far cheaper than a sync and works everywhere.
+type poller struct {
+	dir    string
+	notify func()
+	last   map[string]fileState
+	done   chan struct{}
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func newPoller(dir string, interval time.Duration, notify func()) (*poller, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	p := &poller{dir: dir, notify: notify, done: make(chan struct{})}
+	p.last = p.scan()
+	go p.run(interval)
+	return p, nil
+}
+
+func (p *poller) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			cur := p.scan()
+			if changed(p.last, cur) {
+				p.notify()
+			}
+			p.last = cur
+		}
+	}
+}
+
+func (p *poller) scan() map[string]fileState {
+	matches, _ := filepath.Glob(filepath.Join(p.dir, "*", "*.jsonl"))
+	states := make(map[string]fileState, len(matches))
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		states[m] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+	return states
+}
+
+func changed(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for path, s := range a {
+		if t, ok := b[path]; !ok || !s.modTime.Equal(t.modTime) || s.size != t.size {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *poller) Close() error {
+	close(p.done)
+	return nil
+}
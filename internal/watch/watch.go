@@ -0,0 +1,113 @@
+// Package watch reports changes to session files under a source directory,
+// so the sync daemon can run when transcripts change instead of on a timer.
+package watch
+
+import (
+	"strings"
+	"time"
+)
+
+// Debounce timings. Session files are appended to line by line while a
+// conversation streams, so bursts are coalesced into one notification.
+const (
+	DefaultDebounce = 500 * time.Millisecond
+	MaxDelay        = 5 * time.Second // Upper bound on delay during a continuous burst
+	PollInterval    = 2 * time.Second // Used where inotify and kqueue are unavailable
+)
+
+// Watcher delivers a value on C after session files change and have then
+// been quiet for the debounce period.
+type Watcher struct {
+	C <-chan struct{}
+
+	c        chan struct{}
+	raw      chan struct{}
+	done     chan struct{}
+	debounce time.Duration
+	backend  backend
+}
+
+// backend produces raw, undebounced change signals.
+type backend interface {
+	Close() error
+}
+
+// New watches sourceDir/<project>/*.jsonl. A debounce of 0 uses DefaultDebounce.
+func New(sourceDir string, debounce time.Duration) (*Watcher, error) {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	c := make(chan struct{}, 1)
+	w := &Watcher{
+		C:        c,
+		c:        c,
+		raw:      make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		debounce: debounce,
+	}
+	b, err := newBackend(sourceDir, w.signal)
+	if err != nil {
+		return nil, err
+	}
+	w.backend = b
+	go w.loop()
+	return w, nil
+}
+
+// Close stops watching. C is not closed.
+func (w *Watcher) Close() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+	close(w.done)
+	return w.backend.Close()
+}
+
+// signal records a raw change without blocking the backend.
+func (w *Watcher) signal() {
+	select {
+	case w.raw <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) loop() {
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	var first time.Time
+
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-w.raw:
+			now := time.Now()
+			if timer == nil {
+				first = now
+				timer = time.NewTimer(w.debounce)
+				timerC = timer.C
+				continue
+			}
+			// Keep waiting for quiet, but never past MaxDelay since the first change.
+			wait := min(w.debounce, MaxDelay-now.Sub(first))
+			timer.Stop()
+			timer.Reset(max(wait, 0))
+		case <-timerC:
+			timer, timerC = nil, nil
+			select {
+			case w.c <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// isSessionFile reports whether name is a session transcript.
+func isSessionFile(name string) bool {
+	return strings.HasSuffix(name, ".jsonl")
+}
@@ -0,0 +1,235 @@
+//go:build darwin
+
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	gone      = syscall.NOTE_DELETE | syscall.NOTE_RENAME | syscall.NOTE_REVOKE
+	dirFlags  = syscall.NOTE_WRITE | gone
+	fileFlags = syscall.NOTE_WRITE | syscall.NOTE_EXTEND | gone
+)
+
+// kqueue watches the source directory, every project directory and every
+// session file in them. kqueue reports changes per open descriptor, so an
+// append is only seen on the file itself; directory writes reveal new
+// projects and sessions.
+type kqueue struct {
+	kq     int
+	wake   [2]int // Close closes wake[1], which makes wake[0] readable
+	dir    string
+	notify func()
+	paths  map[string]int // watched path -> fd
+	fds    map[int]string
+}
+
+func newBackend(dir string, notify func()) (backend, error) {
+	k, err := newKqueue(dir, notify)
+	if err == nil {
+		return k, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	// Typically out of descriptors with a very large number of sessions.
+	return newPoller(dir, PollInterval, notify)
+}
+
+func newKqueue(dir string, notify func()) (*kqueue, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil, os.NewSyscallError("kqueue", err)
+	}
+	syscall.CloseOnExec(kq)
+	k := &kqueue{kq: kq, dir: dir, notify: notify, paths: map[string]int{}, fds: map[int]string{}}
+	if err := syscall.Pipe(k.wake[:]); err != nil {
+		syscall.Close(kq)
+		return nil, os.NewSyscallError("pipe", err)
+	}
+	syscall.CloseOnExec(k.wake[0])
+	syscall.CloseOnExec(k.wake[1])
+
+	fail := func(err error) (*kqueue, error) {
+		syscall.Close(k.wake[1])
+		k.closeAll()
+		return nil, err
+	}
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, k.wake[0], syscall.EVFILT_READ, syscall.EV_ADD)
+	if _, err := syscall.Kevent(kq, []syscall.Kevent_t{ev}, nil, nil); err != nil {
+		return fail(os.NewSyscallError("kevent", err))
+	}
+	if err := k.add(dir, dirFlags); err != nil {
+		return fail(err)
+	}
+	if _, err := k.scanRoot(); err != nil {
+		return fail(err)
+	}
+
+	go k.run()
+	return k, nil
+}
+
+// add watches path unless it is already watched.
+func (k *kqueue) add(path string, fflags uint32) error {
+	if _, ok := k.paths[path]; ok {
+		return nil
+	}
+	// O_EVTONLY keeps the descriptor from holding the volume busy.
+	fd, err := syscall.Open(path, syscall.O_EVTONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, fd, syscall.EVFILT_VNODE, syscall.EV_ADD|syscall.EV_CLEAR)
+	ev.Fflags = fflags
+	if _, err := syscall.Kevent(k.kq, []syscall.Kevent_t{ev}, nil, nil); err != nil {
+		syscall.Close(fd)
+		return os.NewSyscallError("kevent", err)
+	}
+	k.paths[path] = fd
+	k.fds[fd] = path
+	return nil
+}
+
+// remove drops the watch on path and on everything below it.
+func (k *kqueue) remove(path string) {
+	for p, fd := range k.paths {
+		if p == path || strings.HasPrefix(p, path+string(filepath.Separator)) {
+			syscall.Close(fd)
+			delete(k.paths, p)
+			delete(k.fds, fd)
+		}
+	}
+}
+
+// scanRoot watches project directories that are not watched yet and
+// reports whether it found any.
+func (k *kqueue) scanRoot() (bool, error) {
+	entries, err := os.ReadDir(k.dir)
+	if err != nil {
+		return false, err
+	}
+	added := false
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		project := filepath.Join(k.dir, e.Name())
+		if _, ok := k.paths[project]; ok {
+			continue
+		}
+		if err := k.add(project, dirFlags); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // Vanished again
+			}
+			return added, err
+		}
+		added = true
+		if _, err := k.scanProject(project); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// scanProject watches session files in project that are not watched yet
+// and reports whether it found any.
+func (k *kqueue) scanProject(project string) (bool, error) {
+	entries, err := os.ReadDir(project)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	added := false
+	for _, e := range entries {
+		if e.IsDir() || !isSessionFile(e.Name()) {
+			continue
+		}
+		path := filepath.Join(project, e.Name())
+		if _, ok := k.paths[path]; ok {
+			continue
+		}
+		if err := k.add(path, fileFlags); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return added, err
+		}
+		added = true
+	}
+	return added, nil
+}
+
+func (k *kqueue) run() {
+	defer k.closeAll()
+	events := make([]syscall.Kevent_t, 64)
+	for {
+		n, err := syscall.Kevent(k.kq, nil, events, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		// Drop vanished paths before rescanning, so a session file replaced
+		// by a rename is watched again under its new inode.
+		changed := false
+		var rescan []string
+		for _, ev := range events[:n] {
+			fd := int(ev.Ident)
+			if fd == k.wake[0] {
+				return // closed
+			}
+			path, ok := k.fds[fd]
+			if !ok {
+				continue
+			}
+			switch {
+			case ev.Fflags&gone != 0:
+				k.remove(path)
+				changed = true
+			case path == k.dir || filepath.Dir(path) == k.dir:
+				rescan = append(rescan, path)
+			default:
+				changed = true // Session file written or extended
+			}
+		}
+		// Errors here are running out of descriptors; the interval ticker
+		// still covers sessions that could not be watched.
+		for _, path := range rescan {
+			var added bool
+			if path == k.dir {
+				added, _ = k.scanRoot()
+			} else {
+				added, _ = k.scanProject(path)
+			}
+			changed = changed || added
+		}
+		if changed {
+			k.notify()
+		}
+	}
+}
+
+func (k *kqueue) closeAll() {
+	for fd := range k.fds {
+		syscall.Close(fd)
+	}
+	syscall.Close(k.wake[0])
+	syscall.Close(k.kq)
+}
+
+func (k *kqueue) Close() error {
+	return syscall.Close(k.wake[1])
+}
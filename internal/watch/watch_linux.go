@@ -0,0 +1,106 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	rootMask    = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_ONLYDIR
+	projectMask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_MOVED_TO |
+		syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_ONLYDIR
+)
+
+// inotify watches the source directory and every project directory in it.
+type inotify struct {
+	f      *os.File
+	fd     int
+	dir    string
+	rootWD int
+	notify func()
+}
+
+func newBackend(dir string, notify func()) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// A non-blocking fd goes through the runtime poller, so Close unblocks Read.
+	in := &inotify{f: os.NewFile(uintptr(fd), "inotify"), fd: fd, dir: dir, notify: notify}
+
+	in.rootWD, err = syscall.InotifyAddWatch(fd, dir, rootMask)
+	if err != nil {
+		in.f.Close()
+		return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		in.f.Close()
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			in.addProject(e.Name())
+		}
+	}
+
+	go in.run()
+	return in, nil
+}
+
+func (in *inotify) addProject(name string) {
+	// Errors mean the directory vanished again; the root watch covers that.
+	syscall.InotifyAddWatch(in.fd, filepath.Join(in.dir, name), projectMask)
+}
+
+func (in *inotify) run() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.f.Read(buf)
+		if err != nil {
+			return // closed
+		}
+		changed := false
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			name := string(nameBytes[:clen(nameBytes)])
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			switch {
+			case ev.Mask&syscall.IN_Q_OVERFLOW != 0:
+				changed = true
+			case int(ev.Wd) == in.rootWD:
+				if ev.Mask&syscall.IN_ISDIR != 0 {
+					if ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+						in.addProject(name)
+					}
+					changed = true
+				}
+			case isSessionFile(name):
+				changed = true
+			}
+		}
+		if changed {
+			in.notify()
+		}
+	}
+}
+
+func (in *inotify) Close() error {
+	return in.f.Close()
+}
+
+// clen returns the length of a NUL-padded name.
+func clen(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package watch
+
+func newBackend(dir string, notify func()) (backend, error) {
+	return newPoller(dir, PollInterval, notify)
+}
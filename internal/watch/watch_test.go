@@ -0,0 +1,131 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const debounce = 50 * time.Millisecond
+
+func waitSignal(t *testing.T, c <-chan struct{}, timeout time.Duration) bool {
+	t.Helper()
+	select {
+	case <-c:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestWatcherNotifiesOnSessionChanges(t *testing.T) {
+	src := t.TempDir()
+	project := filepath.Join(src, "-Users-test-proj")
+	require.NoError(t, os.MkdirAll(project, 0755))
+	session := filepath.Join(project, "s1.jsonl")
+	require.NoError(t, os.WriteFile(session, []byte("{}\n"), 0644))
+
+	w, err := New(src, debounce)
+	require.NoError(t, err)
+	defer w.Close()
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T)
+		want   bool
+	}{
+		{
+			name: "append to session",
+			change: func(t *testing.T) {
+				f, err := os.OpenFile(session, os.O_APPEND|os.O_WRONLY, 0644)
+				require.NoError(t, err)
+				_, err = f.WriteString("{}\n")
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+			},
+			want: true,
+		},
+		{
+			name: "new session file",
+			change: func(t *testing.T) {
+				require.NoError(t, os.WriteFile(filepath.Join(project, "s2.jsonl"), []byte("{}\n"), 0644))
+			},
+			want: true,
+		},
+		{
+			name: "new project directory",
+			change: func(t *testing.T) {
+				dir := filepath.Join(src, "-Users-test-new")
+				require.NoError(t, os.MkdirAll(dir, 0755))
+				require.True(t, waitSignal(t, w.C, 3*time.Second), "directory creation is a change")
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "s3.jsonl"), []byte("{}\n"), 0644))
+			},
+			want: true,
+		},
+		{
+			name: "unrelated file is ignored",
+			change: func(t *testing.T) {
+				require.NoError(t, os.WriteFile(filepath.Join(project, "notes.txt"), []byte("x"), 0644))
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change(t)
+			timeout := 3 * time.Second
+			if !tt.want {
+				timeout = 500 * time.Millisecond
+			}
+			assert.Equal(t, tt.want, waitSignal(t, w.C, timeout))
+		})
+	}
+}
+
+func TestWatcherDebouncesBursts(t *testing.T) {
+	src := t.TempDir()
+	project := filepath.Join(src, "proj")
+	require.NoError(t, os.MkdirAll(project, 0755))
+
+	w, err := New(src, 200*time.Millisecond)
+	require.NoError(t, err)
+	defer w.Close()
+
+	session := filepath.Join(project, "s.jsonl")
+	for i := 0; i < 20; i++ {
+		f, err := os.OpenFile(session, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString("{}\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	require.True(t, waitSignal(t, w.C, 3*time.Second))
+	assert.False(t, waitSignal(t, w.C, 500*time.Millisecond), "a burst yields one notification")
+}
+
+func TestWatcherMissingDir(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing"), debounce)
+	assert.Error(t, err)
+}
+
+func TestPoller(t *testing.T) {
+	src := t.TempDir()
+	project := filepath.Join(src, "proj")
+	require.NoError(t, os.MkdirAll(project, 0755))
+
+	notified := make(chan struct{}, 10)
+	p, err := newPoller(src, 20*time.Millisecond, func() { notified <- struct{}{} })
+	require.NoError(t, err)
+	defer p.Close()
+
+	assert.False(t, waitSignal(t, notified, 100*time.Millisecond), "no changes, no signal")
+	require.NoError(t, os.WriteFile(filepath.Join(project, "s.jsonl"), []byte("{}\n"), 0644))
+	assert.True(t, waitSignal(t, notified, time.Second))
+}
@@ -0,0 +1,68 @@
+package model
+
+import "strings"
+
+// AccountSnapshot records one Claude account/organization pair and when sync
+// saw it active in ~/.claude.json. Times are Unix epochs.
+type AccountSnapshot struct {
+	AccountUUID      string `json:"account_uuid"`
+	OrganizationUUID string `json:"organization_uuid"`
+	Email            string `json:"email"`
+	DisplayName      string `json:"display_name"`
+	BillingType      string `json:"billing_type"`
+	FirstSeen        int64  `json:"first_seen"`
+	LastSeen         int64  `json:"last_seen"`
+}
+
+func (s AccountSnapshot) sameAccount(o AccountSnapshot) bool {
+	return s.AccountUUID == o.AccountUUID && s.OrganizationUUID == o.OrganizationUUID
+}
+
+// AccountHistory lists account snapshots in the order they became active.
+// The same account appears again after switching away and back.
+type AccountHistory []AccountSnapshot
+
+// Observe records that current was active at now: it extends the latest
+// snapshot when the account and organization are unchanged, and starts a
+// new one otherwise. Snapshots without an account UUID are ignored.
+func (h AccountHistory) Observe(current AccountSnapshot, now int64) AccountHistory {
+	if current.AccountUUID == "" {
+		return h
+	}
+	if n := len(h); n > 0 && h[n-1].sameAccount(current) {
+		current.FirstSeen = h[n-1].FirstSeen
+		current.LastSeen = now
+		h[n-1] = current
+		return h
+	}
+	current.FirstSeen, current.LastSeen = now, now
+	return append(h, current)
+}
+
+// At returns the snapshot active at epoch: the latest one first seen at or
+// before it. Usage older than the whole history is credited to the first
+// snapshot, the best guess available. It returns nil for an empty history.
+func (h AccountHistory) At(epoch int64) *AccountSnapshot {
+	if len(h) == 0 {
+		return nil
+	}
+	active := &h[0]
+	for i := range h {
+		if h[i].FirstSeen > epoch {
+			break
+		}
+		active = &h[i]
+	}
+	return active
+}
+
+// ResolveAccount maps an email address to its account UUID. Anything else
+// (already a UUID, or an unknown email) is returned unchanged with ok false.
+func (h AccountHistory) ResolveAccount(s string) (uuid string, ok bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		if h[i].Email != "" && strings.EqualFold(h[i].Email, s) {
+			return h[i].AccountUUID, true
+		}
+	}
+	return s, false
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountHistoryObserve(t *testing.T) {
+	a := AccountSnapshot{AccountUUID: "acct-a", OrganizationUUID: "org-1", Email: "a@example.com"}
+	b := AccountSnapshot{AccountUUID: "acct-b", OrganizationUUID: "org-2", Email: "b@example.com"}
+
+	var h AccountHistory
+	h = h.Observe(a, 100)
+	h = h.Observe(a, 200)
+	require.Len(t, h, 1, "same account extends the snapshot")
+	assert.Equal(t, int64(100), h[0].FirstSeen)
+	assert.Equal(t, int64(200), h[0].LastSeen)
+
+	h = h.Observe(b, 300)
+	h = h.Observe(AccountSnapshot{}, 350)
+	h = h.Observe(a, 400)
+	require.Len(t, h, 3, "switching back starts a new snapshot; empty accounts are ignored")
+	assert.Equal(t, "acct-b", h[1].AccountUUID)
+	assert.Equal(t, int64(300), h[1].LastSeen)
+
+	other := a
+	other.OrganizationUUID = "org-3"
+	h = h.Observe(other, 500)
+	assert.Len(t, h, 4, "a different organization is a different snapshot")
+}
+
+func TestAccountHistoryAt(t *testing.T) {
+	h := AccountHistory{
+		{AccountUUID: "acct-a", FirstSeen: 100, LastSeen: 200},
+		{AccountUUID: "acct-b", FirstSeen: 300, LastSeen: 400},
+	}
+
+	tests := []struct {
+		name  string
+		epoch int64
+		want  string
+	}{
+		{name: "before history falls back to first", epoch: 50, want: "acct-a"},
+		{name: "within first", epoch: 150, want: "acct-a"},
+		{name: "gap keeps previous account", epoch: 250, want: "acct-a"},
+		{name: "switch point", epoch: 300, want: "acct-b"},
+		{name: "after history", epoch: 1000, want: "acct-b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.At(tt.epoch)
+			require.NotNil(t, got)
+			assert.Equal(t, tt.want, got.AccountUUID)
+		})
+	}
+
+	assert.Nil(t, AccountHistory{}.At(100))
+}
+
+func TestAccountHistoryResolveAccount(t *testing.T) {
+	h := AccountHistory{{AccountUUID: "acct-a", Email: "a@example.com"}}
+
+	uuid, ok := h.ResolveAccount("A@Example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "acct-a", uuid)
+
+	uuid, ok = h.ResolveAccount("acct-a")
+	assert.False(t, ok)
+	assert.Equal(t, "acct-a", uuid)
+}
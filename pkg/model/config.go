@@ -1,16 +1,92 @@
 package model
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigFileName is the optional JSON configuration file read from DataRoot.
+const ConfigFileName = "config.json"
+
+// Encryption modes for the data root.
+const (
+	EncryptionOff            = ""
+	EncryptionKeyring        = "keyring"
+	EncryptionPassphraseFile = "passphrase-file"
 )
 
 // Config holds runtime configuration for jevons.
 type Config struct {
-	DataRoot  string // Where events, dashboard, PIDs, and logs live
-	SourceDir string // Where AI session JSONL files are read from
-	Port      int    // HTTP server port
-	Interval  int    // Sync interval in seconds
+	DataRoot   string           `json:"-"`          // Where events, dashboard, PIDs, and logs live
+	SourceDir  string           `json:"source_dir"` // Where AI session JSONL files are read from
+	Port       int              `json:"port"`       // HTTP server port
+	Interval   int              `json:"interval"`   // Sync interval in seconds
+	Workers    int              `json:"workers"`    // Session files parsed concurrently; 0 means one per CPU
+	Watch      bool             `json:"watch"`      // Sync on source file changes, not just every Interval
+	Encryption EncryptionConfig `json:"encryption"` // At-rest encryption of the data root
+	Backup     BackupConfig     `json:"backup"`     // Scheduled snapshots of the data root
+	Hooks      []HookConfig     `json:"hooks"`      // Run after a sync ingests new events
+	Projects   ProjectRules     `json:"projects"`   // Which projects sync tracks
+	Retry      RetryConfig      `json:"retry"`      // How the daemon handles failing syncs
+	Log        LogConfig        `json:"log"`        // Structured log in DataRoot/logs/jevons.log
+	Metrics    MetricsConfig    `json:"metrics"`    // Prometheus /metrics of the dashboard server
+}
+
+// EncryptionConfig controls at-rest encryption of the event stores.
+type EncryptionConfig struct {
+	Mode           string `json:"mode"`            // "", "keyring", or "passphrase-file"
+	PassphraseFile string `json:"passphrase_file"` // Used when Mode is "passphrase-file"
+}
+
+// BackupConfig controls scheduled snapshots taken by the sync daemon.
+type BackupConfig struct {
+	Dir           string `json:"dir"`            // Defaults to DataRoot/backups
+	IntervalHours int    `json:"interval_hours"` // 0 disables scheduled snapshots
+	Keep          int    `json:"keep"`           // Number of snapshots retained by rotation
+}
+
+// RetryConfig controls how the sync daemon backs off after failed syncs.
+// Retries start one Interval after the first failure and double from there.
+type RetryConfig struct {
+	MaxBackoff      int `json:"max_backoff"`      // Cap on the retry wait in seconds; 0 disables backoff
+	BreakerFailures int `json:"breaker_failures"` // Pause syncing after this many consecutive failures; 0 disables
+}
+
+// Log formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogConfig controls the structured log written to DataRoot/logs/jevons.log.
+type LogConfig struct {
+	Level     string `json:"level"`       // "debug", "info", "warn", or "error"
+	Format    string `json:"format"`      // "text" or "json"
+	MaxSizeMB int    `json:"max_size_mb"` // Rotate once the file reaches this size; 0 disables rotation
+	Keep      int    `json:"keep"`        // Rotated files retained
+}
+
+// MetricsConfig controls the Prometheus metrics served on /metrics.
+type MetricsConfig struct {
+	// Projects, when set, lists the projects (globs on slug or path, as in
+	// ProjectRules) that get their own project label; the rest are summed
+	// under "other" to bound label cardinality.
+	Projects []string `json:"projects"`
+}
+
+// HookConfig is a post-sync hook: either a shell command that receives the
+// new events as JSON on stdin, or an HTTP webhook the same JSON is POSTed to.
+type HookConfig struct {
+	Name    string `json:"name"`    // Shown in sync-status.json; defaults to the command or URL
+	Command string `json:"command"` // Run with sh -c
+	URL     string `json:"url"`     // Webhook endpoint
+	Secret  string `json:"secret"`  // Webhook HMAC-SHA256 signing secret
+	Timeout int    `json:"timeout"` // Seconds per attempt; 0 means 10
+	Retries int    `json:"retries"` // Extra webhook attempts on failure
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -33,5 +109,166 @@ func DefaultConfig() Config {
 		SourceDir: sourceDir,
 		Port:      8765,
 		Interval:  15,
+		Backup: BackupConfig{
+			Keep: 7,
+		},
+		Retry: RetryConfig{
+			MaxBackoff: 300,
+		},
+		Log: LogConfig{
+			Level:     "info",
+			Format:    LogFormatText,
+			MaxSizeMB: 10,
+			Keep:      3,
+		},
+	}
+}
+
+// LoadConfig returns DefaultConfig overlaid with DataRoot/config.json, if present.
+// Environment variables still take precedence over the file.
+func LoadConfig() (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(filepath.Join(cfg.DataRoot, ConfigFileName))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	dataRoot := cfg.DataRoot
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return DefaultConfig(), fmt.Errorf("parse %s: %w", ConfigFileName, err)
+	}
+	cfg.DataRoot = dataRoot
+	if env := os.Getenv("CLAUDE_USAGE_SOURCE_DIR"); env != "" {
+		cfg.SourceDir = env
+	}
+
+	// The interval is also the daemon's first retry delay; 0 would retry a
+	// failing sync in a tight loop.
+	if cfg.Interval < 1 {
+		return DefaultConfig(), fmt.Errorf("interval must be a positive integer, got %d", cfg.Interval)
+	}
+
+	if cfg.Workers < 0 {
+		return DefaultConfig(), fmt.Errorf("workers must not be negative")
+	}
+
+	if cfg.Backup.IntervalHours < 0 || cfg.Backup.Keep < 0 {
+		return DefaultConfig(), fmt.Errorf("backup interval_hours and keep must not be negative")
+	}
+
+	if cfg.Retry.MaxBackoff < 0 || cfg.Retry.BreakerFailures < 0 {
+		return DefaultConfig(), fmt.Errorf("retry max_backoff and breaker_failures must not be negative")
+	}
+
+	switch strings.ToLower(cfg.Log.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		return DefaultConfig(), fmt.Errorf("unknown log level: %q", cfg.Log.Level)
+	}
+	if cfg.Log.Format != LogFormatText && cfg.Log.Format != LogFormatJSON {
+		return DefaultConfig(), fmt.Errorf("unknown log format: %q", cfg.Log.Format)
+	}
+	if cfg.Log.MaxSizeMB < 0 || cfg.Log.Keep < 0 {
+		return DefaultConfig(), fmt.Errorf("log max_size_mb and keep must not be negative")
+	}
+
+	for i, h := range cfg.Hooks {
+		if (h.Command == "") == (h.URL == "") {
+			return DefaultConfig(), fmt.Errorf("hooks[%d]: exactly one of command or url is required", i)
+		}
+		if h.Timeout < 0 || h.Retries < 0 {
+			return DefaultConfig(), fmt.Errorf("hooks[%d]: timeout and retries must not be negative", i)
+		}
+	}
+
+	if err := cfg.Projects.validate(); err != nil {
+		return DefaultConfig(), err
+	}
+	if err := (ProjectRules{Include: cfg.Metrics.Projects}).validate(); err != nil {
+		return DefaultConfig(), fmt.Errorf("metrics.%w", err)
+	}
+
+	switch cfg.Encryption.Mode {
+	case EncryptionOff, EncryptionKeyring:
+	case EncryptionPassphraseFile:
+		if cfg.Encryption.PassphraseFile == "" {
+			return DefaultConfig(), fmt.Errorf("encryption mode %q requires passphrase_file", cfg.Encryption.Mode)
+		}
+	default:
+		return DefaultConfig(), fmt.Errorf("unknown encryption mode: %s", cfg.Encryption.Mode)
+	}
+
+	return cfg, nil
+}
+
+// DiffConfig lists the settings that differ between old and new, one
+// "key: old -> new" line per leaf value in config.json notation (for example
+// "projects.exclude[0]"). Secrets are shown as "<redacted>".
+func DiffConfig(old, new Config) []string {
+	a, b := flattenConfig(old), flattenConfig(new)
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []string
+	for _, k := range keys {
+		va, okA := a[k]
+		vb, okB := b[k]
+		if okA && okB && va == vb {
+			continue
+		}
+		if !okA {
+			va = "(unset)"
+		}
+		if !okB {
+			vb = "(unset)"
+		}
+		if strings.HasSuffix(k, "secret") || strings.HasSuffix(k, "passphrase_file") {
+			va, vb = "<redacted>", "<redacted>"
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", k, va, vb))
+	}
+	return changes
+}
+
+// flattenConfig maps each leaf of cfg's JSON form to its JSON value.
+func flattenConfig(cfg Config) map[string]string {
+	data, _ := json.Marshal(cfg)
+	var v any
+	_ = json.Unmarshal(data, &v)
+	out := make(map[string]string)
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				walk(k, child)
+			}
+		case []any:
+			for i, child := range t {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+			}
+		case nil:
+			// Treated like an empty list: absent
+		default:
+			leaf, _ := json.Marshal(t)
+			out[prefix] = string(leaf)
+		}
 	}
+	walk("", v)
+	return out
 }
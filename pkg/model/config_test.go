@@ -0,0 +1,197 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		check   func(t *testing.T, cfg Config)
+		wantErr bool
+	}{
+		{
+			name: "no config file uses defaults",
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 8765, cfg.Port)
+				assert.Equal(t, 15, cfg.Interval)
+				assert.Equal(t, EncryptionOff, cfg.Encryption.Mode)
+				assert.Equal(t, RetryConfig{MaxBackoff: 300}, cfg.Retry)
+				assert.Equal(t, LogConfig{Level: "info", Format: LogFormatText, MaxSizeMB: 10, Keep: 3}, cfg.Log)
+			},
+		},
+		{
+			name: "log settings",
+			file: `{"log": {"level": "debug", "format": "json", "max_size_mb": 1}}`,
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, LogConfig{Level: "debug", Format: LogFormatJSON, MaxSizeMB: 1, Keep: 3}, cfg.Log)
+			},
+		},
+		{
+			name: "metrics project allowlist",
+			file: `{"metrics": {"projects": ["/Users/me/code/**"]}}`,
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, []string{"/Users/me/code/**"}, cfg.Metrics.Projects)
+			},
+		},
+		{
+			name:    "empty metrics project pattern",
+			file:    `{"metrics": {"projects": [""]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "zero interval",
+			file:    `{"interval": 0}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative interval",
+			file:    `{"interval": -5}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown log level",
+			file:    `{"log": {"level": "verbose"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown log format",
+			file:    `{"log": {"format": "xml"}}`,
+			wantErr: true,
+		},
+		{
+			name: "retry policy",
+			file: `{"retry": {"max_backoff": 0, "breaker_failures": 5}}`,
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, RetryConfig{BreakerFailures: 5}, cfg.Retry)
+			},
+		},
+		{
+			name:    "negative breaker failures",
+			file:    `{"retry": {"breaker_failures": -1}}`,
+			wantErr: true,
+		},
+		{
+			name: "file overrides defaults",
+			file: `{"port": 9000, "interval": 30, "encryption": {"mode": "keyring"}}`,
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 9000, cfg.Port)
+				assert.Equal(t, 30, cfg.Interval)
+				assert.Equal(t, EncryptionKeyring, cfg.Encryption.Mode)
+			},
+		},
+		{
+			name: "hooks",
+			file: `{"hooks": [{"command": "cat"}, {"url": "https://example.com/hook", "secret": "s", "retries": 2}]}`,
+			check: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Hooks, 2)
+				assert.Equal(t, "cat", cfg.Hooks[0].Command)
+				assert.Equal(t, 2, cfg.Hooks[1].Retries)
+			},
+		},
+		{
+			name:    "hook with command and url",
+			file:    `{"hooks": [{"command": "cat", "url": "https://example.com"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "hook with neither command nor url",
+			file:    `{"hooks": [{"name": "empty"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			file:    `{not json`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown encryption mode",
+			file:    `{"encryption": {"mode": "rot13"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "passphrase mode without file",
+			file:    `{"encryption": {"mode": "passphrase-file"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataRoot := t.TempDir()
+			t.Setenv("CLAUDE_USAGE_DATA_DIR", dataRoot)
+			t.Setenv("CLAUDE_USAGE_SOURCE_DIR", "")
+			if tt.file != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dataRoot, ConfigFileName), []byte(tt.file), 0600))
+			}
+
+			cfg, err := LoadConfig()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, dataRoot, cfg.DataRoot)
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadConfigEnvSourceDirWins(t *testing.T) {
+	dataRoot := t.TempDir()
+	t.Setenv("CLAUDE_USAGE_DATA_DIR", dataRoot)
+	t.Setenv("CLAUDE_USAGE_SOURCE_DIR", "/from/env")
+	require.NoError(t, os.WriteFile(filepath.Join(dataRoot, ConfigFileName), []byte(`{"source_dir": "/from/file"}`), 0600))
+
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "/from/env", cfg.SourceDir)
+}
+
+func TestDiffConfig(t *testing.T) {
+	base := Config{
+		Interval: 15,
+		Projects: ProjectRules{Exclude: []string{"*-scratch"}},
+		Hooks:    []HookConfig{{URL: "https://example.com", Secret: "a"}},
+	}
+
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   []string
+	}{
+		{"no changes", func(cfg *Config) {}, nil},
+		{"scalar", func(cfg *Config) { cfg.Interval = 30 }, []string{"interval: 15 -> 30"}},
+		{
+			"list item added",
+			func(cfg *Config) { cfg.Projects.Exclude = []string{"*-scratch", "tmp"} },
+			[]string{`projects.exclude[1]: (unset) -> "tmp"`},
+		},
+		{
+			"list emptied",
+			func(cfg *Config) { cfg.Projects.Exclude = nil },
+			[]string{`projects.exclude[0]: "*-scratch" -> (unset)`},
+		},
+		{
+			"secret redacted",
+			func(cfg *Config) { cfg.Hooks = []HookConfig{{URL: "https://example.com", Secret: "b"}} },
+			[]string{"hooks[0].secret: <redacted> -> <redacted>"},
+		},
+		{"data root ignored", func(cfg *Config) { cfg.DataRoot = "/elsewhere" }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base
+			next.Projects.Exclude = append([]string(nil), base.Projects.Exclude...)
+			tt.change(&next)
+			assert.Equal(t, tt.want, DiffConfig(base, next))
+		})
+	}
+}
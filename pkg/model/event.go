@@ -1,9 +1,18 @@
 package model
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ProviderClaude identifies events parsed from Claude session logs.
+const ProviderClaude = "claude"
+
 // TokenEvent represents a single token usage event from an AI session log.
 // Fields match the TSV schema: ts_epoch, ts_iso, project_slug, session_id,
 // input, output, cache_read, cache_create, billable, total_with_cache,
-// content_type, signature.
+// content_type, signature. Fields after Signature are extension columns kept
+// in the row-aligned events-ext.tsv so events.tsv stays shell-compatible.
 type TokenEvent struct {
 	TSEpoch        int64  `json:"ts_epoch"`
 	TSISO          string `json:"ts_iso"`
@@ -17,6 +26,29 @@ type TokenEvent struct {
 	TotalWithCache int64  `json:"total_with_cache"`
 	ContentType    string `json:"content_type"`
 	Signature      string `json:"signature"`
+	Model          string `json:"model"`
+	ID             string `json:"id"`      // Deterministic, see EventID
+	Seq            int64  `json:"seq"`     // Ingest sequence number; 0 until assigned by sync
+	Account        string `json:"account"` // UUID of the Claude account active when the event happened
+	Org            string `json:"org"`     // UUID of that account's organization
+}
+
+// EventID derives a stable event ID from the provider, session and a key
+// identifying the message within it (see MessageKey).
+func EventID(provider, sessionID, messageKey string) string {
+	sum := sha256.Sum256([]byte(provider + "\x00" + sessionID + "\x00" + messageKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+// MessageKey identifies an assistant message within a session. The API
+// message ID is preferred; the signature is appended because one message can
+// be logged with growing usage. Rows without a message ID (older logs, or
+// rows re-read from events.tsv alone) fall back to the timestamp.
+func MessageKey(messageID, tsISO, signature string) string {
+	if messageID != "" {
+		return messageID + "|" + signature
+	}
+	return tsISO + "|" + signature
 }
 
 // LiveEvent extends TokenEvent with a prompt preview column.
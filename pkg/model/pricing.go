@@ -0,0 +1,48 @@
+package model
+
+import "strings"
+
+// Price is what a model charges, in USD per million tokens.
+type Price struct {
+	Input      float64
+	Output     float64
+	CacheRead  float64
+	CacheWrite float64
+}
+
+// prices maps model name fragments to list prices: the first fragment
+// contained in a model name wins. Older models come first; a bare family
+// name catches newer releases at the family's current price.
+var prices = []struct {
+	fragment string
+	price    Price
+}{
+	{"opus-4-1", Price{Input: 15, Output: 75, CacheRead: 1.50, CacheWrite: 18.75}},
+	{"opus-4-2025", Price{Input: 15, Output: 75, CacheRead: 1.50, CacheWrite: 18.75}},
+	{"3-opus", Price{Input: 15, Output: 75, CacheRead: 1.50, CacheWrite: 18.75}},
+	{"opus", Price{Input: 5, Output: 25, CacheRead: 0.50, CacheWrite: 6.25}},
+	{"sonnet", Price{Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75}},
+	{"3-5-haiku", Price{Input: 0.80, Output: 4, CacheRead: 0.08, CacheWrite: 1}},
+	{"3-haiku", Price{Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.30}},
+	{"haiku", Price{Input: 1, Output: 5, CacheRead: 0.10, CacheWrite: 1.25}},
+}
+
+// PriceFor returns the list price of the named model, and false for models
+// it doesn't know (including events synced before model names were kept).
+func PriceFor(modelName string) (Price, bool) {
+	name := strings.ToLower(modelName)
+	for _, p := range prices {
+		if strings.Contains(name, p.fragment) {
+			return p.price, true
+		}
+	}
+	return Price{}, false
+}
+
+// Cost estimates what e cost in USD at price p.
+func (p Price) Cost(e TokenEvent) float64 {
+	return (float64(e.Input)*p.Input +
+		float64(e.Output)*p.Output +
+		float64(e.CacheRead)*p.CacheRead +
+		float64(e.CacheCreate)*p.CacheWrite) / 1e6
+}
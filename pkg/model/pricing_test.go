@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceFor(t *testing.T) {
+	tests := []struct {
+		model  string
+		want   float64 // Input price
+		wantOK bool
+	}{
+		{"claude-opus-4-5-20251101", 5, true},
+		{"claude-opus-4-1-20250805", 15, true},
+		{"claude-opus-4-20250514", 15, true},
+		{"claude-3-opus-20240229", 15, true},
+		{"claude-opus-5", 5, true},
+		{"claude-sonnet-4-5-20250929", 3, true},
+		{"claude-3-7-sonnet-20250219", 3, true},
+		{"claude-haiku-4-5-20251001", 1, true},
+		{"claude-3-5-haiku-20241022", 0.80, true},
+		{"claude-3-haiku-20240307", 0.25, true},
+		{"", 0, false},
+		{"<synthetic>", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			p, ok := PriceFor(tt.model)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, p.Input)
+		})
+	}
+}
+
+func TestPriceCost(t *testing.T) {
+	p := Price{Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75}
+	e := TokenEvent{Input: 1000, Output: 2000, CacheRead: 1e6, CacheCreate: 10000}
+	// 0.003 + 0.03 + 0.3 + 0.0375
+	assert.InDelta(t, 0.3705, p.Cost(e), 1e-9)
+}
@@ -0,0 +1,26 @@
+package model
+
+// Project is one entry of projects.json, mapping a project slug to its path.
+type Project struct {
+	Slug string   `json:"slug"`
+	Path string   `json:"path"`
+	Git  *GitInfo `json:"git,omitempty"` // Nil when Path is not inside a git repository
+}
+
+// GitInfo describes the git repository a project directory belongs to.
+type GitInfo struct {
+	Repo          string `json:"repo"`                     // Main checkout root, shared by its worktrees and subdirectories
+	Root          string `json:"root"`                     // Top of the working tree containing the project path
+	RemoteURL     string `json:"remote_url,omitempty"`     // URL of origin, or of the first remote
+	DefaultBranch string `json:"default_branch,omitempty"` // Branch origin/HEAD points at, else main or master
+	Worktree      bool   `json:"worktree,omitempty"`       // Root is a linked worktree, not the main checkout
+}
+
+// RepoKey identifies the logical project p belongs to: its repository when
+// known, otherwise its own path.
+func (p Project) RepoKey() string {
+	if p.Git != nil && p.Git.Repo != "" {
+		return p.Git.Repo
+	}
+	return p.Path
+}
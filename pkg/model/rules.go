@@ -0,0 +1,104 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ProjectRules selects which projects sync tracks. Patterns are globs
+// matched against both the project slug and its path: "*" and "?" stop at
+// "/", "**" crosses directories, and a trailing "/**" also matches the
+// directory itself. With Include set, only matching projects are tracked;
+// Exclude always wins.
+type ProjectRules struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// Empty reports whether r tracks every project.
+func (r ProjectRules) Empty() bool {
+	return len(r.Include) == 0 && len(r.Exclude) == 0
+}
+
+// Match reports whether the project with slug and path is tracked and, when
+// it is not, why.
+func (r ProjectRules) Match(slug, path string) (bool, string) {
+	for _, p := range r.Exclude {
+		if globMatch(p, slug, path) {
+			return false, fmt.Sprintf("matches exclude %q", p)
+		}
+	}
+	if len(r.Include) == 0 {
+		return true, ""
+	}
+	for _, p := range r.Include {
+		if globMatch(p, slug, path) {
+			return true, ""
+		}
+	}
+	return false, "matches no include pattern"
+}
+
+func (r ProjectRules) validate() error {
+	for _, list := range [][]string{r.Include, r.Exclude} {
+		for _, p := range list {
+			if p == "" {
+				return fmt.Errorf("projects: empty pattern")
+			}
+			if _, err := compileGlob(p); err != nil {
+				return fmt.Errorf("projects: bad pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func globMatch(pattern string, names ...string) bool {
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return false
+	}
+	for _, n := range names {
+		if re.MatchString(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob translates a glob into an anchored regular expression.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			b.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
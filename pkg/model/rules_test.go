@@ -0,0 +1,67 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectRulesMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      ProjectRules
+		slug       string
+		path       string
+		want       bool
+		wantReason string
+	}{
+		{name: "no rules", rules: ProjectRules{}, slug: "-a", path: "/a", want: true},
+		{name: "exclude by path subtree", rules: ProjectRules{Exclude: []string{"/work/clients/**"}}, slug: "-work-clients-x", path: "/work/clients/x/y", wantReason: `matches exclude "/work/clients/**"`},
+		{name: "subtree includes the directory itself", rules: ProjectRules{Exclude: []string{"/work/clients/**"}}, slug: "-work-clients", path: "/work/clients", wantReason: `matches exclude "/work/clients/**"`},
+		{name: "star stops at slash", rules: ProjectRules{Exclude: []string{"/tmp/*"}}, slug: "-tmp-a-b", path: "/tmp/a/b", want: true},
+		{name: "exclude by slug", rules: ProjectRules{Exclude: []string{"*scratch*"}}, slug: "-Users-me-scratch", path: "/Users/me/scratch", wantReason: `matches exclude "*scratch*"`},
+		{name: "include matches", rules: ProjectRules{Include: []string{"/work/**"}}, slug: "-work-app", path: "/work/app", want: true},
+		{name: "include misses", rules: ProjectRules{Include: []string{"/work/**"}}, slug: "-home-app", path: "/home/app", wantReason: "matches no include pattern"},
+		{name: "exclude wins over include", rules: ProjectRules{Include: []string{"/work/**"}, Exclude: []string{"/work/nda"}}, slug: "-work-nda", path: "/work/nda", wantReason: `matches exclude "/work/nda"`},
+		{name: "character class", rules: ProjectRules{Include: []string{"/p[0-9]"}}, slug: "-p7", path: "/p7", want: true},
+		{name: "negated class", rules: ProjectRules{Include: []string{"/p[!0-9]"}}, slug: "-p7", path: "/p7", wantReason: "matches no include pattern"},
+		{name: "literal dot", rules: ProjectRules{Include: []string{"/a.b"}}, slug: "-axb", path: "/axb", wantReason: "matches no include pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.rules.Match(tt.slug, tt.path)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestLoadConfigProjectRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{name: "valid", json: `{"projects": {"include": ["/work/**"], "exclude": ["*tmp*"]}}`},
+		{name: "unterminated class", json: `{"projects": {"exclude": ["/work/[abc"]}}`, wantErr: `bad pattern "/work/[abc"`},
+		{name: "empty pattern", json: `{"projects": {"include": [""]}}`, wantErr: "empty pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("CLAUDE_USAGE_DATA_DIR", dir)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(tt.json), 0600))
+
+			cfg, err := LoadConfig()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"/work/**"}, cfg.Projects.Include)
+		})
+	}
+}